@@ -0,0 +1,148 @@
+package pgmigrate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ArchiveSource serves migrations from a directory inside a .zip, .tar or
+// .tar.gz archive, so a release bundle can ship its migrations as a single
+// file. The archive is read into memory when it is opened.
+type ArchiveSource struct {
+	files map[string][]byte
+}
+
+// IsArchive reports whether OpenArchive can read the file at filename, going
+// by its extension
+func IsArchive(filename string) bool {
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(filename, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenArchive reads the files in dir of the archive at filename, where dir is
+// a slash separated path from the root of the archive, or "" for the root
+// itself.
+func OpenArchive(filename string, dir string) (*ArchiveSource, error) {
+	dir = path.Clean("/" + dir)
+	source := &ArchiveSource{files: map[string][]byte{}}
+	add := func(name string, open func() (io.ReadCloser, error)) error {
+		name = path.Clean("/" + name)
+		if path.Dir(name) != dir {
+			return nil
+		}
+		reader, err := open()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		content, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return fmt.Errorf("reading %s from %s: %w", name, filename, err)
+		}
+		source.files[path.Base(name)] = content
+		return nil
+	}
+
+	switch {
+	case strings.HasSuffix(filename, ".zip"):
+		archive, err := zip.OpenReader(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer archive.Close()
+		for _, file := range archive.File {
+			if file.FileInfo().IsDir() {
+				continue
+			}
+			if err := add(file.Name, file.Open); err != nil {
+				return nil, err
+			}
+		}
+
+	case IsArchive(filename):
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		var reader io.Reader = file
+		if !strings.HasSuffix(filename, ".tar") {
+			gz, err := gzip.NewReader(file)
+			if err != nil {
+				return nil, fmt.Errorf("decompressing %s: %w", filename, err)
+			}
+			defer gz.Close()
+			reader = gz
+		}
+
+		archive := tar.NewReader(reader)
+		for {
+			header, err := archive.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", filename, err)
+			}
+			if header.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := add(header.Name, func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(archive), nil
+			}); err != nil {
+				return nil, err
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("%s is not a .zip, .tar or .tar.gz archive", filename)
+	}
+
+	return source, nil
+}
+
+func (source *ArchiveSource) List() ([]Migration, error) {
+	migrations, err := ParseFilenames(source.names())
+	if err != nil {
+		return nil, err
+	}
+	for idx := range migrations {
+		migrations[idx].source = source
+	}
+	return migrations, nil
+}
+
+// ListRepeatable lists the R-description.sql files in the archive directory
+func (source *ArchiveSource) ListRepeatable() ([]Repeatable, error) {
+	return ParseRepeatableFilenames(source.names()), nil
+}
+
+func (source *ArchiveSource) names() []string {
+	names := make([]string, 0, len(source.files))
+	for name := range source.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (source *ArchiveSource) ReadFile(name string) ([]byte, error) {
+	content, ok := source.files[name]
+	if !ok {
+		return nil, fmt.Errorf("reading %s: %w", name, os.ErrNotExist)
+	}
+	return content, nil
+}
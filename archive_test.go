@@ -0,0 +1,109 @@
+package pgmigrate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testArchiveFiles = map[string]string{
+	"release/migrations/001-foo.up.sql":   s1u,
+	"release/migrations/001-foo.down.sql": s1d,
+	"release/migrations/002-bar.up.sql":   s2u,
+	"release/README.md":                   "ignored",
+	"release/other/003-baz.up.sql":        s3u,
+}
+
+func writeZip(t *testing.T, filename string) {
+	t.Helper()
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer file.Close()
+	archive := zip.NewWriter(file)
+	for name, content := range testArchiveFiles {
+		writer, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if _, err := writer.Write([]byte(content)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func writeTarGz(t *testing.T, filename string) {
+	t.Helper()
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	archive := tar.NewWriter(gz)
+	for name, content := range testArchiveFiles {
+		if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err.Error())
+		}
+		if _, err := archive.Write([]byte(content)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestArchiveSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	zipFile := filepath.Join(dir, "release.zip")
+	writeZip(t, zipFile)
+	tarFile := filepath.Join(dir, "release.tar.gz")
+	writeTarGz(t, tarFile)
+
+	for _, filename := range []string{zipFile, tarFile} {
+		if !IsArchive(filename) {
+			t.Errorf("Expected %s to be an archive", filename)
+		}
+
+		source, err := OpenArchive(filename, "release/migrations")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		migrations, err := source.List()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(migrations) != 2 || migrations[0].DownFile != "001-foo.down.sql" || migrations[1].Name != "bar" {
+			t.Errorf("%s: unexpected migrations %+v", filename, migrations)
+			continue
+		}
+		up, err := migrations[1].UpSQL()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if up != s2u {
+			t.Errorf("%s: wrong content %q", filename, up)
+		}
+	}
+
+	if _, err := OpenArchive(filepath.Join(dir, "release.rar"), ""); err == nil {
+		t.Error("Expected an error for an unknown archive type")
+	}
+}
@@ -0,0 +1,77 @@
+// Package azuread authenticates to Azure Database for PostgreSQL with a
+// Microsoft Entra ID, formerly Azure AD, access token as the password. Tokens
+// are fetched again once they are close to expiring, so a pool which
+// reconnects later gets a fresh one.
+package azuread
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"gopkg.daemonl.com/pgmigrate/pgauth"
+)
+
+// Scope is what the tokens are for, Azure Database for PostgreSQL
+const Scope = "https://ossrdbms-aad.database.windows.net/.default"
+
+// refreshBefore is how long before a token expires it is replaced
+const refreshBefore = 5 * time.Minute
+
+// Provider is a pgauth.Provider using access tokens from a credential as the
+// password. The connection's user is the Entra ID user, group or managed
+// identity name the server knows.
+type Provider struct {
+	credential azcore.TokenCredential
+
+	lock  sync.Mutex
+	token azcore.AccessToken
+}
+
+// NewProvider creates a Provider fetching tokens from credential
+func NewProvider(credential azcore.TokenCredential) *Provider {
+	return &Provider{credential: credential}
+}
+
+// New creates a Provider from azidentity's default credential, which tries
+// the environment, workload identity, managed identity and the Azure CLI
+func New() (*Provider, error) {
+	credential, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewProvider(credential), nil
+}
+
+// Credentials returns the current token, fetching a new one if it is close
+// to expiring
+func (p *Provider) Credentials(ctx context.Context, params pgauth.Params) (pgauth.Credentials, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if time.Until(p.token.ExpiresOn) < refreshBefore {
+		token, err := p.credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{Scope}})
+		if err != nil {
+			return pgauth.Credentials{}, err
+		}
+		p.token = token
+	}
+	return pgauth.Credentials{Password: p.token.Token}, nil
+}
+
+// Open opens a pool connecting to dsn, which shouldn't have a password, as
+// its user with tokens from the default credential
+func Open(dsn string) (*sql.DB, error) {
+	provider, err := New()
+	if err != nil {
+		return nil, err
+	}
+	connector, err := pgauth.NewConnector(dsn, provider)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(connector), nil
+}
@@ -0,0 +1,57 @@
+package azuread
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"gopkg.daemonl.com/pgmigrate/pgauth"
+)
+
+// countingCredential issues numbered tokens lasting lifetime
+type countingCredential struct {
+	lifetime time.Duration
+	issued   int
+	scopes   []string
+}
+
+func (c *countingCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	c.issued++
+	c.scopes = options.Scopes
+	return azcore.AccessToken{Token: fmt.Sprintf("token-%d", c.issued), ExpiresOn: time.Now().Add(c.lifetime)}, nil
+}
+
+func TestCredentials(t *testing.T) {
+	ctx := context.Background()
+	params := pgauth.Params{Host: "app.postgres.database.azure.com", Port: "5432", User: "migrator@example.com"}
+
+	credential := &countingCredential{lifetime: time.Hour}
+	provider := NewProvider(credential)
+	for i := 0; i < 2; i++ {
+		creds, err := provider.Credentials(ctx, params)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if creds.Password != "token-1" || creds.User != "" {
+			t.Errorf("Expected the first token to be reused, got %+v", creds)
+		}
+	}
+	if len(credential.scopes) != 1 || credential.scopes[0] != Scope {
+		t.Errorf("Wrong scopes %v", credential.scopes)
+	}
+
+	// A token about to expire is replaced
+	expiring := &countingCredential{lifetime: time.Minute}
+	provider = NewProvider(expiring)
+	for i := 0; i < 2; i++ {
+		if _, err := provider.Credentials(ctx, params); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if expiring.issued != 2 {
+		t.Errorf("Expected a token for each connection, got %d", expiring.issued)
+	}
+}
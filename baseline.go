@@ -0,0 +1,67 @@
+package pgmigrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Baseline adopts a database whose schema already matches version, recording
+// it as applied without running any files. See Migrator.Baseline.
+func Baseline(ctx context.Context, conn Queryer, version int, opts ...Option) error {
+	return New(conn, opts...).Baseline(ctx, version)
+}
+
+// Baseline records version as applied without running any files, so
+// pgmigrate can take over a long lived database whose early migrations were
+// applied some other way. Later runs start from the migration after version.
+// It refuses a database which pgmigrate already tracks; use Force to change
+// the version of one of those.
+func (m *Migrator) Baseline(ctx context.Context, version int) error {
+	if version < 1 {
+		return fmt.Errorf("baseline version must be at least 1, got %d", version)
+	}
+
+	return m.withSession(ctx, func(conn Queryer) error {
+		return m.adopt(ctx, conn, version, "baseline")
+	})
+}
+
+// adopt records version as applied on a database pgmigrate doesn't yet
+// track, with name in the history saying where the version came from
+func (m *Migrator) adopt(ctx context.Context, conn Queryer, version int, name string) error {
+	table := m.cfg.table
+	currentVersion, err := table.getVersion(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if err := table.ensureDirtyColumn(ctx, conn); err != nil {
+		return err
+	}
+	if err := table.ensureHistoryTable(ctx, conn); err != nil {
+		return err
+	}
+	if m.cfg.scheme == TimestampVersions {
+		if err := table.ensureBigintVersions(ctx, conn); err != nil {
+			return err
+		}
+	}
+
+	history, err := table.history(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if currentVersion != 0 || len(history) > 0 {
+		return fmt.Errorf("can't adopt a database pgmigrate already tracks, it is at version %d", currentVersion)
+	}
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET version = $1, dirty_version = NULL`, table.versionTable()), version); err != nil {
+		return err
+	}
+	return table.recordHistory(ctx, conn, HistoryEntry{
+		Version:   version,
+		Name:      name,
+		Direction: directionBaseline,
+		AppliedAt: time.Now(),
+	})
+}
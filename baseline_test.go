@@ -0,0 +1,39 @@
+package pgmigrate
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_baseline")
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	// The legacy database already has the first two tables
+	if _, err := conn.ExecContext(ctx, s1u+s2u); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	migrator := New(conn, WithSource(DirSource(migrateDir)))
+	if err := migrator.Baseline(ctx, 2); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Expected only 3 to run after the baseline: %s", err.Error())
+	}
+	if v, err := migrator.Version(ctx); err != nil {
+		t.Fatal(err.Error())
+	} else if v != 3 {
+		t.Fatalf("Expected version 3, got %d", v)
+	}
+
+	if err := migrator.Baseline(ctx, 2); err == nil {
+		t.Fatal("Expected baselining a tracked database to fail")
+	}
+}
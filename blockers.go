@@ -0,0 +1,161 @@
+package pgmigrate
+
+import (
+	"context"
+	"time"
+)
+
+// WithBlockerReport logs which sessions are blocking a migration once it has
+// been running for threshold, and again every threshold after that while it
+// is still blocked, so a hung deploy can be explained without opening psql.
+// Each blocking session logs lock_blocked, with its pid, user, application,
+// state, transaction age and query. The sessions are looked up on another
+// connection from the pool, so it does nothing for a Migrator created with a
+// single connection. The logger is called from another goroutine.
+func WithBlockerReport(threshold time.Duration) Option {
+	return func(cfg *config) {
+		cfg.blockerThreshold = threshold
+	}
+}
+
+// WithIdleBlockers deals with sessions which are idle in a transaction, and
+// have been for longer than idleFor, while holding a lock a migration is
+// waiting for, as stuck clients often do. Unless terminate is set they are
+// only logged, as idle_blocker, to check what would go. With it they are
+// ended with pg_terminate_backend, logging blocker_terminated. Both have the
+// fields of lock_blocked. Sessions doing work are never terminated. Like
+// WithBlockerReport, it needs a pool.
+func WithIdleBlockers(idleFor time.Duration, terminate bool) Option {
+	return func(cfg *config) {
+		cfg.idleBlockerAge = idleFor
+		cfg.terminateIdleBlockers = terminate
+	}
+}
+
+// idleBlockerInterval is how often blockers are checked for WithIdleBlockers
+// without WithBlockerReport
+const idleBlockerInterval = time.Second
+
+// blocker is a session holding a lock a migration is waiting for
+type blocker struct {
+	pid            int
+	user           string
+	application    string
+	state          string
+	query          string
+	transactionAge time.Duration
+	stateAge       time.Duration
+}
+
+// queryBlockers lists the sessions blocking the backend pid
+func queryBlockers(ctx context.Context, monitor Queryer, pid int) ([]blocker, error) {
+	rows, err := monitor.QueryContext(ctx, `
+		SELECT pid, coalesce(usename, ''), coalesce(application_name, ''),
+			coalesce(state, ''), coalesce(query, ''),
+			coalesce(extract(epoch FROM now() - xact_start), 0),
+			coalesce(extract(epoch FROM now() - state_change), 0)
+		FROM pg_stat_activity
+		WHERE pid = ANY(pg_blocking_pids($1))
+		ORDER BY pid`, pid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blockers := []blocker{}
+	for rows.Next() {
+		b := blocker{}
+		var transactionSeconds, stateSeconds float64
+		if err := rows.Scan(&b.pid, &b.user, &b.application, &b.state, &b.query, &transactionSeconds, &stateSeconds); err != nil {
+			return nil, err
+		}
+		b.transactionAge = secondsDuration(transactionSeconds)
+		b.stateAge = secondsDuration(stateSeconds)
+		blockers = append(blockers, b)
+	}
+	return blockers, rows.Err()
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Millisecond)
+}
+
+// idle reports whether b is idle in a transaction for longer than idleFor
+func (b blocker) idle(idleFor time.Duration) bool {
+	return (b.state == "idle in transaction" || b.state == "idle in transaction (aborted)") && b.stateAge > idleFor
+}
+
+// watchBlockers reports the sessions blocking conn, which is about to run
+// step, until the returned function is called
+func watchBlockers(ctx context.Context, conn Queryer, cfg *config, step step) func() {
+	if (cfg.blockerThreshold <= 0 && cfg.idleBlockerAge <= 0) || cfg.monitor == nil {
+		return func() {}
+	}
+	var pid int
+	if err := conn.QueryRowContext(ctx, `SELECT pg_backend_pid()`).Scan(&pid); err != nil {
+		return func() {}
+	}
+	interval := cfg.blockerThreshold
+	if interval <= 0 {
+		interval = idleBlockerInterval
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		started := time.Now()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			blockers, err := queryBlockers(ctx, cfg.monitor, pid)
+			if err != nil {
+				cfg.logger.Error("lock_blocked", "version", step.Version, "filename", step.Filename(), "error", err.Error())
+				continue
+			}
+			for _, b := range blockers {
+				fields := []interface{}{"version", step.Version, "filename", step.Filename(), "waiting", time.Since(started).Round(time.Second),
+					"blocking_pid", b.pid, "blocking_user", b.user, "blocking_application", b.application,
+					"blocking_state", b.state, "blocking_transaction_age", b.transactionAge, "blocking_query", b.query}
+				if cfg.blockerThreshold > 0 {
+					cfg.logger.Error("lock_blocked", fields...)
+				}
+				if cfg.idleBlockerAge > 0 && b.idle(cfg.idleBlockerAge) {
+					cfg.handleIdleBlocker(ctx, b, fields)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// handleIdleBlocker logs an idle blocker, terminating it if configured to.
+// The state is checked again as it is terminated, in case the session has
+// woken up since it was listed.
+func (cfg *config) handleIdleBlocker(ctx context.Context, b blocker, fields []interface{}) {
+	if !cfg.terminateIdleBlockers {
+		cfg.logger.Error("idle_blocker", fields...)
+		return
+	}
+	var terminated bool
+	err := cfg.monitor.QueryRowContext(ctx, `
+		SELECT coalesce(bool_or(pg_terminate_backend(pid)), false)
+		FROM pg_stat_activity
+		WHERE pid = $1 AND state IN ('idle in transaction', 'idle in transaction (aborted)')
+			AND now() - state_change > $2 * interval '1 millisecond'`, b.pid, cfg.idleBlockerAge.Milliseconds()).Scan(&terminated)
+	if err != nil {
+		cfg.logger.Error("blocker_terminated", append(fields, "error", err.Error())...)
+		return
+	}
+	if terminated {
+		cfg.logger.Error("blocker_terminated", fields...)
+	}
+}
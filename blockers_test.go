@@ -0,0 +1,115 @@
+package pgmigrate
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBlockerReport(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"002-bar.up.sql":   "ALTER TABLE foo ADD COLUMN bar int;",
+		"002-bar.down.sql": "ALTER TABLE foo DROP COLUMN bar;",
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_blocker_report")
+	defer conn.Close()
+
+	ctx := context.Background()
+	if err := New(conn, WithSource(DirSource(migrateDir))).To(ctx, 1); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT * FROM foo`); err != nil {
+		t.Fatal(err.Error())
+	}
+	go func() {
+		time.Sleep(time.Second)
+		tx.Rollback() //nolint: errcheck
+	}()
+
+	out := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(out, nil))
+	if err := New(conn, WithSource(DirSource(migrateDir)), WithLogger(logger), WithBlockerReport(200*time.Millisecond)).Up(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(out.String(), "lock_blocked") || !strings.Contains(out.String(), `blocking_query="SELECT * FROM foo"`) {
+		t.Errorf("Expected the blocking session to be logged, got %s", out.String())
+	}
+}
+
+func TestBlockerIdle(t *testing.T) {
+	for _, tc := range []struct {
+		b    blocker
+		want bool
+	}{
+		{blocker{state: "idle in transaction", stateAge: time.Minute}, true},
+		{blocker{state: "idle in transaction (aborted)", stateAge: time.Minute}, true},
+		{blocker{state: "idle in transaction", stateAge: time.Second}, false},
+		{blocker{state: "active", stateAge: time.Minute}, false},
+	} {
+		if got := tc.b.idle(30 * time.Second); got != tc.want {
+			t.Errorf("%s for %s: expected %v, got %v", tc.b.state, tc.b.stateAge, tc.want, got)
+		}
+	}
+}
+
+func TestIdleBlockers(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"002-bar.up.sql":   "ALTER TABLE foo ADD COLUMN bar int;",
+		"002-bar.down.sql": "ALTER TABLE foo DROP COLUMN bar;",
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_idle_blockers")
+	defer conn.Close()
+
+	ctx := context.Background()
+	if err := New(conn, WithSource(DirSource(migrateDir))).To(ctx, 1); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// A client which never finishes its transaction
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer tx.Rollback() //nolint: errcheck
+	if _, err := tx.ExecContext(ctx, `SELECT * FROM foo`); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Without terminate the blocker is only listed, so the lock is never
+	// granted
+	out := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(out, nil))
+	listOnly := New(conn, WithSource(DirSource(migrateDir)), WithLogger(logger), WithIdleBlockers(100*time.Millisecond, false), WithLockTimeout(time.Second, 0))
+	if err := listOnly.Up(ctx); err == nil {
+		t.Fatal("Expected the migration to time out waiting for its lock")
+	}
+	if !strings.Contains(out.String(), "idle_blocker") {
+		t.Errorf("Expected the idle blocker to be listed, got %s", out.String())
+	}
+
+	out.Reset()
+	terminating := New(conn, WithSource(DirSource(migrateDir)), WithLogger(logger), WithIdleBlockers(100*time.Millisecond, true))
+	if err := terminating.Up(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(out.String(), "blocker_terminated") {
+		t.Errorf("Expected the idle blocker to be terminated, got %s", out.String())
+	}
+}
@@ -0,0 +1,92 @@
+package pgmigrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// ChecksumMismatchError is returned when a migration file has been edited
+// after it was applied.
+type ChecksumMismatchError struct {
+	Version  int
+	Filename string
+	Applied  string
+	Current  string
+}
+
+func (err *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("migration %d (%s) has changed since it was applied: checksum was %s, now %s", err.Version, err.Filename, err.Applied, err.Current)
+}
+
+// Is matches ErrChecksumMismatch
+func (err *ChecksumMismatchError) Is(target error) bool {
+	return target == ErrChecksumMismatch
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyChecksums compares the up files of applied migrations against the
+// checksum recorded when they last ran. Migrations applied before history was
+// recorded have nothing to compare against, and are skipped, as is a squashed
+// migration in a database which ran the files it replaced.
+func (t trackingTable) verifyChecksums(ctx context.Context, conn Queryer, source MigrationSource, byVersion map[int]Migration, currentVersion int) error {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf(`
+		SELECT DISTINCT ON (version) version, checksum
+		FROM %s
+		WHERE direction = $1 AND version <= $2
+		ORDER BY version, id DESC
+	`, t.historyTable()), directionUp, currentVersion)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	applied := map[int]string{}
+	for rows.Next() {
+		var version int
+		var sum string
+		if err := rows.Scan(&version, &sum); err != nil {
+			return err
+		}
+		applied[version] = sum
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	for _, version := range versions {
+		appliedSum := applied[version]
+		migration, ok := byVersion[version]
+		if !ok || migration.UpFile == "" {
+			continue
+		}
+		if migration.squashed && version != versions[0] {
+			continue
+		}
+		content, err := readExpanded(source, migration.UpFile)
+		if err != nil {
+			return err
+		}
+		if currentSum := checksum(content); currentSum != appliedSum {
+			return &ChecksumMismatchError{
+				Version:  version,
+				Filename: migration.UpFile,
+				Applied:  appliedSum,
+				Current:  currentSum,
+			}
+		}
+	}
+	return nil
+}
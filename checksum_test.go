@@ -0,0 +1,37 @@
+package pgmigrate
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChecksumMismatch(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_checksum")
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	if err := MigrateDatabase(ctx, conn, migrateDir, 2); err != nil {
+		t.Fatalf("Unable to migrate: %s", err.Error())
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(migrateDir, "001-foo.up.sql"), []byte(`CREATE TABLE foo (id bigint);`), 0660); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err := MigrateDatabase(ctx, conn, migrateDir, -1)
+	mismatch := &ChecksumMismatchError{}
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected a ChecksumMismatchError, got %v", err)
+	}
+	if mismatch.Version != 1 {
+		t.Errorf("Expected version 1 to mismatch, got %d", mismatch.Version)
+	}
+}
@@ -0,0 +1,98 @@
+// Package cloudsql connects to Cloud SQL for PostgreSQL through the Cloud SQL
+// Go connector, by instance connection name, so migrations can run from
+// Cloud Build or Cloud Run without the auth proxy sidecar. The connector
+// handles TLS, and with IAM authentication the login too.
+package cloudsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/cloudsqlconn"
+	"github.com/lib/pq"
+)
+
+// Connector is a driver.Connector for sql.OpenDB, dialing an instance
+// through the Cloud SQL Go connector
+type Connector struct {
+	dialer   *cloudsqlconn.Dialer
+	instance string
+	dsn      string
+}
+
+// NewConnector creates a Connector for instance, a connection name such as
+// project:region:instance. dsn, a URL or key=value connection string, gives
+// the user, database and, without IAM authentication, the password; its host
+// is ignored. opts configure the dialer, as cloudsqlconn.WithIAMAuthN logs in
+// as the user's IAM principal.
+func NewConnector(ctx context.Context, instance string, dsn string, opts ...cloudsqlconn.Option) (*Connector, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		var err error
+		dsn, err = pq.ParseURL(dsn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	dialer, err := cloudsqlconn.NewDialer(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	// The connector's connection is already encrypted
+	return &Connector{dialer: dialer, instance: instance, dsn: dsn + " sslmode=disable"}, nil
+}
+
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	connector, err := pq.NewConnector(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	connector.Dialer(instanceDialer{c})
+	return connector.Connect(ctx)
+}
+
+func (c *Connector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// Close stops the dialer refreshing the instance's certificates, and is
+// called by sql.DB's Close
+func (c *Connector) Close() error {
+	return c.dialer.Close()
+}
+
+// instanceDialer dials the instance whatever address lib/pq asks for
+type instanceDialer struct {
+	connector *Connector
+}
+
+func (d instanceDialer) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	return d.connector.dialer.Dial(ctx, d.connector.instance)
+}
+
+func (d instanceDialer) Dial(network string, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+func (d instanceDialer) DialTimeout(network string, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.DialContext(ctx, network, address)
+}
+
+// Open opens a pool connecting to instance as dsn's user, logging in with IAM
+// when iam is set, and otherwise with dsn's password
+func Open(ctx context.Context, instance string, dsn string, iam bool) (*sql.DB, error) {
+	opts := []cloudsqlconn.Option{}
+	if iam {
+		opts = append(opts, cloudsqlconn.WithIAMAuthN())
+	}
+	connector, err := NewConnector(ctx, instance, dsn, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(connector), nil
+}
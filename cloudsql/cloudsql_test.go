@@ -0,0 +1,49 @@
+package cloudsql
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/cloudsqlconn"
+	"golang.org/x/oauth2"
+)
+
+func TestConnectDialsInstance(t *testing.T) {
+	lock := sync.Mutex{}
+	paths := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		paths = append(paths, r.URL.Path)
+		lock.Unlock()
+		http.Error(w, "no such instance", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	connector, err := NewConnector(ctx, "my-project:europe-west1:my-instance", "postgres://migrator@/app",
+		cloudsqlconn.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})),
+		cloudsqlconn.WithAdminAPIEndpoint(server.URL),
+		cloudsqlconn.WithLazyRefresh(),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer connector.Close()
+
+	if !strings.Contains(connector.dsn, "user='migrator'") || !strings.HasSuffix(connector.dsn, " sslmode=disable") {
+		t.Errorf("Wrong connection string %q", connector.dsn)
+	}
+
+	if _, err := connector.Connect(ctx); err == nil {
+		t.Fatal("Expected the fake admin API to fail the dial")
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(paths) == 0 || !strings.Contains(paths[0], "projects/my-project/instances/my-instance") {
+		t.Errorf("Expected the admin API to be asked about the instance, got %v", paths)
+	}
+}
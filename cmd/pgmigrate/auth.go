@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"cloud.google.com/go/cloudsqlconn"
+	"github.com/lib/pq"
+	"gopkg.daemonl.com/pgmigrate/azuread"
+	"gopkg.daemonl.com/pgmigrate/cloudsql"
+	"gopkg.daemonl.com/pgmigrate/pgauth"
+	"gopkg.daemonl.com/pgmigrate/rdsiam"
+	"gopkg.daemonl.com/pgmigrate/vaultcreds"
+)
+
+var (
+	providerOnce sync.Once
+	provider     pgauth.Provider
+	providerErr  error
+)
+
+// authProvider supplies the credentials of each connection for -auth, and is
+// nil for a password, from the URL, environment or password file
+func (cf commonFlags) authProvider(ctx context.Context) (pgauth.Provider, error) {
+	providerOnce.Do(func() {
+		switch *cf.auth {
+		case "password", "cloudsql-iam":
+		case "rds-iam":
+			provider, providerErr = rdsiam.New(ctx)
+		case "azure-ad":
+			provider, providerErr = azuread.New()
+		case "vault":
+			if *cf.vaultRole == "" {
+				providerErr = fmt.Errorf("-auth vault requires -vault-role")
+				return
+			}
+			vault, err := vaultcreds.New(*cf.vaultRole)
+			if err != nil {
+				providerErr = err
+				return
+			}
+			vault.Mount = *cf.vaultMount
+			provider = vault
+		default:
+			providerErr = fmt.Errorf("Unknown -auth %q, use password, rds-iam, azure-ad, vault or cloudsql-iam", *cf.auth)
+		}
+	})
+	return provider, providerErr
+}
+
+// closeAuthProvider releases what the -auth provider holds, revoking Vault's
+// lease, once the command is done
+func closeAuthProvider() {
+	closer, ok := provider.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		log.Printf("releasing the -auth credentials: %s", err.Error())
+	}
+}
+
+// connector connects to pgURL, through the Cloud SQL connector with
+// -cloudsql-instance, and with the credentials of -auth. lib/pq fills in what
+// pgURL leaves out from the libpq environment variables, and the password
+// from the password file.
+func (cf commonFlags) connector(ctx context.Context, pgURL string) (driver.Connector, error) {
+	checkPasswordFile()
+	provider, err := cf.authProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if *cf.cloudSQL != "" {
+		if provider != nil {
+			return nil, fmt.Errorf("-cloudsql-instance authenticates with a password or -auth cloudsql-iam")
+		}
+		opts := []cloudsqlconn.Option{}
+		if *cf.auth == "cloudsql-iam" {
+			opts = append(opts, cloudsqlconn.WithIAMAuthN())
+		}
+		return cloudsql.NewConnector(ctx, *cf.cloudSQL, pgURL, opts...)
+	}
+	if *cf.auth == "cloudsql-iam" {
+		return nil, fmt.Errorf("-auth cloudsql-iam requires -cloudsql-instance")
+	}
+	if provider != nil {
+		return pgauth.NewConnector(pgURL, provider)
+	}
+	return pq.NewConnector(pgURL)
+}
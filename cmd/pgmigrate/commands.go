@@ -0,0 +1,494 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.daemonl.com/pgmigrate"
+)
+
+func runUp(ctx context.Context, args []string) error {
+	fs, common := newFlagSet("up")
+	targetVersion := fs.Int("target", -1, "The target version. (-1 = latest)")
+	dryRun := fs.Bool("dry-run", false, "Print the files which would run, without running them")
+	allowDown := fs.Bool("allow-down", false, "Allow a target below the current version, which runs down files")
+	modules := fs.Bool("modules", false, "Migrate each directory in the migrations directory as a module, to its latest version")
+	schemas := fs.String("schemas", "", "Migrate each of these comma separated schemas to the latest version")
+	schemasLike := fs.String("schemas-like", "", "Migrate each schema matching this LIKE pattern to the latest version")
+	tenantsQuery := fs.String("tenants-query", "", "Migrate each schema named by this query to the latest version")
+	lint := fs.Bool("lint", false, "Warn about statements which take heavy locks or rewrite tables before running them")
+	serverVersion := fs.Int("server-version", 0, "The major version of the server, for -lint (0 = current)")
+	shadow := fs.Bool("shadow", false, "Rehearse the run in a scratch schema first, and only go ahead if it succeeds")
+	shadowURL := fs.String("shadow-postgres", "", "Rehearse the run against this empty scratch database first")
+	common.parse(fs, args)
+
+	steps, err := stepsArg(fs, "up")
+	if err != nil {
+		return err
+	}
+	if steps != 0 && *targetVersion != -1 {
+		return fmt.Errorf("up takes either -target or a number of steps, not both")
+	}
+	fanOut := 0
+	for _, set := range []bool{*modules, *schemas != "", *schemasLike != "", *tenantsQuery != ""} {
+		if set {
+			fanOut++
+		}
+	}
+	if fanOut > 1 {
+		return fmt.Errorf("use only one of -modules, -schemas, -schemas-like and -tenants-query")
+	}
+	if fanOut > 0 && (steps != 0 || *targetVersion != -1) {
+		return fmt.Errorf("-modules, -schemas, -schemas-like and -tenants-query always migrate to the latest version")
+	}
+
+	opts := append(migrateOptions(*dryRun), pgmigrate.WithAllowDown(*allowDown))
+	if *lint {
+		opts = append(opts, pgmigrate.WithLint(*serverVersion))
+	}
+	if *shadow {
+		opts = append(opts, pgmigrate.WithShadowSchema(true))
+	}
+	if *shadowURL != "" {
+		shadowPool, err := common.open(ctx, *shadowURL)
+		if err != nil {
+			return fmt.Errorf("shadow database: %w", err)
+		}
+		defer shadowPool.Close()
+		opts = append(opts, pgmigrate.WithShadowDatabase(shadowPool))
+	}
+	return common.eachDatabase(ctx, func(ctx context.Context, dbPool *sql.DB) error {
+		opts := append([]pgmigrate.Option{noticeOption(dbPool)}, opts...)
+		if *modules {
+			moduleList, err := pgmigrate.ModulesFromDir(*common.migrationsDir)
+			if err != nil {
+				return err
+			}
+			return pgmigrate.MigrateModules(ctx, dbPool, moduleList, common.options(opts...)...)
+		}
+		if *schemas != "" || *schemasLike != "" || *tenantsQuery != "" {
+			schemaList := strings.Split(*schemas, ",")
+			var err error
+			if *schemasLike != "" {
+				schemaList, err = pgmigrate.SchemasLike(ctx, dbPool, *schemasLike)
+			} else if *tenantsQuery != "" {
+				schemaList, err = pgmigrate.SchemasFromQuery(ctx, dbPool, *tenantsQuery)
+			}
+			if err != nil {
+				return err
+			}
+			return pgmigrate.MigrateSchemas(ctx, dbPool, schemaList, common.options(opts...)...)
+		}
+
+		migrator := pgmigrate.New(dbPool, common.options(opts...)...)
+		if steps != 0 {
+			return migrator.UpSteps(ctx, steps)
+		}
+		return migrator.To(ctx, *targetVersion)
+	})
+}
+
+func runDown(ctx context.Context, args []string) error {
+	fs, common := newFlagSet("down")
+	dryRun := fs.Bool("dry-run", false, "Print the files which would run, without running them")
+	allowDown := fs.Bool("allow-down", false, "Allow running down files, which down refuses without")
+	common.parse(fs, args)
+
+	steps, err := stepsArg(fs, "down")
+	if err != nil {
+		return err
+	}
+	if steps == 0 {
+		steps = 1
+	}
+
+	return common.eachDatabase(ctx, func(ctx context.Context, dbPool *sql.DB) error {
+		opts := append(migrateOptions(*dryRun), pgmigrate.WithAllowDown(*allowDown), noticeOption(dbPool))
+		return pgmigrate.New(dbPool, common.options(opts...)...).DownSteps(ctx, steps)
+	})
+}
+
+func runResume(ctx context.Context, args []string) error {
+	fs, common := newFlagSet("resume")
+	allowDown := fs.Bool("allow-down", false, "Allow resuming a run down, which runs down files")
+	common.parse(fs, args)
+
+	dbPool, err := common.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer dbPool.Close()
+
+	migrator := pgmigrate.New(dbPool, common.options(pgmigrate.WithAllowDown(*allowDown), noticeOption(dbPool))...)
+	failure, err := migrator.LastFailure(ctx)
+	if err != nil {
+		return err
+	}
+	if failure == nil {
+		return fmt.Errorf("nothing to resume, the last run didn't fail")
+	}
+	fmt.Fprintf(os.Stderr, "Resuming from version %d to %d, after %s failed at %s: %s\n",
+		failure.Version, failure.Target, failure.Filename, failure.FailedAt.Format(time.RFC3339), failure.Error)
+	return migrator.Resume(ctx)
+}
+
+// stepsArg parses the optional number of steps after the flags, returning 0
+// if there isn't one
+func stepsArg(fs *flag.FlagSet, name string) (int, error) {
+	if fs.NArg() == 0 {
+		return 0, nil
+	}
+	steps, err := strconv.Atoi(fs.Arg(0))
+	if err != nil || steps < 1 || fs.NArg() > 1 {
+		return 0, fmt.Errorf("%s takes a single positive number of steps, got %q", name, strings.Join(fs.Args(), " "))
+	}
+	return steps, nil
+}
+
+func migrateOptions(dryRun bool) []pgmigrate.Option {
+	opts := []pgmigrate.Option{}
+	if dryRun {
+		opts = append(opts, pgmigrate.WithDryRun(os.Stdout))
+	}
+	return opts
+}
+
+func runStatus(ctx context.Context, args []string) error {
+	fs, common := newFlagSet("status")
+	asJSON := fs.Bool("json", false, "Print the status as JSON, the same as -output json")
+	common.parse(fs, args)
+
+	dbPool, err := common.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer dbPool.Close()
+
+	statuses, err := pgmigrate.New(dbPool, common.options()...).Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON || common.jsonOutput() {
+		return printJSON(os.Stdout, statuses)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATE\tAPPLIED AT\tDURATION")
+	for _, status := range statuses {
+		state := "pending"
+		appliedAt := ""
+		duration := ""
+		if status.Applied {
+			state = "applied"
+		}
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		if status.Duration != nil {
+			duration = status.Duration.String()
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt, duration)
+	}
+	return tw.Flush()
+}
+
+func runVersion(ctx context.Context, args []string) error {
+	fs, common := newFlagSet("version")
+	common.parse(fs, args)
+
+	dbPool, err := common.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer dbPool.Close()
+
+	currentVersion, err := pgmigrate.New(dbPool, common.options()...).ReadVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	if common.jsonOutput() {
+		return printJSON(os.Stdout, map[string]int{"version": currentVersion})
+	}
+	fmt.Println(currentVersion)
+	return nil
+}
+
+func runBaseline(ctx context.Context, args []string) error {
+	fs, common := newFlagSet("baseline")
+	version := fs.Int("version", 0, "The version the database's schema already matches")
+	common.parse(fs, args)
+
+	if *version < 1 {
+		return fmt.Errorf("baseline requires -version")
+	}
+
+	dbPool, err := common.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer dbPool.Close()
+
+	return pgmigrate.New(dbPool, common.options()...).Baseline(ctx, *version)
+}
+
+func runImport(ctx context.Context, args []string) error {
+	fs, common := newFlagSet("import")
+	from := fs.String("from", "", "The tool the database was migrated with: golang-migrate or flyway")
+	fromTable := fs.String("from-table", "", "The other tool's tracking table, if not its default")
+	common.parse(fs, args)
+
+	dbPool, err := common.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer dbPool.Close()
+
+	migrator := pgmigrate.New(dbPool, common.options()...)
+	switch *from {
+	case "golang-migrate":
+		return migrator.ImportGolangMigrate(ctx, *fromTable)
+	case "flyway":
+		return migrator.ImportFlyway(ctx, *fromTable)
+	}
+	return fmt.Errorf("import requires -from golang-migrate or -from flyway")
+}
+
+func runSeed(ctx context.Context, args []string) error {
+	fs, common := newFlagSet("seed")
+	environment := fs.String("env", "", "The environment being seeded, for files restricted to some environments")
+	common.parse(fs, args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("seed requires exactly one directory")
+	}
+
+	dbPool, err := common.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer dbPool.Close()
+
+	return pgmigrate.New(dbPool, common.options(pgmigrate.WithEnvironment(*environment))...).Seed(ctx, fs.Arg(0))
+}
+
+func runCreate(ctx context.Context, args []string) error {
+	fs, common := newFlagSet("create")
+	headerFile := fs.String("header", "", "A text/template file to use as the header of each new file")
+	common.parse(fs, args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("create requires exactly one name")
+	}
+
+	header := ""
+	if *headerFile != "" {
+		headerBytes, err := ioutil.ReadFile(*headerFile)
+		if err != nil {
+			return err
+		}
+		header = string(headerBytes)
+	}
+
+	filenames, err := pgmigrate.CreateWithScheme(*common.migrationsDir, fs.Arg(0), header, common.scheme())
+	if err != nil {
+		return err
+	}
+	for _, filename := range filenames {
+		fmt.Println(filename)
+	}
+	return nil
+}
+
+func runExport(ctx context.Context, args []string) error {
+	fs, common := newFlagSet("export")
+	layoutName := fs.String("layout", "golang-migrate", "The file names to write: golang-migrate or pgmigrate")
+	common.parse(fs, args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("export requires exactly one directory")
+	}
+
+	var layout pgmigrate.Layout
+	switch *layoutName {
+	case "golang-migrate":
+		layout = pgmigrate.GolangMigrateLayout
+	case "pgmigrate":
+		layout = pgmigrate.PgmigrateLayout
+	default:
+		return fmt.Errorf("-layout must be golang-migrate or pgmigrate, got %q", *layoutName)
+	}
+
+	if err := os.MkdirAll(fs.Arg(0), 0755); err != nil {
+		return err
+	}
+	filenames, err := pgmigrate.Export(common.source(), fs.Arg(0), layout)
+	if err != nil {
+		return err
+	}
+	for _, filename := range filenames {
+		fmt.Println(filename)
+	}
+	return nil
+}
+
+func runSquash(ctx context.Context, args []string) error {
+	fs, common := newFlagSet("squash")
+	through := fs.Int("through", 0, "The last version to squash")
+	common.parse(fs, args)
+
+	if *through < 1 {
+		return fmt.Errorf("squash requires -through")
+	}
+
+	filenames, err := pgmigrate.Squash(*common.migrationsDir, *through, pgmigrate.WithVersionScheme(common.scheme()))
+	if err != nil {
+		return err
+	}
+	for _, filename := range filenames {
+		fmt.Println(filename)
+	}
+	return nil
+}
+
+func runLock(ctx context.Context, args []string) error {
+	fs, common := newFlagSet("lock")
+	check := fs.Bool("check", false, "Check the migrations match the lock file instead of writing it")
+	common.parse(fs, args)
+
+	source := common.source()
+	if *check {
+		lockFile, err := source.ReadFile(pgmigrate.LockFileName)
+		if err != nil {
+			return err
+		}
+		return pgmigrate.VerifyLockFile(source, lockFile)
+	}
+
+	out, err := os.Create(filepath.Join(*common.migrationsDir, pgmigrate.LockFileName))
+	if err != nil {
+		return err
+	}
+	if err := pgmigrate.WriteLockFile(source, out); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func runLint(ctx context.Context, args []string) error {
+	fs, common := newFlagSet("lint")
+	serverVersion := fs.Int("server-version", 0, "The major version of the server the migrations will run on (0 = current)")
+	common.parse(fs, args)
+
+	findings, err := pgmigrate.Lint(common.source(), *serverVersion)
+	if err != nil {
+		return err
+	}
+	for _, finding := range findings {
+		fmt.Println(finding.String())
+	}
+	if len(findings) > 0 {
+		return fmt.Errorf("lint failed, see the warnings above")
+	}
+	return nil
+}
+
+func runValidate(ctx context.Context, args []string) error {
+	fs, common := newFlagSet("validate")
+	common.parse(fs, args)
+
+	problems := pgmigrate.New(nil, common.options()...).Problems()
+	if common.jsonOutput() {
+		messages := make([]string, 0, len(problems))
+		for _, problem := range problems {
+			messages = append(messages, problem.Error())
+		}
+		if err := printJSON(os.Stdout, map[string][]string{"problems": messages}); err != nil {
+			return err
+		}
+	} else {
+		for _, problem := range problems {
+			fmt.Println(problem.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("validate found %d problems, see above", len(problems))
+	}
+	return nil
+}
+
+func runPlan(ctx context.Context, args []string) error {
+	fs, common := newFlagSet("plan")
+	targetVersion := fs.Int("target", -1, "The target version. (-1 = latest)")
+	outFile := fs.String("o", "", "Write the plan to this file instead of stdout")
+	allowDown := fs.Bool("allow-down", false, "Allow a target below the current version, which runs down files")
+	common.parse(fs, args)
+
+	dbPool, err := common.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer dbPool.Close()
+
+	// The JSON plan lists the files, for scripts to inspect, and can't be
+	// applied
+	migrator := pgmigrate.New(dbPool, common.options(pgmigrate.WithAllowDown(*allowDown))...)
+	writePlan := func(w io.Writer) error {
+		if !common.jsonOutput() {
+			return migrator.WritePlan(ctx, *targetVersion, w)
+		}
+		plan, err := migrator.Plan(ctx, *targetVersion)
+		if err != nil {
+			return err
+		}
+		return printJSON(w, plan)
+	}
+	if *outFile == "" {
+		return writePlan(os.Stdout)
+	}
+
+	out, err := os.Create(*outFile)
+	if err != nil {
+		return err
+	}
+	if err := writePlan(out); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func runApply(ctx context.Context, args []string) error {
+	fs, common := newFlagSet("apply")
+	allowDown := fs.Bool("allow-down", false, "Allow a plan down, which runs down files")
+	common.parse(fs, args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("apply requires exactly one plan file")
+	}
+
+	script, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	dbPool, err := common.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer dbPool.Close()
+
+	return pgmigrate.New(dbPool, common.options(pgmigrate.WithAllowDown(*allowDown))...).ApplyPlan(ctx, script)
+}
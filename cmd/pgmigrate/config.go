@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is read, if it exists, when -config isn't given
+const defaultConfigFile = "pgmigrate.yaml"
+
+// parse parses the flags in args, then fills in those they don't set from
+// the config file
+func (cf commonFlags) parse(fs *flag.FlagSet, args []string) {
+	fs.Parse(args) //nolint: errcheck
+	if err := cf.applyConfig(fs); err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+// applyConfig sets the flags which weren't given from the config file. Its
+// settings are named after the flags, as in
+//
+//	migrations: ./db/migrations
+//	table: app._migrate_
+//	lock-timeout: 5s
+//	var:
+//	  APP_ROLE: app
+//	environments:
+//	  production:
+//	    postgres-env: PRODUCTION_DATABASE_URL
+//	    policy: destructive
+//
+// with the settings of the -environment overriding the top level, those in
+// maps a key at a time, and postgres-env naming the environment variable
+// holding the database URL. Settings for flags the command doesn't take are
+// skipped.
+func (cf commonFlags) applyConfig(fs *flag.FlagSet) error {
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	filename := *cf.configFile
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) && !given["config"] {
+		if *cf.environment != "" {
+			return fmt.Errorf("-environment %s needs a config file, and there is no %s", *cf.environment, filename)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	settings := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &settings); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	environments := map[string]map[string]interface{}{}
+	if value, ok := settings["environments"]; ok {
+		encoded, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if err := yaml.Unmarshal(encoded, &environments); err != nil {
+			return fmt.Errorf("%s: environments: %w", filename, err)
+		}
+		delete(settings, "environments")
+	}
+	if *cf.environment != "" {
+		environment, ok := environments[*cf.environment]
+		if !ok {
+			return fmt.Errorf("%s has no environment %s", filename, *cf.environment)
+		}
+		for name, value := range environment {
+			// Maps, such as var, are merged a key at a time
+			overrides, isMap := value.(map[string]interface{})
+			base, baseIsMap := settings[name].(map[string]interface{})
+			if isMap && baseIsMap {
+				merged := map[string]interface{}{}
+				for key, item := range base {
+					merged[key] = item
+				}
+				for key, item := range overrides {
+					merged[key] = item
+				}
+				value = merged
+			}
+			settings[name] = value
+		}
+	}
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := settings[name]
+		if name == "postgres-env" {
+			if given["postgres"] || given["postgres-file"] {
+				continue
+			}
+			// The variable is only needed by commands which connect
+			*cf.postgresEnv = fmt.Sprint(value)
+			pgURL := os.Getenv(*cf.postgresEnv)
+			if pgURL == "" {
+				continue
+			}
+			cf.pgURLs.Set(pgURL) //nolint: errcheck
+			continue
+		}
+		if name == "config" || name == "environment" {
+			return fmt.Errorf("%s: %s can only be given as a flag", filename, name)
+		}
+		if given[name] || fs.Lookup(name) == nil {
+			continue
+		}
+		for _, setting := range settingValues(value) {
+			if err := fs.Set(name, setting); err != nil {
+				return fmt.Errorf("%s: %s: %w", filename, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// settingValues are the flag values of a setting: a list sets a repeatable
+// flag once for each item, and a map, such as var, once for each NAME=VALUE
+func settingValues(value interface{}) []string {
+	switch value := value.(type) {
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		values := make([]string, 0, len(value))
+		for _, key := range keys {
+			values = append(values, fmt.Sprintf("%s=%v", key, value[key]))
+		}
+		return values
+	}
+	return []string{fmt.Sprint(value)}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pgmigrate-config")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "pgmigrate.yaml")
+	if err := ioutil.WriteFile(filename, []byte(`
+migrations: ./db/migrations
+table: app._migrate_
+var:
+  APP_ROLE: app
+  READ_ROLE: reader
+environments:
+  production:
+    table: prod._migrate_
+    var:
+      APP_ROLE: prod_app
+`), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	fs, cf := newFlagSet("up")
+	cf.parse(fs, []string{"-config", filename, "-environment", "production", "-migrations", "./given"})
+
+	if *cf.migrationsDir != "./given" {
+		t.Errorf("Expected the flag to win over the config file, got %q", *cf.migrationsDir)
+	}
+	if *cf.tableName != "prod._migrate_" {
+		t.Errorf("Expected the environment's table, got %q", *cf.tableName)
+	}
+	if got := strings.Join(*cf.variables, ","); got != "APP_ROLE=prod_app,READ_ROLE=reader" {
+		t.Errorf("Expected the environment's var to be merged with the top level, got %q", got)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// stringList collects a flag given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// databaseURLs are the -postgres flags followed by the lines of -postgres-file
+// which aren't blank or # comments. Without either, they are PGMIGRATE_URL,
+// or failing that the database the libpq environment variables describe.
+func (cf commonFlags) databaseURLs() ([]string, error) {
+	urls := append([]string{}, *cf.pgURLs...)
+	if *cf.pgURLFile == "" {
+		if len(urls) == 0 {
+			if pgURL := os.Getenv("PGMIGRATE_URL"); pgURL != "" {
+				return []string{pgURL}, nil
+			}
+			if dsn := environmentDSN(); dsn != "" {
+				return []string{dsn}, nil
+			}
+		}
+		return urls, nil
+	}
+
+	file, err := os.Open(*cf.pgURLFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls, scanner.Err()
+}
+
+// eachDatabase runs callback against each database, up to -concurrency at a
+// time. With more than one database, each result is printed, and the error
+// only says how many failed.
+func (cf commonFlags) eachDatabase(ctx context.Context, callback func(context.Context, *sql.DB) error) error {
+	urls, err := cf.databaseURLs()
+	if err != nil {
+		return err
+	}
+	if len(urls) < 2 {
+		dbPool, err := cf.connect(ctx)
+		if err != nil {
+			return err
+		}
+		defer dbPool.Close()
+		return callback(ctx, dbPool)
+	}
+
+	concurrency := *cf.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+	results := make([]error, len(urls))
+	wg := sync.WaitGroup{}
+	for idx, pgURL := range urls {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(idx int, pgURL string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			dbPool, err := cf.open(ctx, pgURL)
+			if err != nil {
+				results[idx] = err
+				return
+			}
+			defer dbPool.Close()
+			results[idx] = callback(ctx, dbPool)
+		}(idx, pgURL)
+	}
+	wg.Wait()
+
+	failed := 0
+	for idx, err := range results {
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "FAIL %s: %s\n", databaseName(urls[idx]), err.Error())
+		} else {
+			fmt.Fprintf(os.Stderr, "ok   %s\n", databaseName(urls[idx]))
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d databases failed", failed, len(urls))
+	}
+	return nil
+}
+
+// environmentVariables are the libpq variables which environmentDSN reads,
+// and the connection parameter each sets
+var environmentVariables = []struct{ name, param string }{
+	{"PGHOST", "host"},
+	{"PGHOSTADDR", "hostaddr"},
+	{"PGPORT", "port"},
+	{"PGDATABASE", "dbname"},
+	{"PGUSER", "user"},
+	{"PGSSLMODE", "sslmode"},
+}
+
+// environmentDSN is a connection string for the database described by the
+// libpq environment variables, as psql would connect to, or empty if none of
+// them are set. PGPASSWORD is left out, so the string can be printed, as
+// lib/pq reads it, along with the rest, from the environment itself.
+func environmentDSN() string {
+	params := []string{}
+	for _, variable := range environmentVariables {
+		if value := os.Getenv(variable.name); value != "" {
+			params = append(params, variable.param+"="+quoteParam(value))
+		}
+	}
+	if len(params) == 0 && os.Getenv("PGPASSWORD") != "" {
+		params = append(params, "host=localhost")
+	}
+	return strings.Join(params, " ")
+}
+
+// quoteParam quotes a connection string value if it needs it
+func quoteParam(value string) string {
+	if !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// databaseName identifies a database in output without its password
+func databaseName(pgURL string) string {
+	if parsed, err := url.Parse(pgURL); err == nil && parsed.Host != "" {
+		return parsed.Redacted()
+	}
+	fields := []string{}
+	for _, field := range strings.Fields(pgURL) {
+		if !strings.HasPrefix(field, "password=") {
+			fields = append(fields, field)
+		}
+	}
+	return strings.Join(fields, " ")
+}
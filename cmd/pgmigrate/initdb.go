@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	"gopkg.daemonl.com/pgmigrate"
+)
+
+// baselineDown stops the generated baseline from being reverted, as there is
+// no sensible way to undo an entire schema
+const baselineDown = `DO $$ BEGIN RAISE EXCEPTION 'the baseline migration can''t be reverted'; END $$;
+`
+
+func runInitFromDB(ctx context.Context, args []string) error {
+	fs, common := newFlagSet("init-from-db")
+	pgDump := fs.String("pg-dump", "pg_dump", "The pg_dump executable, which should be at least the server's version")
+	name := fs.String("name", "baseline", "The name of the generated migration")
+	baseline := fs.Bool("baseline", false, "Also record the generated migration as applied to the database")
+	common.parse(fs, args)
+
+	urls, err := common.databaseURLs()
+	if err != nil {
+		return err
+	}
+	if len(urls) != 1 {
+		return fmt.Errorf("init-from-db requires exactly one -postgres")
+	}
+
+	existing, err := pgmigrate.DirSource(*common.migrationsDir).List()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("%s already has migrations, init-from-db only starts a new directory", *common.migrationsDir)
+	}
+
+	// pgmigrate's own tables are left out, as would be any from a previous
+	// tool the database is moving from
+	dumpArgs := []string{"--schema-only", "--no-owner", "--no-privileges", "--exclude-table", *common.tableName + "*"}
+	if *common.schema != "" {
+		dumpArgs = append(dumpArgs, "--schema", *common.schema)
+	}
+	dumpArgs = append(dumpArgs, urls[0])
+
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, *pgDump, dumpArgs...)
+	cmd.Stderr = stderr
+	schema, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("running %s: %w: %s", *pgDump, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	filenames, err := pgmigrate.CreateWithScheme(*common.migrationsDir, *name, "", common.scheme())
+	if err != nil {
+		return err
+	}
+	upFile, downFile := filenames[0], filenames[1]
+	if err := ioutil.WriteFile(upFile, schema, 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(downFile, []byte(baselineDown), 0644); err != nil {
+		return err
+	}
+	for _, filename := range filenames {
+		fmt.Println(filename)
+	}
+
+	if !*baseline {
+		return nil
+	}
+	dbPool, err := common.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer dbPool.Close()
+
+	migrations, err := pgmigrate.DirSource(*common.migrationsDir).List()
+	if err != nil {
+		return err
+	}
+	return pgmigrate.New(dbPool, common.options()...).Baseline(ctx, migrations[0].Version)
+}
@@ -3,37 +3,568 @@ package main
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/lib/pq"
 	"gopkg.daemonl.com/pgmigrate"
+	"gopkg.daemonl.com/pgmigrate/gitsource"
+	"gopkg.daemonl.com/pgmigrate/httpsource"
+	"gopkg.daemonl.com/pgmigrate/sigverify"
 )
 
+type command struct {
+	usage   string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{
+	"up": {
+		usage:   "up [-target N] [-allow-down] [-modules] [-schemas A,B] [-schemas-like P] [-tenants-query Q] [-lint] [-shadow] [-dry-run] [N]",
+		summary: "Apply the next N migrations, or up to the target version, or the latest",
+		run:     runUp,
+	},
+	"down": {
+		usage:   "down -allow-down [-dry-run] [N]",
+		summary: "Revert the last N migrations (default 1)",
+		run:     runDown,
+	},
+	"status": {
+		usage:   "status [-json]",
+		summary: "List migrations and whether they have been applied",
+		run:     runStatus,
+	},
+	"create": {
+		usage:   "create [-header F] <name>",
+		summary: "Create the next numbered pair of migration files",
+		run:     runCreate,
+	},
+	"lint": {
+		usage:   "lint [-server-version N]",
+		summary: "Report statements which take heavy locks or rewrite tables",
+		run:     runLint,
+	},
+	"validate": {
+		usage:   "validate",
+		summary: "Report every missing, orphaned or misnumbered migration file",
+		run:     runValidate,
+	},
+	"lock": {
+		usage:   "lock [-check]",
+		summary: "Write the checksums of the migrations to pgmigrate.lock, or check them",
+		run:     runLock,
+	},
+	"plan": {
+		usage:   "plan [-target N] [-allow-down] [-o F]",
+		summary: "Write the SQL script which would migrate to the target",
+		run:     runPlan,
+	},
+	"apply": {
+		usage:   "apply [-allow-down] <plan.sql>",
+		summary: "Run a script written by plan",
+		run:     runApply,
+	},
+	"baseline": {
+		usage:   "baseline -version N",
+		summary: "Adopt an existing database at version N without running anything",
+		run:     runBaseline,
+	},
+	"import": {
+		usage:   "import -from golang-migrate|flyway [-from-table T]",
+		summary: "Adopt a database at the version recorded by another migration tool",
+		run:     runImport,
+	},
+	"export": {
+		usage:   "export [-layout golang-migrate|pgmigrate] <dir>",
+		summary: "Copy the migrations into dir, named in another tool's layout",
+		run:     runExport,
+	},
+	"init-from-db": {
+		usage:   "init-from-db [-baseline] [-pg-dump F]",
+		summary: "Write the schema of an existing database as the first migration",
+		run:     runInitFromDB,
+	},
+	"squash": {
+		usage:   "squash -through N",
+		summary: "Replace the migrations up to N with a single squashed migration",
+		run:     runSquash,
+	},
+	"resume": {
+		usage:   "resume [-allow-down]",
+		summary: "Continue the last run, which failed, to the version it was migrating to",
+		run:     runResume,
+	},
+	"seed": {
+		usage:   "seed [-env NAME] <dir>",
+		summary: "Run the seed files in dir which have changed since they last ran",
+		run:     runSeed,
+	},
+	"version": {
+		usage:   "version",
+		summary: "Print the current version of the database",
+		run:     runVersion,
+	},
+}
+
 func main() {
-	pgURL := flag.String("postgres", "", "The Postgres URL")
-	targetVersion := flag.Int("target", -1, "The target version. (-1 = latest)")
-	migrationsDir := flag.String("migrations", "./migrations", "The migrations source")
-	flag.Parse()
+	args := os.Args[1:]
+
+	// An interrupt cancels the run, rolling back the migration in progress,
+	// and a second one kills the process straight away
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// Without a subcommand, behave as the original flag only interface did
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		err := runUp(ctx, args)
+		closeAuthProvider()
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		printUsage()
+		os.Exit(2)
+	}
+
+	err := cmd.run(ctx, args[1:])
+	closeAuthProvider()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: pgmigrate <command> [flags]\n\nCommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-26s %s\n", commands[name].usage, commands[name].summary)
+	}
+}
+
+// commonFlags are shared by every subcommand
+type commonFlags struct {
+	pgURLs         *stringList
+	pgURLFile      *string
+	concurrency    *int
+	migrationsDir  *string
+	tableName      *string
+	versions       *string
+	outOfOrder     *bool
+	forwardOnly    *bool
+	gitRef         *string
+	recursive      *bool
+	module         *string
+	schema         *string
+	preScripts     *string
+	postScripts    *string
+	variables      *stringList
+	envVariables   *string
+	minisignKey    *string
+	pgpKeyRing     *string
+	policy         *string
+	overridePolicy *bool
+	wait           *bool
+	waitTimeout    *time.Duration
+	timeout        *time.Duration
+	lockTimeout    *time.Duration
+	lockRetries    *int
+	reportBlockers *time.Duration
+	idleBlockers   *time.Duration
+	terminateIdle  *bool
+	singleTx       *bool
+	split          *bool
+	notifyURL      *string
+	strict         *bool
+	output         *string
+	verbose        *bool
+	debug          *bool
+	quiet          *bool
+	passwordPrompt *bool
+	configFile     *string
+	environment    *string
+	postgresEnv    *string
+	auth           *string
+	cloudSQL       *string
+	vaultRole      *string
+	vaultMount     *string
+}
+
+func newFlagSet(name string) (*flag.FlagSet, commonFlags) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	pgURLs := &stringList{}
+	fs.Var(pgURLs, "postgres", "The Postgres URL, which up and down accept more than once (default $PGMIGRATE_URL, or $PGHOST and the other libpq variables)")
+	variables := &stringList{}
+	fs.Var(variables, "var", "A NAME=VALUE to substitute for ${NAME} in migration files, any number of times")
+	return fs, commonFlags{
+		pgURLs:         pgURLs,
+		pgURLFile:      fs.String("postgres-file", "", "A file of Postgres URLs, one per line, for up and down to migrate each of"),
+		concurrency:    fs.Int("concurrency", 1, "How many databases up and down migrate at once"),
+		migrationsDir:  fs.String("migrations", "./migrations", "The migrations directory, archive, or URL of a manifest"),
+		tableName:      fs.String("table", "_migrate_", "The version tracking table, as table or schema.table"),
+		versions:       fs.String("versions", "sequential", "The version numbering scheme, sequential or timestamp"),
+		outOfOrder:     fs.Bool("allow-out-of-order", false, "Apply migrations older than the current version which were never applied"),
+		forwardOnly:    fs.Bool("forward-only", false, "Allow migrations without down files"),
+		gitRef:         fs.String("git-ref", "", "Read the migrations directory as of this git ref of the current repository"),
+		recursive:      fs.Bool("recursive", false, "Read migrations from subdirectories of the migrations directory too"),
+		module:         fs.String("module", "", "Track the migrations as this module, with its own version"),
+		schema:         fs.String("schema", "", "Run in this schema, with its own version, for schema per tenant databases"),
+		preScripts:     fs.String("pre", "", "A directory of .sql scripts to run before every migration run"),
+		postScripts:    fs.String("post", "", "A directory of .sql scripts to run after every migration run"),
+		variables:      variables,
+		envVariables:   fs.String("env-vars", "", "Comma separated environment variables to substitute for ${NAME} in migration files"),
+		minisignKey:    fs.String("minisign-key", "", "Require every migration file to have a .minisig signature by this minisign public key file"),
+		pgpKeyRing:     fs.String("pgp-keyring", "", "Require every migration file to have a .sig signature by a key in this OpenPGP key ring file"),
+		policy:         fs.String("policy", "", "Deny up migrations matching the rules in this file, or destructive for the built in rules"),
+		overridePolicy: fs.Bool("override-policy", false, "Run migrations the -policy denies"),
+		wait:           fs.Bool("wait", false, "Retry connecting, backing off, until the database is ready"),
+		waitTimeout:    fs.Duration("wait-timeout", time.Minute, "How long -wait retries connecting for"),
+		timeout:        fs.Duration("migration-timeout", 0, "Fail any migration which runs for longer than this (0 = no limit)"),
+		lockTimeout:    fs.Duration("lock-timeout", 0, "The lock_timeout of migrations which don't set their own (0 = the server's)"),
+		lockRetries:    fs.Int("lock-retries", 0, "How many times to retry a migration which hits -lock-timeout"),
+		reportBlockers: fs.Duration("report-blockers", 0, "Log the sessions blocking a migration which has run for this long (0 = never)"),
+		idleBlockers:   fs.Duration("idle-blockers", 0, "List sessions blocking a migration which have been idle in a transaction for this long (0 = never)"),
+		terminateIdle:  fs.Bool("terminate-idle-blockers", false, "Terminate the sessions -idle-blockers lists"),
+		singleTx:       fs.Bool("single-transaction", false, "Run every migration in one transaction, rolling them all back if any fails"),
+		split:          fs.Bool("split-statements", false, "Send migration files a statement at a time, as poolers like pgbouncer need"),
+		strict:         fs.Bool("strict", false, "Fail when a file starting with a version isn't named like a migration"),
+		notifyURL:      fs.String("notify-url", "", "Post a JSON summary of each run to this webhook, such as a Slack incoming webhook"),
+		output:         fs.String("output", "text", "Print results as text, or as json for scripts"),
+		verbose:        fs.Bool("v", false, "Log every step of each migration, not just a summary"),
+		debug:          fs.Bool("vv", false, "Log every step and the SQL of each migration"),
+		quiet:          fs.Bool("quiet", false, "Log only errors"),
+		passwordPrompt: fs.Bool("password-prompt", false, "Prompt for the database password before connecting"),
+		configFile:     fs.String("config", defaultConfigFile, "A YAML file of settings for the flags which aren't given"),
+		environment:    fs.String("environment", os.Getenv("PGMIGRATE_ENV"), "Use this environment's settings from the config file (default $PGMIGRATE_ENV)"),
+		postgresEnv:    new(string),
+		auth:           fs.String("auth", "password", "How to authenticate: password, rds-iam for an RDS IAM token on each connection, azure-ad for an Entra ID token, vault for credentials from -vault-role, or cloudsql-iam with -cloudsql-instance"),
+		cloudSQL:       fs.String("cloudsql-instance", "", "Connect to this Cloud SQL instance, as project:region:instance, through the Cloud SQL Go connector"),
+		vaultRole:      fs.String("vault-role", "", "The role of Vault's database secrets engine -auth vault gets credentials for"),
+		vaultMount:     fs.String("vault-mount", "database", "Where Vault's database secrets engine is mounted"),
+	}
+}
+
+// options configures a Migrator from the flags, followed by extra
+func (cf commonFlags) options(extra ...pgmigrate.Option) []pgmigrate.Option {
+	source := cf.source()
+	opts := []pgmigrate.Option{
+		pgmigrate.WithSource(source),
+		pgmigrate.WithTableName(*cf.tableName),
+		pgmigrate.WithVersionScheme(cf.scheme()),
+		pgmigrate.WithAllowOutOfOrder(*cf.outOfOrder),
+		pgmigrate.WithForwardOnly(*cf.forwardOnly),
+		pgmigrate.WithModule(*cf.module),
+		pgmigrate.WithSchema(*cf.schema),
+		pgmigrate.WithPreScripts(*cf.preScripts),
+		pgmigrate.WithPostScripts(*cf.postScripts),
+		pgmigrate.WithSingleTransaction(*cf.singleTx),
+		pgmigrate.WithSplitStatements(*cf.split),
+		pgmigrate.WithStrict(*cf.strict),
+		pgmigrate.WithLogLevel(cf.logLevel()),
+	}
+	if verifier := cf.signatureVerifier(); verifier != nil {
+		opts = append(opts, pgmigrate.WithSignatures(verifier))
+	}
+	if *cf.timeout > 0 {
+		opts = append(opts, pgmigrate.WithMigrationTimeout(*cf.timeout))
+	}
+	if *cf.lockTimeout > 0 {
+		opts = append(opts, pgmigrate.WithLockTimeout(*cf.lockTimeout, *cf.lockRetries))
+	}
+	if *cf.reportBlockers > 0 {
+		opts = append(opts, pgmigrate.WithBlockerReport(*cf.reportBlockers))
+	}
+	if *cf.terminateIdle && *cf.idleBlockers <= 0 {
+		log.Fatal("-terminate-idle-blockers requires -idle-blockers")
+	}
+	if *cf.idleBlockers > 0 {
+		opts = append(opts, pgmigrate.WithIdleBlockers(*cf.idleBlockers, *cf.terminateIdle))
+	}
+	runNotifiers := notifiers{}
+	if *cf.notifyURL != "" {
+		runNotifiers = append(runNotifiers, pgmigrate.Webhook{URL: *cf.notifyURL})
+	}
+	if cf.jsonOutput() {
+		runNotifiers = append(runNotifiers, stdoutReports)
+	}
+	if len(runNotifiers) > 0 {
+		opts = append(opts, pgmigrate.WithNotifier(runNotifiers))
+	}
+	if *cf.policy != "" {
+		opts = append(opts, pgmigrate.WithPolicy(cf.policyRules()...), pgmigrate.WithPolicyOverride(*cf.overridePolicy))
+	}
+	// Runs are checked against the lock file whenever there is one
+	if _, err := source.ReadFile(pgmigrate.LockFileName); err == nil {
+		opts = append(opts, pgmigrate.WithLockFile(pgmigrate.LockFileName))
+	}
+	if *cf.envVariables != "" {
+		opts = append(opts, pgmigrate.WithEnvVariables(strings.Split(*cf.envVariables, ",")...))
+	}
+	if len(*cf.variables) > 0 {
+		opts = append(opts, pgmigrate.WithVariables(cf.variableMap()))
+	}
+	return append(opts, extra...)
+}
+
+// logLevel is set by -quiet, -v or -vv
+func (cf commonFlags) logLevel() pgmigrate.LogLevel {
+	if *cf.quiet && (*cf.verbose || *cf.debug) {
+		log.Fatal("use only one of -quiet, -v and -vv")
+	}
+	switch {
+	case *cf.quiet:
+		return pgmigrate.LogQuiet
+	case *cf.debug:
+		return pgmigrate.LogDebug
+	case *cf.verbose:
+		return pgmigrate.LogVerbose
+	}
+	return pgmigrate.LogSummary
+}
 
-	if *pgURL == "" {
-		log.Fatal("Requires postgres flag")
+// signatureVerifier checks signatures by the -minisign-key or -pgp-keyring
+func (cf commonFlags) signatureVerifier() pgmigrate.SignatureVerifier {
+	if *cf.minisignKey != "" && *cf.pgpKeyRing != "" {
+		log.Fatal("use only one of -minisign-key and -pgp-keyring")
 	}
+	if *cf.minisignKey != "" {
+		publicKey, err := ioutil.ReadFile(*cf.minisignKey)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		verifier, err := sigverify.Minisign(string(publicKey))
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		return verifier
+	}
+	if *cf.pgpKeyRing != "" {
+		keyRing, err := ioutil.ReadFile(*cf.pgpKeyRing)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		verifier, err := sigverify.OpenPGP(keyRing)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		return verifier
+	}
+	return nil
+}
 
-	if err := do(*pgURL, *migrationsDir, *targetVersion); err != nil {
+// policyRules reads the -policy file, or the built in destructive rules
+func (cf commonFlags) policyRules() []pgmigrate.PolicyRule {
+	if *cf.policy == "destructive" {
+		return pgmigrate.DestructivePolicy
+	}
+	file, err := os.Open(*cf.policy)
+	if err != nil {
 		log.Fatal(err.Error())
 	}
+	defer file.Close()
+	rules, err := pgmigrate.ReadPolicy(file)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	return rules
+}
+
+// variableMap parses the -var flags
+func (cf commonFlags) variableMap() map[string]string {
+	vars := map[string]string{}
+	for _, variable := range *cf.variables {
+		idx := strings.Index(variable, "=")
+		if idx < 1 {
+			log.Fatalf("-var takes NAME=VALUE, got %q", variable)
+		}
+		vars[variable[:idx]] = variable[idx+1:]
+	}
+	return vars
 }
 
-func do(pgURL string, migrationsDir string, targetVersion int) error {
+// source reads from the -migrations directory, optionally at a git ref, the
+// manifest at an http(s) URL, or an archive, as in release.zip or
+// release.tar.gz/db/migrations
+func (cf commonFlags) source() pgmigrate.MigrationSource {
+	location := *cf.migrationsDir
+	if archive, dir, ok := splitArchivePath(location); ok {
+		source, err := pgmigrate.OpenArchive(archive, dir)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		return source
+	}
+	if *cf.gitRef != "" {
+		source, err := gitsource.Open(".", *cf.gitRef, location)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		return source
+	}
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		source, err := httpsource.New(context.Background(), nil, location)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		return source
+	}
+	if *cf.recursive {
+		return pgmigrate.RecursiveDirSource(location)
+	}
+	return pgmigrate.DirSource(location)
+}
+
+// splitArchivePath finds an archive in location, returning it and the
+// directory within it
+func splitArchivePath(location string) (string, string, bool) {
+	parts := strings.Split(location, "/")
+	for idx := range parts {
+		archive := strings.Join(parts[:idx+1], "/")
+		if pgmigrate.IsArchive(archive) {
+			return archive, strings.Join(parts[idx+1:], "/"), true
+		}
+	}
+	return "", "", false
+}
+
+func (cf commonFlags) scheme() pgmigrate.VersionScheme {
+	switch *cf.versions {
+	case "sequential":
+		return pgmigrate.SequentialVersions
+	case "timestamp":
+		return pgmigrate.TimestampVersions
+	}
+	log.Fatalf("Unknown version scheme %q, use sequential or timestamp", *cf.versions)
+	return pgmigrate.SequentialVersions
+}
 
-	ctx := context.Background()
-	dbPool, err := sql.Open("postgres", pgURL)
+func (cf commonFlags) connect(ctx context.Context) (*sql.DB, error) {
+	urls, err := cf.databaseURLs()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if err := dbPool.Ping(); err != nil {
-		return err
+	if len(urls) == 0 && *cf.cloudSQL != "" {
+		// The user and database can come from the environment
+		urls = []string{""}
 	}
+	if len(urls) == 0 && *cf.postgresEnv != "" {
+		return nil, fmt.Errorf("Requires postgres flag, or %s to be set, as the config file's postgres-env", *cf.postgresEnv)
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("Requires postgres flag, PGMIGRATE_URL or libpq environment variables such as PGHOST")
+	}
+	if len(urls) > 1 {
+		return nil, fmt.Errorf("Only up and down take more than one database")
+	}
+	dbPool, err := cf.open(ctx, urls[0])
+	if err != nil && *cf.auth == "password" && !*cf.passwordPrompt && sqlState(err) == "28P01" && canPrompt(urls[0]) {
+		// A database which wants a password that wasn't given, from a
+		// terminal, prompts for one as psql would
+		password, promptErr := promptedPassword(urls[0])
+		if promptErr != nil {
+			return nil, promptErr
+		}
+		return cf.open(ctx, withPassword(urls[0], password))
+	}
+	return dbPool, err
+}
 
-	return pgmigrate.MigrateDatabase(ctx, dbPool, migrationsDir, targetVersion)
+// open connects to pgURL, waiting for it to be ready with -wait, and with a
+// password from the terminal with -password-prompt or the credentials of
+// -auth
+func (cf commonFlags) open(ctx context.Context, pgURL string) (*sql.DB, error) {
+	if *cf.passwordPrompt {
+		password, err := promptedPassword(pgURL)
+		if err != nil {
+			return nil, err
+		}
+		pgURL = withPassword(pgURL, password)
+	}
+	connector, err := cf.connector(ctx, pgURL)
+	if err != nil {
+		return nil, err
+	}
+	if !*cf.wait {
+		return openDatabase(ctx, connector)
+	}
+	return waitForDatabase(ctx, databaseName(pgURL), connector, *cf.waitTimeout)
 }
+
+// noticeRelays holds the relay for the server notices of each pool opened by
+// openDatabase
+var noticeRelays sync.Map
+
+// openDatabase opens a pool of connections from connector, checking it can
+// connect
+func openDatabase(ctx context.Context, connector driver.Connector) (*sql.DB, error) {
+	relay := &pgmigrate.NoticeRelay{}
+	dbPool := sql.OpenDB(pq.ConnectorWithNoticeHandler(connector, func(notice *pq.Error) {
+		relay.Notice(notice.Severity, notice.Message)
+	}))
+	if err := dbPool.PingContext(ctx); err != nil {
+		dbPool.Close()
+		return nil, err
+	}
+	noticeRelays.Store(dbPool, relay)
+	return dbPool, nil
+}
+
+// noticeOption logs the server notices of dbPool, such as RAISE NOTICE in a
+// migration
+func noticeOption(dbPool *sql.DB) pgmigrate.Option {
+	relay, _ := noticeRelays.Load(dbPool)
+	notices, _ := relay.(*pgmigrate.NoticeRelay)
+	return pgmigrate.WithNotices(notices)
+}
+
+// waitForDatabase retries openDatabase, doubling the delay between tries up
+// to maxWaitDelay, until it connects or timeout has passed
+func waitForDatabase(ctx context.Context, name string, connector driver.Connector, timeout time.Duration) (*sql.DB, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	delay := minWaitDelay
+	for {
+		dbPool, err := openDatabase(ctx, connector)
+		if err == nil {
+			return dbPool, nil
+		}
+		log.Printf("waiting for %s: %s", name, err.Error())
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("database not ready after %s: %w", timeout, err)
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxWaitDelay {
+			delay = maxWaitDelay
+		}
+	}
+}
+
+const (
+	minWaitDelay = 250 * time.Millisecond
+	maxWaitDelay = 10 * time.Second
+)
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"gopkg.daemonl.com/pgmigrate"
+)
+
+// jsonOutput is whether -output asks for JSON rather than text
+func (cf commonFlags) jsonOutput() bool {
+	switch *cf.output {
+	case "text":
+		return false
+	case "json":
+		return true
+	}
+	log.Fatalf("Unknown output %q, use text or json", *cf.output)
+	return false
+}
+
+// printJSON writes value to w as indented JSON
+func printJSON(w io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// jsonReports prints each run's report to stdout as a line of JSON, so that
+// runs against several databases print one line each
+type jsonReports struct {
+	lock sync.Mutex
+}
+
+// stdoutReports is shared by every database a command migrates
+var stdoutReports = &jsonReports{}
+
+func (reports *jsonReports) Notify(ctx context.Context, report pgmigrate.RunReport) error {
+	reports.lock.Lock()
+	defer reports.lock.Unlock()
+	return json.NewEncoder(os.Stdout).Encode(report)
+}
+
+// notifiers tells each of its notifiers about every run, returning the first
+// error
+type notifiers []pgmigrate.Notifier
+
+func (list notifiers) Notify(ctx context.Context, report pgmigrate.RunReport) error {
+	var firstErr error
+	for _, notifier := range list {
+		if err := notifier.Notify(ctx, report); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+	"golang.org/x/term"
+)
+
+var (
+	promptOnce     sync.Once
+	promptPassword string
+	promptErr      error
+)
+
+// promptedPassword reads a password from the terminal, once however many
+// databases it is used for
+func promptedPassword(pgURL string) (string, error) {
+	promptOnce.Do(func() {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			promptErr = fmt.Errorf("-password-prompt needs a terminal")
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Password for %s: ", databaseName(pgURL))
+		password, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		promptPassword, promptErr = string(password), err
+	})
+	return promptPassword, promptErr
+}
+
+// canPrompt is whether a failed connection to pgURL should prompt for a
+// password and try again, as psql does when none was given and it is run
+// from a terminal
+func canPrompt(pgURL string) bool {
+	return !hasPassword(pgURL) && os.Getenv("PGPASSWORD") == "" && term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// hasPassword is whether pgURL, as a URL or a key=value connection string,
+// sets a password
+func hasPassword(pgURL string) bool {
+	if parsed, err := url.Parse(pgURL); err == nil && parsed.Host != "" {
+		if _, ok := parsed.User.Password(); ok {
+			return true
+		}
+		return parsed.Query().Get("password") != ""
+	}
+	for _, field := range strings.Fields(pgURL) {
+		if strings.HasPrefix(field, "password=") {
+			return true
+		}
+	}
+	return false
+}
+
+// withPassword sets password in pgURL
+func withPassword(pgURL string, password string) string {
+	if parsed, err := url.Parse(pgURL); err == nil && parsed.Host != "" {
+		username := ""
+		if parsed.User != nil {
+			username = parsed.User.Username()
+		}
+		parsed.User = url.UserPassword(username, password)
+		return parsed.String()
+	}
+	return pgURL + " password=" + quoteParam(password)
+}
+
+// sqlState is the SQLSTATE code of a server error, or empty, such as 28P01
+// for a wrong or missing password
+func sqlState(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code)
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// passwordFile is where connections without a password look one up, as psql
+// does: PGPASSFILE, ~/.pgpass, or %APPDATA%\postgresql\pgpass.conf on
+// Windows
+func passwordFile() string {
+	if filename := os.Getenv("PGPASSFILE"); filename != "" {
+		return filename
+	}
+	if runtime.GOOS == "windows" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "postgresql", "pgpass.conf")
+		}
+		return ""
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".pgpass")
+}
+
+var checkPasswordFileOnce sync.Once
+
+// checkPasswordFile points lib/pq, which reads the password file itself, at
+// the Windows location it doesn't know, and warns, as psql does, when the
+// file is ignored for its permissions
+func checkPasswordFile() {
+	checkPasswordFileOnce.Do(func() {
+		filename := passwordFile()
+		if filename == "" {
+			return
+		}
+		info, err := os.Stat(filename)
+		if err != nil {
+			return
+		}
+		if runtime.GOOS == "windows" {
+			os.Setenv("PGPASSFILE", filename) //nolint: errcheck
+			return
+		}
+		if ignoresPasswordFile(info.Mode()) {
+			log.Printf("WARNING: password file %q has permissions %v, so it is ignored; permissions should be u=rw (0600) or less", filename, info.Mode().Perm())
+		}
+	})
+}
+
+// ignoresPasswordFile is lib/pq's own test of the password file's mode, which
+// is written in hex, so unlike psql's it rejects an executable file but not
+// a group executable one
+func ignoresPasswordFile(mode os.FileMode) bool {
+	return mode&0x77 != 0
+}
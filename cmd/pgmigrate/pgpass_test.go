@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestIgnoresPasswordFile(t *testing.T) {
+	for _, tc := range []struct {
+		mode    os.FileMode
+		ignored bool
+	}{
+		{0600, false},
+		{0400, false},
+		{0610, false},
+		{0640, true},
+		{0604, true},
+		{0700, true},
+	} {
+		if got := ignoresPasswordFile(tc.mode); got != tc.ignored {
+			t.Errorf("Mode %v: expected ignored=%v", tc.mode, tc.ignored)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package pgmigrate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const defaultVersionWidth = 3
+
+var reValidName = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9_.-]*$`)
+
+// CreateData is passed to the header template when creating migration files
+type CreateData struct {
+	Version   int
+	Name      string
+	Direction string
+}
+
+// Create writes the next numbered pair of empty migration files in dir,
+// returning their paths. Version numbers are zero padded to match the last
+// existing migration. If header is not empty it is rendered as a
+// text/template with CreateData and written at the top of each file.
+func Create(dir string, name string, header string) ([]string, error) {
+	return CreateWithScheme(dir, name, header, SequentialVersions)
+}
+
+// CreateWithScheme is Create, numbering the new files by scheme. With
+// TimestampVersions the version is the current UTC time.
+func CreateWithScheme(dir string, name string, header string, scheme VersionScheme) ([]string, error) {
+	if !reValidName.MatchString(name) {
+		return nil, fmt.Errorf("invalid migration name %q: use letters, numbers, -, _ and .", name)
+	}
+
+	headerTemplate, err := template.New("header").Parse(header)
+	if err != nil {
+		return nil, fmt.Errorf("parsing header template: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	migrations, err := DirSource(dir).List()
+	if err != nil {
+		return nil, err
+	}
+
+	last := 0
+	width := defaultVersionWidth
+	if len(migrations) > 0 {
+		last = migrations[len(migrations)-1].Version
+		width = versionWidth(migrations[len(migrations)-1])
+	}
+	next := scheme.next(last, time.Now())
+	if scheme == TimestampVersions {
+		width = len(timestampFormat)
+	}
+
+	filenames := []string{}
+	for _, direction := range []string{directionUp, directionDown} {
+		content := &bytes.Buffer{}
+		if err := headerTemplate.Execute(content, CreateData{
+			Version:   next,
+			Name:      name,
+			Direction: direction,
+		}); err != nil {
+			return nil, fmt.Errorf("rendering header template: %w", err)
+		}
+
+		filename := filepath.Join(dir, fmt.Sprintf("%0*d-%s.%s.sql", width, next, name, direction))
+		if err := writeNewFile(filename, content.Bytes()); err != nil {
+			return nil, err
+		}
+		filenames = append(filenames, filename)
+	}
+	return filenames, nil
+}
+
+// versionWidth is the number of digits used in the existing migration's
+// filename, including any zero padding
+func versionWidth(migration Migration) int {
+	filename := migration.UpFile
+	if filename == "" {
+		filename = migration.DownFile
+	}
+	prefix := strings.Split(path.Base(filename), ".")[0]
+	if idx := strings.IndexAny(prefix, "-_"); idx != -1 {
+		prefix = prefix[:idx]
+	}
+	if prefix == "" {
+		return defaultVersionWidth
+	}
+	return len(prefix)
+}
+
+func writeNewFile(filename string, content []byte) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,91 @@
+package pgmigrate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreate(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"0009-foo.up.sql":   s1u,
+		"0009-foo.down.sql": s1d,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	filenames, err := Create(migrateDir, "add_users", "-- {{ .Version }} {{ .Name }} {{ .Direction }}\n")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expect := []string{
+		filepath.Join(migrateDir, "0010-add_users.up.sql"),
+		filepath.Join(migrateDir, "0010-add_users.down.sql"),
+	}
+	if len(filenames) != len(expect) {
+		t.Fatalf("Expected %d files, got %v", len(expect), filenames)
+	}
+	for idx, filename := range expect {
+		if filenames[idx] != filename {
+			t.Errorf("Expected %s, got %s", filename, filenames[idx])
+		}
+	}
+
+	content, err := ioutil.ReadFile(expect[0])
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(content) != "-- 10 add_users up\n" {
+		t.Errorf("Wrong header %q", string(content))
+	}
+
+	if _, err := Create(migrateDir, "bad name", ""); err == nil {
+		t.Error("Expected an error for an invalid name")
+	}
+
+	if _, err := Create(migrateDir, "add-v1.2-endpoints", ""); err != nil {
+		t.Fatal(err.Error())
+	}
+	migrations, err := DirSource(migrateDir).List()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if last := migrations[len(migrations)-1]; last.Version != 11 || last.Name != "add-v1.2-endpoints" {
+		t.Errorf("Expected the dotted name to be read back, got %+v", last)
+	}
+}
+
+func TestCreateEmpty(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{})
+	defer os.RemoveAll(migrateDir)
+
+	filenames, err := Create(migrateDir, "first", "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if filepath.Base(filenames[0]) != "001-first.up.sql" {
+		t.Errorf("Expected default padding, got %s", filenames[0])
+	}
+}
+
+func TestCreateTimestamp(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{})
+	defer os.RemoveAll(migrateDir)
+
+	filenames, err := CreateWithScheme(migrateDir, "add_users", "", TimestampVersions)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	migrations, err := DirSource(migrateDir).List()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(filenames) != 2 || len(migrations) != 1 {
+		t.Fatalf("Expected one pair of files, got %v", filenames)
+	}
+	if err := TimestampVersions.validate(map[int]Migration{migrations[0].Version: migrations[0]}, 1, migrations[0].Version, false); err != nil {
+		t.Error(err.Error())
+	}
+}
@@ -0,0 +1,188 @@
+package pgmigrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// describeSchema lists the tables, views, sequences, columns, constraints,
+// indexes, triggers, types and functions of the session's current schema as
+// sorted text, one object per line, for comparing schemas. Relations whose
+// names start with exclude, the tracking tables, are left out. Names are
+// printed without the schema, so schemas with the same content describe the same.
+func describeSchema(ctx context.Context, conn Queryer, exclude string) (string, error) {
+	var schema string
+	if err := conn.QueryRowContext(ctx, `SELECT current_schema()`).Scan(&schema); err != nil {
+		return "", err
+	}
+	unqualify := strings.NewReplacer(quoteIdentifier(schema)+".", "", schema+".", "")
+
+	// relations holds the lines under each table, view or sequence
+	relations := map[string][]string{}
+	lines := []string{}
+
+	queries := []struct {
+		query  string
+		format func(fields []string)
+	}{{
+		query: `
+			SELECT c.relname, CASE c.relkind
+				WHEN 'r' THEN 'table' WHEN 'p' THEN 'table' WHEN 'f' THEN 'foreign table'
+				WHEN 'v' THEN 'view' WHEN 'm' THEN 'materialized view' ELSE 'sequence' END
+			FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p', 'f', 'v', 'm', 'S')
+				AND left(c.relname, length($1)) <> $1`,
+		format: func(fields []string) {
+			relations[fields[0]] = append(relations[fields[0]], fmt.Sprintf("%s %s", fields[1], fields[0]))
+		},
+	}, {
+		query: `
+			SELECT c.relname, a.attname, format_type(a.atttypid, a.atttypmod)
+				|| CASE WHEN a.attnotnull THEN ' not null' ELSE '' END
+				|| coalesce(' default ' || pg_get_expr(d.adbin, d.adrelid), '')
+			FROM pg_attribute a
+				JOIN pg_class c ON c.oid = a.attrelid
+				JOIN pg_namespace n ON n.oid = c.relnamespace
+				LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+			WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p', 'f', 'v', 'm')
+				AND a.attnum > 0 AND NOT a.attisdropped
+				AND left(c.relname, length($1)) <> $1
+			ORDER BY c.relname, a.attnum`,
+		format: func(fields []string) {
+			relations[fields[0]] = append(relations[fields[0]], fmt.Sprintf("  column %s %s", fields[1], unqualify.Replace(fields[2])))
+		},
+	}, {
+		query: `
+			SELECT c.relname, con.conname, pg_get_constraintdef(con.oid)
+			FROM pg_constraint con
+				JOIN pg_class c ON c.oid = con.conrelid
+				JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = current_schema() AND left(c.relname, length($1)) <> $1
+			ORDER BY c.relname, con.conname`,
+		format: func(fields []string) {
+			relations[fields[0]] = append(relations[fields[0]], fmt.Sprintf("  constraint %s %s", fields[1], unqualify.Replace(fields[2])))
+		},
+	}, {
+		query: `
+			SELECT c.relname, i.relname, pg_get_indexdef(i.oid)
+			FROM pg_index x
+				JOIN pg_class i ON i.oid = x.indexrelid
+				JOIN pg_class c ON c.oid = x.indrelid
+				JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = current_schema() AND left(c.relname, length($1)) <> $1
+			ORDER BY c.relname, i.relname`,
+		format: func(fields []string) {
+			relations[fields[0]] = append(relations[fields[0]], fmt.Sprintf("  index %s %s", fields[1], unqualify.Replace(fields[2])))
+		},
+	}, {
+		query: `
+			SELECT c.relname, t.tgname, pg_get_triggerdef(t.oid)
+			FROM pg_trigger t
+				JOIN pg_class c ON c.oid = t.tgrelid
+				JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = current_schema() AND NOT t.tgisinternal
+				AND left(c.relname, length($1)) <> $1
+			ORDER BY c.relname, t.tgname`,
+		format: func(fields []string) {
+			relations[fields[0]] = append(relations[fields[0]], fmt.Sprintf("  trigger %s %s", fields[1], unqualify.Replace(fields[2])))
+		},
+	}, {
+		query: `
+			SELECT c.relname, pg_get_viewdef(c.oid)
+			FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = current_schema() AND c.relkind IN ('v', 'm')
+				AND left(c.relname, length($1)) <> $1`,
+		format: func(fields []string) {
+			relations[fields[0]] = append(relations[fields[0]], fmt.Sprintf("  definition %s", strings.Join(strings.Fields(unqualify.Replace(fields[1])), " ")))
+		},
+	}, {
+		query: `
+			SELECT t.typname, CASE t.typtype
+				WHEN 'e' THEN 'enum (' || (SELECT string_agg(e.enumlabel, ', ' ORDER BY e.enumsortorder) FROM pg_enum e WHERE e.enumtypid = t.oid) || ')'
+				ELSE 'domain ' || format_type(t.typbasetype, t.typtypmod) END
+			FROM pg_type t JOIN pg_namespace n ON n.oid = t.typnamespace
+			WHERE n.nspname = current_schema() AND t.typtype IN ('e', 'd')
+				AND left(t.typname, length($1)) <> $1`,
+		format: func(fields []string) {
+			lines = append(lines, fmt.Sprintf("type %s %s", fields[0], unqualify.Replace(fields[1])))
+		},
+	}, {
+		query: `
+			SELECT p.proname, pg_get_function_identity_arguments(p.oid)
+			FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace
+			WHERE n.nspname = current_schema() AND left(p.proname, length($1)) <> $1`,
+		format: func(fields []string) {
+			lines = append(lines, fmt.Sprintf("function %s(%s)", fields[0], unqualify.Replace(fields[1])))
+		},
+	}}
+
+	for _, q := range queries {
+		if err := queryStrings(ctx, conn, q.query, exclude, q.format); err != nil {
+			return "", fmt.Errorf("describing schema %s: %w", schema, err)
+		}
+	}
+
+	for _, relationLines := range relations {
+		lines = append(lines, strings.Join(relationLines, "\n"))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// queryStrings calls callback with each row of query, scanned as strings
+func queryStrings(ctx context.Context, conn Queryer, query string, arg string, callback func([]string)) error {
+	rows, err := conn.QueryContext(ctx, query, arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		fields := make([]string, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for idx := range fields {
+			pointers[idx] = &fields[idx]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+		callback(fields)
+	}
+	return rows.Err()
+}
+
+// diffLines lists the lines of want missing from got with a -, and those of
+// got which aren't in want with a +
+func diffLines(want string, got string) string {
+	count := func(content string) map[string]int {
+		counts := map[string]int{}
+		for _, line := range strings.Split(content, "\n") {
+			counts[line]++
+		}
+		return counts
+	}
+	wantCounts, gotCounts := count(want), count(got)
+
+	diff := &strings.Builder{}
+	for _, line := range strings.Split(want, "\n") {
+		if gotCounts[line] > 0 {
+			gotCounts[line]--
+			continue
+		}
+		fmt.Fprintf(diff, "- %s\n", line)
+	}
+	for _, line := range strings.Split(got, "\n") {
+		if wantCounts[line] > 0 {
+			wantCounts[line]--
+			continue
+		}
+		fmt.Fprintf(diff, "+ %s\n", line)
+	}
+	return diff.String()
+}
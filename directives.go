@@ -0,0 +1,179 @@
+package pgmigrate
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const directivePrefix = "-- pgmigrate:"
+
+// directives are set in the leading comment lines of a migration file, as
+// space separated words after "-- pgmigrate:", e.g.
+//
+//	-- pgmigrate: no-transaction
+//	-- pgmigrate: statement_timeout=5m lock_timeout=10s isolation=serializable
+type directives struct {
+	// noTransaction runs the file outside of a transaction, for statements
+	// like CREATE INDEX CONCURRENTLY. The file is sent as a single query, so
+	// should hold a single statement, unless run with WithSplitStatements. If
+	// it fails the database is left dirty.
+	noTransaction bool
+
+	// statementTimeout and lockTimeout are Go durations, set for just this
+	// file's statements
+	statementTimeout time.Duration
+	lockTimeout      time.Duration
+
+	// isolation is one of read_committed, repeatable_read or serializable
+	isolation sql.IsolationLevel
+
+	// environments restricts a seed file to the comma separated environments
+	environments []string
+
+	// squashed marks a file written by Squash, which replaces every migration
+	// up to its version
+	squashed bool
+}
+
+var isolationLevels = map[string]sql.IsolationLevel{
+	"read_committed":  sql.LevelReadCommitted,
+	"repeatable_read": sql.LevelRepeatableRead,
+	"serializable":    sql.LevelSerializable,
+}
+
+var isolationSQL = map[sql.IsolationLevel]string{
+	sql.LevelReadCommitted:  "READ COMMITTED",
+	sql.LevelRepeatableRead: "REPEATABLE READ",
+	sql.LevelSerializable:   "SERIALIZABLE",
+}
+
+func parseDirectives(content []byte) (directives, error) {
+	parsed := directives{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+		if !strings.HasPrefix(line, directivePrefix) {
+			continue
+		}
+
+		if _, ok := includeTarget(line); ok {
+			continue
+		}
+
+		for _, word := range strings.Fields(strings.TrimPrefix(line, directivePrefix)) {
+			key := word
+			value := ""
+			if idx := strings.Index(word, "="); idx != -1 {
+				key, value = word[:idx], word[idx+1:]
+			}
+			switch key {
+			case "no-transaction":
+				if value != "" {
+					return directives{}, fmt.Errorf("directive no-transaction takes no value")
+				}
+				parsed.noTransaction = true
+			case "statement_timeout", "lock_timeout":
+				duration, err := time.ParseDuration(value)
+				if err != nil || duration < 0 {
+					return directives{}, fmt.Errorf("directive %s requires a duration like 30s or 5m, got %q", key, value)
+				}
+				if key == "statement_timeout" {
+					parsed.statementTimeout = duration
+				} else {
+					parsed.lockTimeout = duration
+				}
+			case "isolation":
+				level, ok := isolationLevels[value]
+				if !ok {
+					return directives{}, fmt.Errorf("directive isolation must be read_committed, repeatable_read or serializable, got %q", value)
+				}
+				parsed.isolation = level
+			case "environments":
+				if value == "" {
+					return directives{}, fmt.Errorf("directive environments requires a comma separated list")
+				}
+				parsed.environments = strings.Split(value, ",")
+			case "squashed":
+				if value != "" {
+					return directives{}, fmt.Errorf("directive squashed takes no value")
+				}
+				parsed.squashed = true
+			default:
+				return directives{}, fmt.Errorf("unknown directive %q", key)
+			}
+		}
+	}
+
+	if parsed.noTransaction && parsed.isolation != sql.LevelDefault {
+		return directives{}, fmt.Errorf("directive isolation can't be used with no-transaction")
+	}
+	return parsed, nil
+}
+
+// inEnvironment reports whether a seed file runs in environment
+func (d directives) inEnvironment(environment string) bool {
+	if len(d.environments) == 0 {
+		return true
+	}
+	for _, allowed := range d.environments {
+		if allowed == environment {
+			return true
+		}
+	}
+	return false
+}
+
+// txOptions are used to begin the file's transaction
+func (d directives) txOptions() *sql.TxOptions {
+	if d.isolation == sql.LevelDefault {
+		return nil
+	}
+	return &sql.TxOptions{Isolation: d.isolation}
+}
+
+// beginSQL is the literal BEGIN statement matching txOptions
+func (d directives) beginSQL() string {
+	if d.isolation == sql.LevelDefault {
+		return "BEGIN;"
+	}
+	return fmt.Sprintf("BEGIN ISOLATION LEVEL %s;", isolationSQL[d.isolation])
+}
+
+// setStatements apply the timeouts, to the current transaction when local is
+// set, otherwise to the session.
+func (d directives) setStatements(local bool) []string {
+	set := "SET"
+	if local {
+		set = "SET LOCAL"
+	}
+	statements := []string{}
+	if d.statementTimeout > 0 {
+		statements = append(statements, fmt.Sprintf("%s statement_timeout = %d;", set, d.statementTimeout.Milliseconds()))
+	}
+	if d.lockTimeout > 0 {
+		statements = append(statements, fmt.Sprintf("%s lock_timeout = %d;", set, d.lockTimeout.Milliseconds()))
+	}
+	return statements
+}
+
+// resetStatements undo the session level setStatements
+func (d directives) resetStatements() []string {
+	statements := []string{}
+	if d.statementTimeout > 0 {
+		statements = append(statements, "RESET statement_timeout;")
+	}
+	if d.lockTimeout > 0 {
+		statements = append(statements, "RESET lock_timeout;")
+	}
+	return statements
+}
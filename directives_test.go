@@ -0,0 +1,107 @@
+package pgmigrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseDirectives(t *testing.T) {
+	parsed, err := parseDirectives([]byte(`
+-- Adds an index without blocking writes
+-- pgmigrate: no-transaction
+CREATE INDEX CONCURRENTLY foo_id ON foo (id);
+`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !parsed.noTransaction {
+		t.Error("Expected no-transaction to be set")
+	}
+
+	parsed, err = parseDirectives([]byte(`CREATE TABLE foo (id int);
+-- pgmigrate: no-transaction
+`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if parsed.noTransaction {
+		t.Error("Directives after the first statement should be ignored")
+	}
+
+	parsed, err = parseDirectives([]byte(`-- pgmigrate: statement_timeout=5m lock_timeout=10s
+-- pgmigrate: isolation=serializable
+UPDATE foo SET id = id + 1;
+`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if parsed.statementTimeout != 5*time.Minute || parsed.lockTimeout != 10*time.Second {
+		t.Errorf("Wrong timeouts %s %s", parsed.statementTimeout, parsed.lockTimeout)
+	}
+	if parsed.isolation != sql.LevelSerializable {
+		t.Errorf("Wrong isolation %s", parsed.isolation)
+	}
+	if set := parsed.setStatements(true); len(set) != 2 || set[0] != "SET LOCAL statement_timeout = 300000;" {
+		t.Errorf("Wrong set statements %q", set)
+	}
+	if begin := parsed.beginSQL(); begin != "BEGIN ISOLATION LEVEL SERIALIZABLE;" {
+		t.Errorf("Wrong begin %q", begin)
+	}
+
+	parsed, err = parseDirectives([]byte("-- pgmigrate: environments=development,staging\n"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !parsed.inEnvironment("staging") || parsed.inEnvironment("production") || parsed.inEnvironment("") {
+		t.Errorf("Wrong environments %q", parsed.environments)
+	}
+	if !(directives{}).inEnvironment("production") {
+		t.Error("Expected files without environments to run everywhere")
+	}
+
+	for _, bad := range []string{
+		"-- pgmigrate: environments=\n",
+		"-- pgmigrate: no-transacton\n",
+		"-- pgmigrate: statement_timeout=soon\n",
+		"-- pgmigrate: isolation=chaos\n",
+		"-- pgmigrate: no-transaction isolation=serializable\n",
+	} {
+		if _, err := parseDirectives([]byte(bad)); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestNoTransaction(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":     s1u,
+		"001-foo.down.sql":   s1d,
+		"002-index.up.sql":   "-- pgmigrate: no-transaction\nCREATE INDEX CONCURRENTLY foo_id ON foo (id);",
+		"002-index.down.sql": "-- pgmigrate: no-transaction\nDROP INDEX CONCURRENTLY foo_id;",
+		"003-bad.up.sql":     "-- pgmigrate: no-transaction\nCREATE INDEX CONCURRENTLY missing_id ON missing (id);",
+		"003-bad.down.sql":   "SELECT 1;",
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_notx")
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	if err := MigrateDatabase(ctx, conn, migrateDir, 2); err != nil {
+		t.Fatalf("Unable to migrate: %s", err.Error())
+	}
+
+	if err := MigrateDatabase(ctx, conn, migrateDir, 3); err == nil {
+		t.Fatal("Expected the bad migration to fail")
+	}
+
+	dirtyErr := &DirtyError{}
+	if err := defaultTable.checkDirty(ctx, conn); !errors.As(err, &dirtyErr) || dirtyErr.Version != 3 {
+		t.Fatalf("Expected a failed no-transaction migration to leave the database dirty, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package pgmigrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DirtyError is returned when a previous run failed without leaving the
+// database in a known state. The database must be checked by hand, then
+// marked as repaired with Force.
+type DirtyError struct {
+	Version int
+}
+
+func (err *DirtyError) Error() string {
+	return fmt.Sprintf("database is dirty: migration %d failed part way, repair it then call Force", err.Version)
+}
+
+// Is matches ErrDirty
+func (err *DirtyError) Is(target error) bool {
+	return target == ErrDirty
+}
+
+func (t trackingTable) ensureDirtyColumn(ctx context.Context, conn Queryer) error {
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS dirty_version bigint`, t.versionTable()))
+	return err
+}
+
+func (t trackingTable) checkDirty(ctx context.Context, conn Queryer) error {
+	dirtyVersion := sql.NullInt64{}
+	if err := conn.QueryRowContext(ctx, fmt.Sprintf(`SELECT dirty_version FROM %s`, t.versionTable())).Scan(&dirtyVersion); err != nil {
+		return err
+	}
+	if dirtyVersion.Valid {
+		return &DirtyError{Version: int(dirtyVersion.Int64)}
+	}
+	return nil
+}
+
+// markDirty is recorded outside of the migration transaction, so it survives
+// anything short of a clean rollback.
+func (t trackingTable) markDirty(ctx context.Context, conn Queryer, version int) error {
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET dirty_version = $1`, t.versionTable()), version)
+	return err
+}
+
+func (t trackingTable) clearDirty(ctx context.Context, conn Queryer) error {
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET dirty_version = NULL`, t.versionTable()))
+	return err
+}
+
+// Force sets the recorded version and clears any dirty marker without running
+// any migrations. Use it once a failed migration has been repaired by hand.
+func Force(ctx context.Context, conn Queryer, version int) error {
+	return New(conn).Force(ctx, version)
+}
+
+// Force sets the recorded version and clears any dirty marker without running
+// any migrations.
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	return m.withSession(ctx, func(conn Queryer) error {
+		table := m.cfg.table
+		if _, err := table.getVersion(ctx, conn); err != nil {
+			return err
+		}
+		if err := table.ensureDirtyColumn(ctx, conn); err != nil {
+			return err
+		}
+		if err := table.ensureHistoryTable(ctx, conn); err != nil {
+			return err
+		}
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET version = $1, dirty_version = NULL`, table.versionTable()), version); err != nil {
+			return err
+		}
+		// Record the force so that skipped migrations aren't later taken to
+		// be out of order
+		return table.recordHistory(ctx, conn, HistoryEntry{
+			Version:   version,
+			Direction: directionForce,
+			AppliedAt: time.Now(),
+		})
+	})
+}
@@ -0,0 +1,54 @@
+package pgmigrate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestDirty(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"002-bad.up.sql":   `CREATE TABLE bar (id int); SELECT * FROM missing;`,
+		"002-bad.down.sql": s2d,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_dirty")
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	if err := MigrateDatabase(ctx, conn, migrateDir, -1); err == nil {
+		t.Fatal("Expected the bad migration to fail")
+	}
+
+	// The failed migration rolled back cleanly, so it should be possible to
+	// keep going
+	if err := defaultTable.checkDirty(ctx, conn); err != nil {
+		t.Fatalf("Expected a clean rollback to not be dirty: %s", err.Error())
+	}
+
+	if err := defaultTable.markDirty(ctx, conn, 2); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err := MigrateDatabase(ctx, conn, migrateDir, -1)
+	dirtyErr := &DirtyError{}
+	if !errors.As(err, &dirtyErr) {
+		t.Fatalf("Expected a DirtyError, got %v", err)
+	}
+	if dirtyErr.Version != 2 {
+		t.Errorf("Expected dirty version 2, got %d", dirtyErr.Version)
+	}
+
+	if err := Force(ctx, conn, 1); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := MigrateDatabase(ctx, conn, migrateDir, 1); err != nil {
+		t.Fatalf("Expected no error after Force: %s", err.Error())
+	}
+}
@@ -0,0 +1,69 @@
+package pgmigrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+func printPlan(ctx context.Context, conn Queryer, cfg *config, targetVersion int, w io.Writer) error {
+	source := cfg.source
+	currentVersion, targetVersion, steps, err := resolvePlan(ctx, conn, cfg, targetVersion)
+	if err != nil {
+		return err
+	}
+
+	var repeatables []pendingRepeatable
+	if _, maxMigration, err := loadMigrations(cfg); err != nil {
+		return err
+	} else if targetVersion == maxMigration {
+		repeatables, err = cfg.table.pendingRepeatables(ctx, conn, source)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(w, "-- Migrate from %d to %d\n", currentVersion, targetVersion)
+	if len(steps) == 0 && len(repeatables) == 0 {
+		fmt.Fprintf(w, "-- Nothing to do\n")
+		return nil
+	}
+
+	for _, step := range steps {
+		if step.Func() != nil {
+			fmt.Fprintf(w, "\n-- %s (%s %d, then version %d, Go function)\n", step.Filename(), step.Direction, step.Version, step.ResultVersion())
+			continue
+		}
+		rendered, err := renderFile(cfg, source, step.Filename())
+		if err != nil {
+			return err
+		}
+		content := rendered.content
+		fileDirectives, err := parseDirectives(content)
+		if err != nil {
+			return fmt.Errorf("reading directives in %s: %w", step.Filename(), err)
+		}
+		transaction := ""
+		if fileDirectives.noTransaction {
+			transaction = ", without a transaction"
+		}
+		fmt.Fprintf(w, "\n-- %s (%s %d, then version %d%s)\n", step.Filename(), step.Direction, step.Version, step.ResultVersion(), transaction)
+		if _, err := w.Write(content); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+
+	for _, repeatable := range repeatables {
+		rendered, err := renderContent(cfg, repeatable.File, repeatable.content)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\n-- %s (repeatable, changed since it last ran)\n", repeatable.File)
+		if _, err := w.Write(rendered.content); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
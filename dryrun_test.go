@@ -0,0 +1,38 @@
+package pgmigrate
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_dryrun")
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	out := &bytes.Buffer{}
+	if err := MigrateDatabase(ctx, conn, migrateDir, 2, WithDryRun(out)); err != nil {
+		t.Fatalf("Unable to plan: %s", err.Error())
+	}
+
+	plan := out.String()
+	for _, expect := range []string{"001-foo.up.sql", s1u, "002-bar.up.sql", s2u} {
+		if !strings.Contains(plan, expect) {
+			t.Errorf("Expected plan to contain %q:\n%s", expect, plan)
+		}
+	}
+	if strings.Contains(plan, "003-baz.up.sql") {
+		t.Errorf("Plan went past the target:\n%s", plan)
+	}
+
+	if _, err := defaultTable.peekVersion(ctx, conn); err != errNoTable {
+		t.Errorf("Expected dry run to leave the database untouched, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package pgmigrate
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Errors returned by runs wrap these, where they apply, so callers can check
+// for them with errors.Is. DirtyError and ChecksumMismatchError match
+// ErrDirty and ErrChecksumMismatch, and carry the details for errors.As.
+var (
+	// ErrMissingUp is a version with no up file or function
+	ErrMissingUp = errors.New("Missing Up migration")
+
+	// ErrMissingDown is a version with no down file or function, unless
+	// WithForwardOnly allows it
+	ErrMissingDown = errors.New("Missing Down migration")
+
+	// ErrBadFilename is a file in the source which looks like a migration but
+	// can't be read as one
+	ErrBadFilename = errors.New("bad migration filename")
+
+	// ErrDuplicateVersion is two files in the source for the same version and
+	// direction, which often follows a merge
+	ErrDuplicateVersion = errors.New("duplicate version")
+
+	// ErrChecksumMismatch is an applied migration whose file has changed
+	ErrChecksumMismatch = errors.New("migration has changed since it was applied")
+
+	// ErrLocked is a run which gave up waiting for another migrator to
+	// release the migration lock, when its context ended
+	ErrLocked = errors.New("another migrator holds the migration lock")
+
+	// ErrDirty is a database left part way through a migration by a failed
+	// run
+	ErrDirty = errors.New("database is dirty")
+)
+
+func missingUpError(version int) error {
+	return fmt.Errorf("%w %d", ErrMissingUp, version)
+}
+
+func missingDownError(version int) error {
+	return fmt.Errorf("%w %d", ErrMissingDown, version)
+}
+
+// MigrationError is returned when a migration fails, with where it failed
+// and, for errors from the server, the server's details. Line and Column are
+// worked out from the server's Position, counting in the file as it was
+// sent, after includes and templates are expanded, and are 0 when the server
+// didn't say.
+type MigrationError struct {
+	Version   int
+	Filename  string
+	Direction string
+
+	// Code is the SQLSTATE of a server error
+	Code     string
+	Message  string
+	Detail   string
+	Hint     string
+	Where    string
+	Table    string
+	Position int
+
+	Line   int
+	Column int
+
+	Err error
+}
+
+func (err *MigrationError) Error() string {
+	msg := err.Err.Error()
+	if err.Line > 0 {
+		msg = fmt.Sprintf("%s:%d:%d: %s", err.Filename, err.Line, err.Column, msg)
+	}
+	if err.Detail != "" {
+		msg += "; detail: " + err.Detail
+	}
+	if err.Hint != "" {
+		msg += "; hint: " + err.Hint
+	}
+	return msg
+}
+
+func (err *MigrationError) Unwrap() error {
+	return err.Err
+}
+
+// newMigrationError adds the details of the failed step to err
+func newMigrationError(step step, err error) *MigrationError {
+	migrationErr := &MigrationError{
+		Version:   step.Version,
+		Filename:  step.Filename(),
+		Direction: step.Direction,
+		Code:      sqlState(err),
+		Err:       err,
+	}
+	if serverErr, ok := asServerError(err); ok {
+		migrationErr.Message = serverErr.Message
+		migrationErr.Detail = serverErr.Detail
+		migrationErr.Hint = serverErr.Hint
+		migrationErr.Where = serverErr.Where
+		migrationErr.Table = serverErr.Table
+		migrationErr.Position, _ = strconv.Atoi(serverErr.Position)
+	}
+	var located *locatedError
+	if errors.As(err, &located) && migrationErr.Position > 0 {
+		migrationErr.Line, migrationErr.Column = lineColumn(located.content, located.offset, migrationErr.Position)
+	}
+	return migrationErr
+}
+
+// locatedError keeps the content a failed query was sent from, for working
+// out the line of the server's error position. The query starts at offset
+// bytes into content.
+type locatedError struct {
+	err     error
+	content string
+	offset  int
+}
+
+func (err *locatedError) Error() string {
+	return err.err.Error()
+}
+
+func (err *locatedError) Unwrap() error {
+	return err.err
+}
+
+// lineColumn converts the server's position, counted in characters from 1 in
+// the query at offset, to a line and column in content
+func lineColumn(content string, offset int, position int) (int, int) {
+	line, column := 1, 1
+	for _, c := range content[:offset] {
+		if c == '\n' {
+			line, column = line+1, 1
+		} else {
+			column++
+		}
+	}
+	characters := 1
+	for _, c := range content[offset:] {
+		if characters == position {
+			break
+		}
+		characters++
+		if c == '\n' {
+			line, column = line+1, 1
+		} else {
+			column++
+		}
+	}
+	return line, column
+}
@@ -0,0 +1,111 @@
+package pgmigrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	byVersion := map[int]Migration{
+		1: {Version: 1, UpFile: "001-foo.up.sql"},
+		2: {Version: 2, DownFile: "002-bar.down.sql"},
+	}
+	if _, err := planSteps(byVersion, 0, 2); !errors.Is(err, ErrMissingUp) || err.Error() != "Missing Up migration 2" {
+		t.Errorf("Expected ErrMissingUp, got %v", err)
+	}
+	if _, err := planSteps(byVersion, 1, 0); !errors.Is(err, ErrMissingDown) {
+		t.Errorf("Expected ErrMissingDown, got %v", err)
+	}
+
+	for _, name := range []string{"abc-foo.up.sql", "001-foo.sideways.sql"} {
+		if _, err := ParseFilenames([]string{name}); !errors.Is(err, ErrBadFilename) {
+			t.Errorf("Expected ErrBadFilename for %s, got %v", name, err)
+		}
+	}
+
+	var dirty error = fmt.Errorf("migrating: %w", &DirtyError{Version: 3})
+	if !errors.Is(dirty, ErrDirty) {
+		t.Error("Expected DirtyError to match ErrDirty")
+	}
+	var dirtyErr *DirtyError
+	if !errors.As(dirty, &dirtyErr) || dirtyErr.Version != 3 {
+		t.Errorf("Expected errors.As to find the DirtyError, got %v", dirtyErr)
+	}
+	if !errors.Is(&ChecksumMismatchError{Version: 1}, ErrChecksumMismatch) {
+		t.Error("Expected ChecksumMismatchError to match ErrChecksumMismatch")
+	}
+	if errors.Is(&ChecksumMismatchError{Version: 1}, ErrDirty) {
+		t.Error("ChecksumMismatchError shouldn't match ErrDirty")
+	}
+}
+
+func TestLineColumn(t *testing.T) {
+	content := "CREATE TABLE foo (id int);\n\nCREATE TABEL bar (id int);\n"
+	for _, tc := range []struct {
+		offset   int
+		position int
+		line     int
+		column   int
+	}{
+		{0, 1, 1, 1},
+		{0, 36, 3, 8},
+		{28, 8, 3, 8},
+	} {
+		line, column := lineColumn(content, tc.offset, tc.position)
+		if line != tc.line || column != tc.column {
+			t.Errorf("Offset %d position %d: expected %d:%d, got %d:%d", tc.offset, tc.position, tc.line, tc.column, line, column)
+		}
+	}
+}
+
+func TestNewMigrationError(t *testing.T) {
+	content := "CREATE TABLE foo (id int);\nCREATE TABEL bar (id int);"
+	serverErr := &pq.Error{Code: "42601", Message: `syntax error at or near "TABEL"`, Position: "8", Hint: "check the spelling"}
+	err := fmt.Errorf("executing 002-bar.up.sql: %w", &locatedError{err: serverErr, content: content, offset: 27})
+
+	migration := Migration{Version: 2, Name: "bar", UpFile: "002-bar.up.sql"}
+	migrationErr := newMigrationError(step{Migration: migration, Direction: directionUp}, err)
+	if migrationErr.Version != 2 || migrationErr.Filename != "002-bar.up.sql" || migrationErr.Code != "42601" || migrationErr.Position != 8 {
+		t.Errorf("Wrong details %+v", migrationErr)
+	}
+	if migrationErr.Line != 2 || migrationErr.Column != 8 {
+		t.Errorf("Expected 2:8, got %d:%d", migrationErr.Line, migrationErr.Column)
+	}
+	expect := `002-bar.up.sql:2:8: executing 002-bar.up.sql: pq: syntax error at or near "TABEL"; hint: check the spelling`
+	if migrationErr.Error() != expect {
+		t.Errorf("Expected %q, got %q", expect, migrationErr.Error())
+	}
+	if !errors.Is(migrationErr, serverErr) {
+		t.Error("Expected the server error to be wrapped")
+	}
+}
+
+func TestMigrationError(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"002-bar.up.sql":   "CREATE TABLE bar (id int);\n\nCREATE TABEL baz (id int);",
+		"002-bar.down.sql": s2d,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_migration_error")
+	defer conn.Close()
+
+	err := MigrateDatabase(context.Background(), conn, migrateDir, -1)
+	var migrationErr *MigrationError
+	if !errors.As(err, &migrationErr) {
+		t.Fatalf("Expected a MigrationError, got %v", err)
+	}
+	if migrationErr.Version != 2 || migrationErr.Filename != "002-bar.up.sql" || migrationErr.Code != "42601" {
+		t.Errorf("Wrong details %+v", migrationErr)
+	}
+	if migrationErr.Line != 3 || migrationErr.Column != 8 {
+		t.Errorf("Expected 3:8, got %d:%d", migrationErr.Line, migrationErr.Column)
+	}
+}
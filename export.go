@@ -0,0 +1,89 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+)
+
+// Layout is a convention for naming migration files
+type Layout int
+
+const (
+	// PgmigrateLayout names files like 001-description.up.sql
+	PgmigrateLayout Layout = iota
+
+	// GolangMigrateLayout names files like golang-migrate's
+	// 000001_description.up.sql
+	GolangMigrateLayout
+)
+
+// fileName formats the file name of a migration's direction in layout,
+// with versions zero padded to width
+func (layout Layout) fileName(width int, migration Migration, direction string) string {
+	separator := "-"
+	if layout == GolangMigrateLayout {
+		separator = "_"
+	}
+	name := fmt.Sprintf("%0*d", width, migration.Version)
+	if migration.Name != "" {
+		name += separator + migration.Name
+	}
+	return name + "." + direction + ".sql"
+}
+
+func (layout Layout) minWidth() int {
+	if layout == GolangMigrateLayout {
+		return 6
+	}
+	return defaultVersionWidth
+}
+
+// Export copies the migration files of source into dir, named in layout, so a
+// folder can move between pgmigrate's and golang-migrate's conventions.
+// Gzipped files are written decompressed, goose files are split into their
+// two directions, and templates keep their .tmpl extension. Repeatable
+// migrations aren't copied. Existing files in dir are never overwritten. It
+// returns the names of the new files.
+func Export(source MigrationSource, dir string, layout Layout) ([]string, error) {
+	migrations, err := source.List()
+	if err != nil {
+		return nil, err
+	}
+
+	width := layout.minWidth()
+	if len(migrations) > 0 {
+		if digits := len(strconv.Itoa(migrations[len(migrations)-1].Version)); digits > width {
+			width = digits
+		}
+	}
+
+	filenames := []string{}
+	for _, migration := range migrations {
+		for _, file := range []struct {
+			name      string
+			direction string
+		}{
+			{migration.UpFile, directionUp},
+			{migration.DownFile, directionDown},
+		} {
+			if file.name == "" {
+				continue
+			}
+			content, err := readMigrationFile(source, file.name)
+			if err != nil {
+				return nil, err
+			}
+			filename := layout.fileName(width, migration, file.direction)
+			if isTemplate(file.name) {
+				filename += ".tmpl"
+			}
+			filename = filepath.Join(dir, filename)
+			if err := writeNewFile(filename, content); err != nil {
+				return nil, err
+			}
+			filenames = append(filenames, filename)
+		}
+	}
+	return filenames, nil
+}
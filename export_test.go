@@ -0,0 +1,62 @@
+package pgmigrate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":       s1u,
+		"001-foo.down.sql":     s1d,
+		"002-bar.up.sql":       s2u,
+		"00003_baz.sql":        "-- +goose Up\n" + s3u + "\n-- +goose Down\n" + s3d,
+		"R-views.sql":          "SELECT 1;",
+		"004-part.up.sql.tmpl": "SELECT {{ 1 }};",
+	})
+	defer os.RemoveAll(migrateDir)
+
+	exportDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(exportDir)
+
+	filenames, err := Export(DirSource(migrateDir), exportDir, GolangMigrateLayout)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(filenames) != 6 || filenames[0] != filepath.Join(exportDir, "000001_foo.up.sql") || filenames[5] != filepath.Join(exportDir, "000004_part.up.sql.tmpl") {
+		t.Fatalf("Unexpected files %v", filenames)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(exportDir, "000003_baz.down.sql"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(content) != s3d {
+		t.Errorf("Wrong goose down section %q", string(content))
+	}
+
+	if _, err := Export(DirSource(migrateDir), exportDir, GolangMigrateLayout); err == nil {
+		t.Error("Expected an error overwriting exported files")
+	}
+
+	backDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(backDir)
+
+	if _, err := Export(DirSource(exportDir), backDir, PgmigrateLayout); err != nil {
+		t.Fatal(err.Error())
+	}
+	migrations, err := ParseDir(backDir, WithForwardOnly(true))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 4 || migrations[2].UpFile != "003-baz.up.sql" || migrations[2].DownFile != "003-baz.down.sql" {
+		t.Errorf("Unexpected migrations %+v", migrations)
+	}
+}
@@ -0,0 +1,150 @@
+package pgmigrate
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Fixtures is a directory of test data for a schema made by GetTestSchema.
+// Each file fills the table it is named after, optionally with a number to
+// set the load order, e.g. 01-users.csv then 02-orders.sql, or a schema, as
+// in app.users.csv. CSV files have a header row of column names, and \N for
+// NULL. SQL files are run as they are.
+type Fixtures struct {
+	dir    string
+	files  []string
+	tables []string
+}
+
+// fixtureOrder is the optional load order prefix of a fixture file name
+var fixtureOrder = regexp.MustCompile(`^[0-9]+-`)
+
+// OpenFixtures finds the .sql and .csv files in dir, without loading them
+func OpenFixtures(dir string) (*Fixtures, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	fixtures := &Fixtures{dir: dir}
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if entry.IsDir() || (ext != ".sql" && ext != ".csv") {
+			continue
+		}
+		fixtures.files = append(fixtures.files, name)
+		fixtures.tables = append(fixtures.tables, fixtureTable(name))
+	}
+	return fixtures, nil
+}
+
+// LoadFixtures opens the fixtures in dir and loads them into conn
+func LoadFixtures(ctx context.Context, conn Queryer, dir string) (*Fixtures, error) {
+	fixtures, err := OpenFixtures(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := fixtures.Load(ctx, conn); err != nil {
+		return nil, err
+	}
+	return fixtures, nil
+}
+
+// fixtureTable is the quoted table a fixture file fills
+func fixtureTable(name string) string {
+	name = fixtureOrder.ReplaceAllString(strings.TrimSuffix(name, filepath.Ext(name)), "")
+	if idx := strings.LastIndex(name, "."); idx != -1 {
+		return quoteIdentifier(name[:idx]) + "." + quoteIdentifier(name[idx+1:])
+	}
+	return quoteIdentifier(name)
+}
+
+// Load inserts every fixture, in file name order
+func (f *Fixtures) Load(ctx context.Context, conn Queryer) error {
+	for idx, name := range f.files {
+		content, err := ioutil.ReadFile(filepath.Join(f.dir, name))
+		if err != nil {
+			return err
+		}
+		if filepath.Ext(name) == ".sql" {
+			if _, err := conn.ExecContext(ctx, string(content)); err != nil {
+				return fmt.Errorf("loading fixture %s: %w", name, err)
+			}
+			continue
+		}
+		if err := loadCSV(ctx, conn, f.tables[idx], content); err != nil {
+			return fmt.Errorf("loading fixture %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Truncate empties every table with a fixture, along with anything
+// referencing them, and restarts their sequences, so the next test starts
+// from nothing
+func (f *Fixtures) Truncate(ctx context.Context, conn Queryer) error {
+	if len(f.tables) == 0 {
+		return nil
+	}
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`TRUNCATE %s RESTART IDENTITY CASCADE`, strings.Join(uniqueStrings(f.tables), ", ")))
+	return err
+}
+
+// Reload truncates the fixture tables then loads the fixtures again, for the
+// start of each test case
+func (f *Fixtures) Reload(ctx context.Context, conn Queryer) error {
+	if err := f.Truncate(ctx, conn); err != nil {
+		return err
+	}
+	return f.Load(ctx, conn)
+}
+
+func loadCSV(ctx context.Context, conn Queryer, table string, content []byte) error {
+	records, err := csv.NewReader(strings.NewReader(string(content))).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	columns := make([]string, len(records[0]))
+	placeholders := make([]string, len(records[0]))
+	for idx, column := range records[0] {
+		columns[idx] = quoteIdentifier(strings.TrimSpace(column))
+		placeholders[idx] = fmt.Sprintf("$%d", idx+1)
+	}
+	insert := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`, table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+
+	for _, record := range records[1:] {
+		values := make([]interface{}, len(record))
+		for idx, value := range record {
+			if value == `\N` {
+				values[idx] = nil
+			} else {
+				values[idx] = value
+			}
+		}
+		if _, err := conn.ExecContext(ctx, insert, values...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,74 @@
+package pgmigrate
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestOpenFixtures(t *testing.T) {
+	dir := writeMigrationFiles(t, map[string]string{
+		"02-orders.sql":  `INSERT INTO orders (user_id) VALUES (1);`,
+		"01-users.csv":   "id,name\n1,alice\n",
+		"app.extras.csv": "id\n1\n",
+		"README.md":      "ignored",
+	})
+	defer os.RemoveAll(dir)
+
+	fixtures, err := OpenFixtures(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expect := []string{`"users"`, `"orders"`, `"app"."extras"`}
+	if len(fixtures.tables) != len(expect) {
+		t.Fatalf("Expected tables %v, got %v", expect, fixtures.tables)
+	}
+	for idx, table := range expect {
+		if fixtures.tables[idx] != table {
+			t.Errorf("Expected table %d to be %s, got %s", idx, table, fixtures.tables[idx])
+		}
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	dir := writeMigrationFiles(t, map[string]string{
+		"01-users.csv":  "id,name,email\n1,alice,alice@example.com\n2,bob,\\N\n",
+		"02-orders.sql": `INSERT INTO orders (user_id) VALUES (1), (2);`,
+	})
+	defer os.RemoveAll(dir)
+
+	conn := getTestConn(t, "test_fixtures")
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE users (id int primary key, name text, email text);
+		CREATE TABLE orders (id serial primary key, user_id int references users (id));
+	`); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	fixtures, err := LoadFixtures(ctx, conn, dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	nullEmails := 0
+	if err := conn.QueryRowContext(ctx, `SELECT count(*) FROM users WHERE email IS NULL`).Scan(&nullEmails); err != nil {
+		t.Fatal(err.Error())
+	}
+	if nullEmails != 1 {
+		t.Errorf("Expected \\N to load as NULL, got %d NULL emails", nullEmails)
+	}
+
+	if err := fixtures.Reload(ctx, conn); err != nil {
+		t.Fatal(err.Error())
+	}
+	maxID := 0
+	if err := conn.QueryRowContext(ctx, `SELECT max(id) FROM orders`).Scan(&maxID); err != nil {
+		t.Fatal(err.Error())
+	}
+	if maxID != 2 {
+		t.Errorf("Expected the reload to restart the sequence, got max id %d", maxID)
+	}
+}
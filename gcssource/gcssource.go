@@ -0,0 +1,143 @@
+// Package gcssource reads pgmigrate migrations from a Google Cloud Storage
+// bucket, laid out as they would be in a migrations directory.
+package gcssource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2/google"
+	"gopkg.daemonl.com/pgmigrate"
+)
+
+const readOnlyScope = "https://www.googleapis.com/auth/devstorage.read_only"
+
+// DefaultEndpoint is the Cloud Storage JSON API
+const DefaultEndpoint = "https://storage.googleapis.com/storage/v1/"
+
+// Source is a pgmigrate.MigrationSource reading the files directly under a
+// prefix in a bucket.
+type Source struct {
+	ctx    context.Context
+	client *http.Client
+	bucket string
+	prefix string
+
+	// Endpoint is the JSON API to talk to, DefaultEndpoint unless changed,
+	// e.g. for an emulator
+	Endpoint string
+
+	// Generations pins files, by name, to an object generation. Listing or
+	// reading a pinned file fails if it has been replaced since.
+	Generations map[string]int64
+}
+
+// New creates a Source for the files under prefix in bucket, authenticating
+// with Application Default Credentials. ctx is used for every request.
+func New(ctx context.Context, bucket string, prefix string) (*Source, error) {
+	client, err := google.DefaultClient(ctx, readOnlyScope)
+	if err != nil {
+		return nil, fmt.Errorf("finding default credentials: %w", err)
+	}
+	return NewWithClient(ctx, client, bucket, prefix), nil
+}
+
+// NewWithClient creates a Source which makes requests with client, which
+// must add its own credentials.
+func NewWithClient(ctx context.Context, client *http.Client, bucket string, prefix string) *Source {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &Source{
+		ctx:      ctx,
+		client:   client,
+		bucket:   bucket,
+		prefix:   prefix,
+		Endpoint: DefaultEndpoint,
+	}
+}
+
+type objectList struct {
+	Items []struct {
+		Name       string `json:"name"`
+		Generation int64  `json:"generation,string"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func (s *Source) List() ([]pgmigrate.Migration, error) {
+	names := []string{}
+	pageToken := ""
+	for {
+		query := url.Values{
+			"prefix":    {s.prefix},
+			"delimiter": {"/"},
+			"fields":    {"items(name,generation),nextPageToken"},
+		}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		list := objectList{}
+		if err := s.get("b/"+url.PathEscape(s.bucket)+"/o", query, func(body []byte) error {
+			return json.Unmarshal(body, &list)
+		}); err != nil {
+			return nil, fmt.Errorf("listing gs://%s/%s: %w", s.bucket, s.prefix, err)
+		}
+
+		for _, item := range list.Items {
+			name := strings.TrimPrefix(item.Name, s.prefix)
+			if generation, ok := s.Generations[name]; ok && generation != item.Generation {
+				return nil, fmt.Errorf("%s is generation %d, but is pinned to %d", name, item.Generation, generation)
+			}
+			names = append(names, name)
+		}
+		if list.NextPageToken == "" {
+			break
+		}
+		pageToken = list.NextPageToken
+	}
+	return pgmigrate.ParseFilenames(names)
+}
+
+func (s *Source) ReadFile(name string) ([]byte, error) {
+	query := url.Values{"alt": {"media"}}
+	if generation, ok := s.Generations[name]; ok {
+		query.Set("ifGenerationMatch", fmt.Sprint(generation))
+	}
+
+	var content []byte
+	if err := s.get("b/"+url.PathEscape(s.bucket)+"/o/"+url.PathEscape(s.prefix+name), query, func(body []byte) error {
+		content = body
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("reading gs://%s/%s%s: %w", s.bucket, s.prefix, name, err)
+	}
+	return content, nil
+}
+
+func (s *Source) get(path string, query url.Values, callback func([]byte) error) error {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodGet, s.Endpoint+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return callback(body)
+}
@@ -0,0 +1,98 @@
+package gcssource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type object struct {
+	generation int64
+	content    string
+}
+
+// fakeServer serves objects from a map through the JSON API, one per page
+func fakeServer(objects map[string]object) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const listPath = "/b/bucket/o"
+		if r.URL.Path == listPath {
+			names := []string{}
+			for name := range objects {
+				if strings.HasPrefix(name, r.URL.Query().Get("prefix")) && name > r.URL.Query().Get("pageToken") {
+					names = append(names, name)
+				}
+			}
+			list := map[string]interface{}{}
+			if len(names) > 0 {
+				first := names[0]
+				for _, name := range names {
+					if name < first {
+						first = name
+					}
+				}
+				list["items"] = []map[string]string{{"name": first, "generation": fmt.Sprint(objects[first].generation)}}
+				list["nextPageToken"] = first
+			}
+			json.NewEncoder(w).Encode(list) //nolint: errcheck
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, listPath+"/")
+		object, ok := objects[name]
+		if !ok || r.URL.Query().Get("alt") != "media" {
+			http.NotFound(w, r)
+			return
+		}
+		if match := r.URL.Query().Get("ifGenerationMatch"); match != "" && match != fmt.Sprint(object.generation) {
+			http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+			return
+		}
+		w.Write([]byte(object.content)) //nolint: errcheck
+	}))
+}
+
+func TestSource(t *testing.T) {
+	server := fakeServer(map[string]object{
+		"migrations/001-foo.up.sql":   {1, `CREATE TABLE foo (id int);`},
+		"migrations/001-foo.down.sql": {2, `DROP TABLE foo;`},
+		"migrations/002-bar.up.sql":   {3, `CREATE TABLE bar (id int);`},
+		"other/003-baz.up.sql":        {4, `CREATE TABLE baz (id int);`},
+	})
+	defer server.Close()
+
+	source := NewWithClient(context.Background(), server.Client(), "bucket", "migrations")
+	source.Endpoint = server.URL + "/"
+
+	migrations, err := source.List()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 2 || migrations[0].DownFile != "001-foo.down.sql" || migrations[1].Name != "bar" {
+		t.Fatalf("Unexpected migrations %+v", migrations)
+	}
+
+	content, err := source.ReadFile("001-foo.down.sql")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(content) != `DROP TABLE foo;` {
+		t.Errorf("Wrong content %q", string(content))
+	}
+
+	source.Generations = map[string]int64{"002-bar.up.sql": 3}
+	if _, err := source.ReadFile("002-bar.up.sql"); err != nil {
+		t.Errorf("Expected the pinned file to match: %s", err.Error())
+	}
+
+	source.Generations["002-bar.up.sql"] = 2
+	if _, err := source.List(); err == nil {
+		t.Error("Expected listing to fail for a replaced file")
+	}
+	if _, err := source.ReadFile("002-bar.up.sql"); err == nil {
+		t.Error("Expected reading to fail for a replaced file")
+	}
+}
@@ -0,0 +1,81 @@
+// Package gitsource reads pgmigrate migrations from a git repository as of a
+// particular ref, so the migrations for a release are pinned to a commit
+// rather than whatever is checked out.
+package gitsource
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"gopkg.daemonl.com/pgmigrate"
+)
+
+// Source is a pgmigrate.MigrationSource reading a directory of one commit
+type Source struct {
+	commit *object.Commit
+	tree   *object.Tree
+}
+
+// Open reads the migrations in dir, relative to the root of the repository
+// at repoPath, as of ref, which can be anything git rev-parse accepts, such
+// as a tag, branch or commit hash.
+func Open(repoPath string, ref string, dir string) (*Source, error) {
+	repo, err := git.PlainOpenWithOptions(repoPath, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("opening repository %s: %w", repoPath, err)
+	}
+	return New(repo, ref, dir)
+}
+
+// New reads the migrations in dir of an already open repository, as of ref
+func New(repo *git.Repository, ref string, dir string) (*Source, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", ref, err)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	if dir = path.Clean(dir); dir != "." {
+		tree, err = tree.Tree(dir)
+		if err != nil {
+			return nil, fmt.Errorf("finding %s at %s: %w", dir, ref, err)
+		}
+	}
+	return &Source{commit: commit, tree: tree}, nil
+}
+
+// Commit is the hash of the commit the migrations are read from
+func (s *Source) Commit() string {
+	return s.commit.Hash.String()
+}
+
+func (s *Source) List() ([]pgmigrate.Migration, error) {
+	names := []string{}
+	for _, entry := range s.tree.Entries {
+		if entry.Mode.IsFile() {
+			names = append(names, entry.Name)
+		}
+	}
+	return pgmigrate.ParseFilenames(names)
+}
+
+func (s *Source) ReadFile(name string) ([]byte, error) {
+	file, err := s.tree.File(name)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s at %s: %w", name, s.Commit(), err)
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
+}
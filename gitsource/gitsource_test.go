@@ -0,0 +1,102 @@
+package gitsource
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func commitFiles(t *testing.T, repo *git.Repository, root string, files map[string]string) {
+	t.Helper()
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755); err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0660); err != nil {
+			t.Fatal(err.Error())
+		}
+		if _, err := worktree.Add(name); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if _, err := worktree.Commit("migrations", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	}); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestSource(t *testing.T) {
+	root, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(root)
+
+	repo, err := git.PlainInit(root, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	commitFiles(t, repo, root, map[string]string{
+		"db/migrations/001-foo.up.sql":   `CREATE TABLE foo (id int);`,
+		"db/migrations/001-foo.down.sql": `DROP TABLE foo;`,
+	})
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := repo.CreateTag("v1", head.Hash(), nil); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	commitFiles(t, repo, root, map[string]string{
+		"db/migrations/001-foo.up.sql": `CREATE TABLE foo (id bigint);`,
+		"db/migrations/002-bar.up.sql": `CREATE TABLE bar (id int);`,
+	})
+
+	source, err := Open(root, "v1", "db/migrations")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if source.Commit() != head.Hash().String() {
+		t.Errorf("Expected commit %s, got %s", head.Hash(), source.Commit())
+	}
+
+	migrations, err := source.List()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 1 || migrations[0].DownFile != "001-foo.down.sql" {
+		t.Fatalf("Unexpected migrations at v1 %+v", migrations)
+	}
+
+	content, err := source.ReadFile("001-foo.up.sql")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(content) != `CREATE TABLE foo (id int);` {
+		t.Errorf("Expected the content as of v1, got %q", string(content))
+	}
+
+	latest, err := Open(root, "HEAD", "db/migrations")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if migrations, err := latest.List(); err != nil || len(migrations) != 2 {
+		t.Errorf("Expected 2 migrations at HEAD, got %+v, %v", migrations, err)
+	}
+
+	if _, err := Open(root, "v1", "missing"); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
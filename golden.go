@@ -0,0 +1,49 @@
+package pgmigrate
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+)
+
+// UpdateGoldenEnv is the environment variable which, when set to 1, makes
+// VerifyGoldenSchema write the golden file rather than compare against it
+const UpdateGoldenEnv = "PGMIGRATE_UPDATE_GOLDEN"
+
+// VerifyGoldenSchema migrates db up with the migrations in dir and compares
+// the resulting schema with goldenFile, failing t with the differences. The
+// description lists the tables, views, sequences, columns, constraints,
+// indexes, triggers, types and functions of the schema, one per line in name
+// order, so it can be checked in and reviewed. Run the tests with
+// PGMIGRATE_UPDATE_GOLDEN=1 to write the file after an intended change, or
+// when it doesn't exist yet.
+func VerifyGoldenSchema(t TestingT, db Queryer, dir string, goldenFile string, opts ...Option) {
+	t.Helper()
+	ctx := context.Background()
+	migrator := New(db, append(opts, WithSource(DirSource(dir)))...)
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrating up: %s", err)
+		return
+	}
+	schema, err := migrator.describeSchema(ctx)
+	if err != nil {
+		t.Fatalf("%s", err)
+		return
+	}
+
+	if os.Getenv(UpdateGoldenEnv) == "1" {
+		if err := ioutil.WriteFile(goldenFile, []byte(schema), 0644); err != nil {
+			t.Fatalf("writing golden schema: %s", err)
+		}
+		return
+	}
+
+	golden, err := ioutil.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatalf("reading golden schema, run with %s=1 to create it: %s", UpdateGoldenEnv, err)
+		return
+	}
+	if string(golden) != schema {
+		t.Errorf("schema differs from %s, run with %s=1 to update it:\n%s", goldenFile, UpdateGoldenEnv, diffLines(string(golden), schema))
+	}
+}
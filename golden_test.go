@@ -0,0 +1,62 @@
+package pgmigrate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyGoldenSchema(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql": "CREATE TABLE foo (id serial PRIMARY KEY, name text NOT NULL);",
+	})
+	defer os.RemoveAll(migrateDir)
+	goldenFile := filepath.Join(migrateDir, "schema.golden")
+
+	conn := getTestConn(t, "test_golden")
+	defer conn.Close()
+
+	os.Setenv(UpdateGoldenEnv, "1")
+	recorder := &recordingT{}
+	VerifyGoldenSchema(recorder, conn, migrateDir, goldenFile, WithForwardOnly(true))
+	os.Unsetenv(UpdateGoldenEnv)
+	if len(recorder.errors) != 0 {
+		t.Fatalf("Expected the golden file to be written, got %v", recorder.errors)
+	}
+
+	golden, err := ioutil.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, want := range []string{
+		"table foo\n",
+		"  column id integer not null default nextval('foo_id_seq'::regclass)\n",
+		"  column name text not null\n",
+		"  constraint foo_pkey PRIMARY KEY (id)\n",
+		"sequence foo_id_seq\n",
+	} {
+		if !strings.Contains(string(golden), want) {
+			t.Errorf("Expected %q in the golden schema:\n%s", want, golden)
+		}
+	}
+	if strings.Contains(string(golden), "_migrate") {
+		t.Errorf("Expected the tracking tables to be left out:\n%s", golden)
+	}
+
+	recorder = &recordingT{}
+	VerifyGoldenSchema(recorder, conn, migrateDir, goldenFile, WithForwardOnly(true))
+	if len(recorder.errors) != 0 {
+		t.Fatalf("Expected the schema to match, got %v", recorder.errors)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(migrateDir, "002-bar.up.sql"), []byte("ALTER TABLE foo ADD COLUMN bar int;"), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	recorder = &recordingT{}
+	VerifyGoldenSchema(recorder, conn, migrateDir, goldenFile, WithForwardOnly(true))
+	if len(recorder.errors) != 1 || !strings.Contains(recorder.errors[0], "+   column bar integer") {
+		t.Errorf("Expected the new column to be reported, got %v", recorder.errors)
+	}
+}
@@ -0,0 +1,82 @@
+package pgmigrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// GoMigrationFunc is a migration step written in Go, for data changes which
+// are impractical in SQL. It runs in the same transaction which records the
+// new version, so it is committed or rolled back along with it.
+type GoMigrationFunc func(ctx context.Context, tx *sql.Tx) error
+
+// WithGoMigrations adds migrations with UpFunc and DownFunc set to those from
+// the source. They share one version sequence, so a Go migration can sit
+// between, or fill in a direction of, the SQL files.
+func WithGoMigrations(migrations ...Migration) Option {
+	return func(cfg *config) {
+		cfg.goMigrations = append(cfg.goMigrations, migrations...)
+	}
+}
+
+// goSource merges Go migrations into the migrations listed by a source
+type goSource struct {
+	MigrationSource
+	migrations []Migration
+}
+
+func (s goSource) List() ([]Migration, error) {
+	migrations, err := s.MigrationSource.List()
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]int{}
+	for idx, migration := range migrations {
+		byVersion[migration.Version] = idx
+	}
+
+	for _, goMigration := range s.migrations {
+		idx, ok := byVersion[goMigration.Version]
+		if !ok {
+			byVersion[goMigration.Version] = len(migrations)
+			migrations = append(migrations, Migration{
+				Version:  goMigration.Version,
+				Name:     goMigration.Name,
+				UpFunc:   goMigration.UpFunc,
+				DownFunc: goMigration.DownFunc,
+			})
+			continue
+		}
+
+		migration := &migrations[idx]
+		if goMigration.UpFunc != nil {
+			if migration.UpFile != "" {
+				return nil, fmt.Errorf("migration %d has both %s and a Go up function", goMigration.Version, migration.UpFile)
+			}
+			if migration.UpFunc != nil {
+				return nil, fmt.Errorf("migration %d has more than one Go up function", goMigration.Version)
+			}
+			migration.UpFunc = goMigration.UpFunc
+		}
+		if goMigration.DownFunc != nil {
+			if migration.DownFile != "" {
+				return nil, fmt.Errorf("migration %d has both %s and a Go down function", goMigration.Version, migration.DownFile)
+			}
+			if migration.DownFunc != nil {
+				return nil, fmt.Errorf("migration %d has more than one Go down function", goMigration.Version)
+			}
+			migration.DownFunc = goMigration.DownFunc
+		}
+		if migration.Name == "" {
+			migration.Name = goMigration.Name
+		}
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
@@ -0,0 +1,111 @@
+package pgmigrate
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+)
+
+func noopMigration(ctx context.Context, tx *sql.Tx) error {
+	return nil
+}
+
+func TestGoSource(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"002-bar.up.sql":   s2u,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	source := goSource{
+		MigrationSource: DirSource(migrateDir),
+		migrations: []Migration{
+			{Version: 3, Name: "backfill", UpFunc: noopMigration, DownFunc: noopMigration},
+			{Version: 2, DownFunc: noopMigration},
+		},
+	}
+
+	migrations, err := source.List()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 3 {
+		t.Fatalf("Expected 3 migrations, got %d", len(migrations))
+	}
+	for idx, migration := range migrations {
+		if migration.Version != idx+1 {
+			t.Errorf("Migration %d has version %d", idx, migration.Version)
+		}
+		if !migration.hasUp() || !migration.hasDown() {
+			t.Errorf("Migration %d is missing a direction", migration.Version)
+		}
+	}
+	if migrations[1].UpFile != "002-bar.up.sql" || migrations[1].DownFunc == nil {
+		t.Errorf("Expected 2 to mix a file and a function, got %+v", migrations[1])
+	}
+	if migrations[2].Name != "backfill" {
+		t.Errorf("Expected 3 to be named backfill, got %s", migrations[2].Name)
+	}
+
+	label := step{Migration: migrations[2], Direction: directionUp}.Filename()
+	if label != "go:3-backfill.up" {
+		t.Errorf("Unexpected label %s", label)
+	}
+
+	source.migrations = []Migration{{Version: 1, UpFunc: noopMigration}}
+	if _, err := source.List(); err == nil {
+		t.Error("Expected an error for a version with a file and a function")
+	}
+}
+
+func TestGoMigrations(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"003-bar.up.sql":   s2u,
+		"003-bar.down.sql": s2d,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_go_migrations")
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	fill := Migration{
+		Version: 2,
+		Name:    "fill",
+		UpFunc: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, `INSERT INTO foo (id) VALUES (1), (2)`)
+			return err
+		},
+		DownFunc: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, `DELETE FROM foo`)
+			return err
+		},
+	}
+
+	if err := MigrateDatabase(ctx, conn, migrateDir, -1, WithGoMigrations(fill)); err != nil {
+		t.Fatalf("Unable to migrate: %s", err.Error())
+	}
+
+	var count int
+	if err := conn.QueryRowContext(ctx, `SELECT count(*) FROM foo`).Scan(&count); err != nil {
+		t.Fatal(err.Error())
+	}
+	if count != 2 {
+		t.Errorf("Expected the Go migration to insert 2 rows, got %d", count)
+	}
+
+	if err := MigrateDatabase(ctx, conn, migrateDir, 1, WithGoMigrations(fill), WithAllowDown(true)); err != nil {
+		t.Fatalf("Unable to migrate down: %s", err.Error())
+	}
+	if err := conn.QueryRowContext(ctx, `SELECT count(*) FROM foo`).Scan(&count); err != nil {
+		t.Fatal(err.Error())
+	}
+	if count != 0 {
+		t.Errorf("Expected the Go down migration to delete the rows, got %d", count)
+	}
+}
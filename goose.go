@@ -0,0 +1,78 @@
+package pgmigrate
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// gooseFilename matches goose's single file migrations, like
+// 00001_create_users.sql, which hold both directions. As with other
+// migrations, they can be templates or gzipped.
+var gooseFilename = regexp.MustCompile(`^[0-9]+_[^.]+\.sql(\.tmpl)?(\.gz)?$`)
+
+// gooseSectionSeparator joins a goose file's name and the direction read
+// from it, as in 00001_create_users.sql#up
+const gooseSectionSeparator = "#"
+
+// gooseDirections stands in for the direction of a goose file's name while
+// it is parsed, as the file is both
+const gooseDirections = "goose"
+
+const gooseAnnotation = "-- +goose"
+
+// gooseSectionFile splits a name from gooseSectionName into the file and
+// direction, with an empty direction for any other name
+func gooseSectionFile(name string) (string, string) {
+	idx := strings.LastIndex(name, gooseSectionSeparator)
+	if idx == -1 {
+		return name, ""
+	}
+	direction := name[idx+1:]
+	if direction != directionUp && direction != directionDown {
+		return name, ""
+	}
+	return name[:idx], direction
+}
+
+func gooseSectionName(name string, direction string) string {
+	return name + gooseSectionSeparator + direction
+}
+
+// gooseSection returns the lines between the -- +goose Up or -- +goose Down
+// annotation for direction and the next one. Goose's NO TRANSACTION
+// annotation becomes the no-transaction directive, and its other
+// annotations, like StatementBegin, are dropped, as each file is sent as a
+// whole.
+func gooseSection(name string, content []byte, direction string) ([]byte, error) {
+	section := &bytes.Buffer{}
+	current := ""
+	foundUp := false
+	noTransaction := false
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		trimmed := strings.TrimSpace(string(line))
+		if !strings.HasPrefix(trimmed, gooseAnnotation) {
+			if current == direction {
+				section.Write(line)
+			}
+			continue
+		}
+		switch annotation := strings.TrimSpace(strings.TrimPrefix(trimmed, gooseAnnotation)); strings.ToLower(annotation) {
+		case directionUp:
+			current = directionUp
+			foundUp = true
+		case directionDown:
+			current = directionDown
+		case "no transaction":
+			noTransaction = true
+		}
+	}
+	if !foundUp {
+		return nil, fmt.Errorf("goose migration %s has no %s Up annotation", name, gooseAnnotation)
+	}
+	if noTransaction && section.Len() > 0 {
+		return append([]byte(directivePrefix+" no-transaction\n"), section.Bytes()...), nil
+	}
+	return section.Bytes(), nil
+}
@@ -0,0 +1,63 @@
+package pgmigrate
+
+import (
+	"os"
+	"testing"
+)
+
+func TestGooseFiles(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"00001_create_foo.sql": "-- +goose Up\n" + s1u + "\n-- +goose Down\n" + s1d + "\n",
+		"00002_index_foo.sql": "-- +goose NO TRANSACTION\n" +
+			"-- +goose Up\n-- +goose StatementBegin\nCREATE INDEX CONCURRENTLY foo_idx ON foo (id);\n-- +goose StatementEnd\n",
+		"00003_broken.sql":         "SELECT 1;\n",
+		"00004_templated.sql.tmpl": "-- +goose Up\n" + s2u + "\n",
+	})
+	defer os.RemoveAll(migrateDir)
+
+	migrations, err := DirSource(migrateDir).List()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 4 || migrations[0].Name != "create_foo" || migrations[0].UpFile != "00001_create_foo.sql#up" {
+		t.Fatalf("Unexpected migrations %+v", migrations)
+	}
+
+	up, err := migrations[0].UpSQL()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if up != s1u+"\n" {
+		t.Errorf("Wrong up section %q", up)
+	}
+	down, err := migrations[0].DownSQL()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if down != s1d+"\n" {
+		t.Errorf("Wrong down section %q", down)
+	}
+
+	up, err = migrations[1].UpSQL()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	parsed, err := parseDirectives([]byte(up))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !parsed.noTransaction {
+		t.Errorf("Expected NO TRANSACTION to become no-transaction in %q", up)
+	}
+	if down, err := migrations[1].DownSQL(); err != nil || down != "" {
+		t.Errorf("Expected an empty down section, got %q, %v", down, err)
+	}
+
+	if _, err := migrations[2].UpSQL(); err == nil {
+		t.Error("Expected an error for a file without a goose Up annotation")
+	}
+
+	if migrations[3].UpFile != "00004_templated.sql.tmpl#up" || !isTemplate(migrations[3].UpFile) {
+		t.Errorf("Expected a goose template, got %+v", migrations[3])
+	}
+}
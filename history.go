@@ -0,0 +1,137 @@
+package pgmigrate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	directionUp   = "up"
+	directionDown = "down"
+
+	// directionForce records a call to Force, which sets the version without
+	// running anything
+	directionForce = "force"
+
+	// directionBaseline records a call to Baseline, which adopts a database
+	// at a version without running anything
+	directionBaseline = "baseline"
+)
+
+// HistoryEntry records a single execution of a migration file, in either
+// direction. Calls to Force and Baseline are recorded with the directions
+// "force" and "baseline", and runs of repeatable migrations with "repeatable"
+// and version 0.
+type HistoryEntry struct {
+	Version   int
+	Name      string
+	Filename  string
+	Checksum  string
+	Direction string
+	AppliedAt time.Time
+	Duration  time.Duration
+
+	// Variables are those substituted into the file, see WithVariables
+	Variables map[string]string
+}
+
+func (t trackingTable) ensureHistoryTable(ctx context.Context, conn Queryer) error {
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id serial primary key,
+			version bigint not null,
+			name text not null,
+			filename text not null,
+			checksum text not null,
+			direction text not null,
+			applied_at timestamptz not null,
+			duration_ms bigint not null
+		);
+		ALTER TABLE %s ADD COLUMN IF NOT EXISTS variables text not null default '';
+	`, t.historyTable(), t.historyTable()))
+	return err
+}
+
+func (t trackingTable) recordHistory(ctx context.Context, conn execer, entry HistoryEntry) error {
+	variables, err := encodeVariables(entry.Variables)
+	if err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO %s
+		(version, name, filename, checksum, direction, applied_at, duration_ms, variables)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, t.historyTable()),
+		entry.Version,
+		entry.Name,
+		entry.Filename,
+		entry.Checksum,
+		entry.Direction,
+		entry.AppliedAt,
+		entry.Duration.Milliseconds(),
+		variables,
+	)
+	return err
+}
+
+// encodeVariables is how variables are stored in the history, "" for none
+func encodeVariables(vars map[string]string) (string, error) {
+	if len(vars) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(vars)
+	return string(encoded), err
+}
+
+// History returns every recorded migration execution, oldest first.
+func History(ctx context.Context, conn Queryer) ([]HistoryEntry, error) {
+	return New(conn).History(ctx)
+}
+
+// History returns every recorded migration execution, oldest first.
+func (m *Migrator) History(ctx context.Context) ([]HistoryEntry, error) {
+	return m.cfg.table.history(ctx, m.conn)
+}
+
+func (t trackingTable) history(ctx context.Context, conn Queryer) ([]HistoryEntry, error) {
+	// variables is read through to_jsonb so that tables which predate the
+	// column can still be read without altering them
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf(`
+		SELECT version, name, filename, checksum, direction, applied_at, duration_ms,
+			coalesce(to_jsonb(history) ->> 'variables', '')
+		FROM %s AS history ORDER BY id
+	`, t.historyTable()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []HistoryEntry{}
+	for rows.Next() {
+		entry := HistoryEntry{}
+		var durationMS int64
+		var variables string
+		if err := rows.Scan(
+			&entry.Version,
+			&entry.Name,
+			&entry.Filename,
+			&entry.Checksum,
+			&entry.Direction,
+			&entry.AppliedAt,
+			&durationMS,
+			&variables,
+		); err != nil {
+			return nil, err
+		}
+		if variables != "" {
+			if err := json.Unmarshal([]byte(variables), &entry.Variables); err != nil {
+				return nil, fmt.Errorf("reading variables of history entry for %s: %w", entry.Filename, err)
+			}
+		}
+		entry.Duration = time.Duration(durationMS) * time.Millisecond
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
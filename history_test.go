@@ -0,0 +1,50 @@
+package pgmigrate
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_history")
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	if err := MigrateDatabase(ctx, conn, migrateDir, 2); err != nil {
+		t.Fatalf("Unable to migrate: %s", err.Error())
+	}
+	if err := MigrateDatabase(ctx, conn, migrateDir, 1, WithAllowDown(true)); err != nil {
+		t.Fatalf("Unable to migrate: %s", err.Error())
+	}
+
+	entries, err := History(ctx, conn)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expect := []struct {
+		version   int
+		direction string
+	}{
+		{1, directionUp},
+		{2, directionUp},
+		{2, directionDown},
+	}
+	if len(entries) != len(expect) {
+		t.Fatalf("Expected %d history entries, got %d", len(expect), len(entries))
+	}
+	for idx, want := range expect {
+		got := entries[idx]
+		if got.Version != want.version || got.Direction != want.direction {
+			t.Errorf("Entry %d: got %d %s, want %d %s", idx, got.Version, got.Direction, want.version, want.direction)
+		}
+		if got.Checksum == "" {
+			t.Errorf("Entry %d: missing checksum", idx)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package pgmigrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Hooks are called around each migration, for work which belongs to the
+// application rather than a file, such as refreshing a materialized view
+// after a particular version, or paging someone when a deploy fails. Any of
+// them can be nil.
+type Hooks struct {
+	// BeforeEach and AfterEach run in the transaction which records the new
+	// version, before and after the file or Go function, and an error from
+	// either fails the migration. Files marked no-transaction have already
+	// run by the time BeforeEach is called.
+	BeforeEach func(ctx context.Context, tx *sql.Tx, migration Migration, direction string) error
+	AfterEach  func(ctx context.Context, tx *sql.Tx, migration Migration, direction string) error
+
+	// OnError is called when a migration fails. Its context isn't cancelled
+	// along with the run, so it can still report an interrupted one.
+	OnError func(ctx context.Context, migration Migration, direction string, err error)
+}
+
+// WithHooks calls hooks around each migration
+func WithHooks(hooks Hooks) Option {
+	return func(cfg *config) {
+		cfg.hooks = hooks
+	}
+}
+
+func (hooks Hooks) beforeEach(ctx context.Context, tx *sql.Tx, step step) error {
+	if hooks.BeforeEach == nil {
+		return nil
+	}
+	if err := hooks.BeforeEach(ctx, tx, step.Migration, step.Direction); err != nil {
+		return fmt.Errorf("before %s: %w", step.Filename(), err)
+	}
+	return nil
+}
+
+func (hooks Hooks) afterEach(ctx context.Context, tx *sql.Tx, step step) error {
+	if hooks.AfterEach == nil {
+		return nil
+	}
+	if err := hooks.AfterEach(ctx, tx, step.Migration, step.Direction); err != nil {
+		return fmt.Errorf("after %s: %w", step.Filename(), err)
+	}
+	return nil
+}
+
+func (hooks Hooks) onError(ctx context.Context, step step, err error) {
+	if hooks.OnError != nil {
+		hooks.OnError(context.WithoutCancel(ctx), step.Migration, step.Direction, err)
+	}
+}
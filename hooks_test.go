@@ -0,0 +1,95 @@
+package pgmigrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestHooksOnError(t *testing.T) {
+	var failed []int
+	cfg := newConfig([]Option{WithHooks(Hooks{
+		OnError: func(ctx context.Context, migration Migration, direction string, err error) {
+			failed = append(failed, migration.Version)
+		},
+	})})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	migration := Migration{Version: 4, Name: "foo", UpFile: "004-foo.up.sql"}
+	if err := runFile(ctx, nil, failingSource{}, step{Migration: migration, Direction: directionUp}, cfg); err == nil {
+		t.Fatal("Expected the read to fail")
+	}
+	if len(failed) != 1 || failed[0] != 4 {
+		t.Errorf("Expected OnError for version 4, got %v", failed)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"002-bar.up.sql":   s2u,
+		"002-bar.down.sql": s2d,
+		"003-bad.up.sql":   "CREATE TABLE baz (id int REFERENCES missing (id));",
+		"003-bad.down.sql": s3d,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_hooks")
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	var before, after, failed []int
+	hooks := Hooks{
+		BeforeEach: func(ctx context.Context, tx *sql.Tx, migration Migration, direction string) error {
+			before = append(before, migration.Version)
+			return nil
+		},
+		AfterEach: func(ctx context.Context, tx *sql.Tx, migration Migration, direction string) error {
+			after = append(after, migration.Version)
+			if migration.Version == 2 && direction == directionUp {
+				_, err := tx.ExecContext(ctx, "INSERT INTO bar (id) VALUES (1)")
+				return err
+			}
+			return nil
+		},
+		OnError: func(ctx context.Context, migration Migration, direction string, err error) {
+			failed = append(failed, migration.Version)
+		},
+	}
+
+	if err := New(conn, WithSource(DirSource(migrateDir)), WithHooks(hooks)).Up(ctx); err == nil {
+		t.Fatal("Expected 003 to fail")
+	}
+	if len(before) != 3 || len(after) != 2 || len(failed) != 1 || failed[0] != 3 {
+		t.Errorf("Wrong hook calls: before %v after %v failed %v", before, after, failed)
+	}
+
+	var count int
+	if err := conn.QueryRowContext(ctx, "SELECT count(*) FROM bar").Scan(&count); err != nil {
+		t.Fatal(err.Error())
+	}
+	if count != 1 {
+		t.Errorf("Expected AfterEach to insert into bar, got %d rows", count)
+	}
+
+	failing := Hooks{
+		BeforeEach: func(ctx context.Context, tx *sql.Tx, migration Migration, direction string) error {
+			return errors.New("not today")
+		},
+	}
+	if err := New(conn, WithSource(DirSource(migrateDir)), WithHooks(failing), WithAllowDown(true)).To(ctx, 1); err == nil {
+		t.Fatal("Expected BeforeEach to fail the migration")
+	}
+	version, err := New(conn).ReadVersion(ctx)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if version != 2 {
+		t.Errorf("Expected the failed hook to leave version 2, got %d", version)
+	}
+}
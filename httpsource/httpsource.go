@@ -0,0 +1,199 @@
+// Package httpsource reads pgmigrate migrations from an HTTP(S) server which
+// publishes a manifest alongside the files, for deploy agents pulling from an
+// internal artifact server. Every file is checked against the checksum in the
+// manifest before it is returned to be run.
+package httpsource
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.daemonl.com/pgmigrate"
+)
+
+// Manifest lists the migrations a server publishes
+type Manifest struct {
+	Migrations []Entry `json:"migrations"`
+}
+
+// Entry is one version in a Manifest. File names are relative to the
+// manifest's URL, and checksums are hex encoded SHA-256 of the file.
+type Entry struct {
+	Version      int    `json:"version"`
+	Name         string `json:"name"`
+	UpFile       string `json:"upFile,omitempty"`
+	UpChecksum   string `json:"upChecksum,omitempty"`
+	DownFile     string `json:"downFile,omitempty"`
+	DownChecksum string `json:"downChecksum,omitempty"`
+}
+
+// BuildManifest describes the migrations in source, for publishing along with
+// its files
+func BuildManifest(source pgmigrate.MigrationSource) (*Manifest, error) {
+	migrations, err := source.List()
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{Migrations: []Entry{}}
+	for _, migration := range migrations {
+		entry := Entry{
+			Version:  migration.Version,
+			Name:     migration.Name,
+			UpFile:   migration.UpFile,
+			DownFile: migration.DownFile,
+		}
+		for _, file := range []struct {
+			name string
+			sum  *string
+		}{
+			{migration.UpFile, &entry.UpChecksum},
+			{migration.DownFile, &entry.DownChecksum},
+		} {
+			if file.name == "" {
+				continue
+			}
+			content, err := source.ReadFile(file.name)
+			if err != nil {
+				return nil, err
+			}
+			*file.sum = checksum(content)
+		}
+		manifest.Migrations = append(manifest.Migrations, entry)
+	}
+	return manifest, nil
+}
+
+// Source is a pgmigrate.MigrationSource reading the manifest at a URL, then
+// the files it lists
+type Source struct {
+	ctx         context.Context
+	client      *http.Client
+	manifestURL *url.URL
+
+	lock      sync.Mutex
+	checksums map[string]string
+}
+
+// New creates a Source for the manifest at manifestURL. ctx is used for every
+// request, and a nil client uses http.DefaultClient.
+func New(ctx context.Context, client *http.Client, manifestURL string) (*Source, error) {
+	parsed, err := url.Parse(manifestURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing manifest URL: %w", err)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Source{
+		ctx:         ctx,
+		client:      client,
+		manifestURL: parsed,
+	}, nil
+}
+
+func (s *Source) List() ([]pgmigrate.Migration, error) {
+	manifest := Manifest{}
+	body, err := s.get(s.manifestURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetching manifest: %w", err)
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+
+	checksums := map[string]string{}
+	migrations := make([]pgmigrate.Migration, 0, len(manifest.Migrations))
+	for _, entry := range manifest.Migrations {
+		if (entry.UpFile != "" && entry.UpChecksum == "") || (entry.DownFile != "" && entry.DownChecksum == "") {
+			return nil, fmt.Errorf("manifest entry %d is missing a checksum", entry.Version)
+		}
+		if entry.UpFile != "" {
+			checksums[entry.UpFile] = strings.ToLower(entry.UpChecksum)
+		}
+		if entry.DownFile != "" {
+			checksums[entry.DownFile] = strings.ToLower(entry.DownChecksum)
+		}
+		migrations = append(migrations, pgmigrate.Migration{
+			Version:  entry.Version,
+			Name:     entry.Name,
+			UpFile:   entry.UpFile,
+			DownFile: entry.DownFile,
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	s.lock.Lock()
+	s.checksums = checksums
+	s.lock.Unlock()
+	return migrations, nil
+}
+
+// ReadFile fetches a file listed in the manifest, failing if its content
+// doesn't match the manifest's checksum
+func (s *Source) ReadFile(name string) ([]byte, error) {
+	s.lock.Lock()
+	listed := s.checksums != nil
+	s.lock.Unlock()
+	if !listed {
+		if _, err := s.List(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.lock.Lock()
+	expect, ok := s.checksums[name]
+	s.lock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%s is not in the manifest", name)
+	}
+
+	fileURL, err := s.manifestURL.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.get(fileURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", name, err)
+	}
+	if sum := checksum(content); sum != expect {
+		return nil, fmt.Errorf("%s has checksum %s, but the manifest has %s", name, sum, expect)
+	}
+	return content, nil
+}
+
+func (s *Source) get(target *url.URL) ([]byte, error) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", target, res.Status)
+	}
+	return body, nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,79 @@
+package httpsource
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.daemonl.com/pgmigrate"
+)
+
+func TestSource(t *testing.T) {
+	files := pgmigrate.MemorySource{
+		1: {Name: "foo", Up: `CREATE TABLE foo (id int);`, Down: `DROP TABLE foo;`},
+		2: {Name: "bar", Up: `CREATE TABLE bar (id int);`},
+	}
+	manifest, err := BuildManifest(files)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	served := map[string]string{}
+	for _, entry := range manifest.Migrations {
+		for _, name := range []string{entry.UpFile, entry.DownFile} {
+			if name == "" {
+				continue
+			}
+			content, err := files.ReadFile(name)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			served["/release/"+name] = string(content)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/release/manifest.json" {
+			json.NewEncoder(w).Encode(manifest) //nolint: errcheck
+			return
+		}
+		content, ok := served[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content)) //nolint: errcheck
+	}))
+	defer server.Close()
+
+	source, err := New(context.Background(), server.Client(), server.URL+"/release/manifest.json")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	migrations, err := source.List()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 2 || migrations[0].DownFile != "1-foo.down.sql" || migrations[1].DownFile != "" {
+		t.Fatalf("Unexpected migrations %+v", migrations)
+	}
+
+	content, err := source.ReadFile("2-bar.up.sql")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(content) != `CREATE TABLE bar (id int);` {
+		t.Errorf("Wrong content %q", string(content))
+	}
+
+	served["/release/2-bar.up.sql"] = `DROP TABLE everything;`
+	if _, err := source.ReadFile("2-bar.up.sql"); err == nil {
+		t.Error("Expected an error for a file which doesn't match the manifest")
+	}
+	if _, err := source.ReadFile("3-baz.up.sql"); err == nil {
+		t.Error("Expected an error for a file not in the manifest")
+	}
+}
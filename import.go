@@ -0,0 +1,96 @@
+package pgmigrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ImportGolangMigrate adopts a database migrated by golang-migrate, recording
+// the version from its tracking table as applied, so a project can switch
+// tools without resetting its databases. table is golang-migrate's table,
+// schema_migrations if empty. golang-migrate's file names, like
+// 000001_create_users.up.sql, are understood as they are.
+func (m *Migrator) ImportGolangMigrate(ctx context.Context, table string) error {
+	if table == "" {
+		table = "schema_migrations"
+	}
+	source := parseTableName(table)
+
+	return m.withSession(ctx, func(conn Queryer) error {
+		var version int
+		var dirty bool
+		if err := conn.QueryRowContext(ctx, fmt.Sprintf(`SELECT version, dirty FROM %s LIMIT 1`, source.versionTable())).Scan(&version, &dirty); err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%s has no version to import", table)
+			}
+			return fmt.Errorf("reading %s: %w", table, err)
+		}
+		if dirty {
+			return fmt.Errorf("golang-migrate left version %d dirty, fix it with golang-migrate before importing", version)
+		}
+		if version < 1 {
+			return fmt.Errorf("%s has no version to import", table)
+		}
+		return m.adopt(ctx, conn, version, "golang-migrate")
+	})
+}
+
+// ImportFlyway adopts a database migrated by Flyway, replaying the versioned
+// rows of its history table, flyway_schema_history if table is empty, and
+// recording the highest version still applied. Repeatable rows, which have no
+// version, are skipped. Flyway's versions must be whole numbers, and a failed
+// row is refused. Flyway's file names, like V1__create_users.sql, are
+// understood as they are.
+func (m *Migrator) ImportFlyway(ctx context.Context, table string) error {
+	if table == "" {
+		table = "flyway_schema_history"
+	}
+	source := parseTableName(table)
+
+	return m.withSession(ctx, func(conn Queryer) error {
+		rows, err := conn.QueryContext(ctx, fmt.Sprintf(`SELECT version, type, success FROM %s WHERE version IS NOT NULL ORDER BY installed_rank`, source.versionTable()))
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", table, err)
+		}
+		defer rows.Close()
+
+		applied := map[int]bool{}
+		for rows.Next() {
+			var versionString, entryType string
+			var success bool
+			if err := rows.Scan(&versionString, &entryType, &success); err != nil {
+				return err
+			}
+			version, err := strconv.Atoi(versionString)
+			if err != nil {
+				return fmt.Errorf("flyway version %q isn't a whole number", versionString)
+			}
+			if !success {
+				return fmt.Errorf("flyway failed to apply version %d, fix it with flyway repair before importing", version)
+			}
+			// undo and delete rows take a version back out
+			if strings.HasPrefix(entryType, "UNDO_") || entryType == "DELETE" {
+				delete(applied, version)
+			} else {
+				applied[version] = true
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		version := 0
+		for appliedVersion := range applied {
+			if appliedVersion > version {
+				version = appliedVersion
+			}
+		}
+		if version < 1 {
+			return fmt.Errorf("%s has no version to import", table)
+		}
+		return m.adopt(ctx, conn, version, "flyway")
+	})
+}
@@ -0,0 +1,130 @@
+package pgmigrate
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestGolangMigrateFilenames(t *testing.T) {
+	migrations, err := ParseFilenames([]string{
+		"000001_create_users.up.sql",
+		"000001_create_users.down.sql",
+		"2_add-index.up.sql",
+		"3.up.sql",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 3 {
+		t.Fatalf("Expected 3 migrations, got %+v", migrations)
+	}
+	if migrations[0].Version != 1 || migrations[0].Name != "create_users" || migrations[0].DownFile != "000001_create_users.down.sql" {
+		t.Errorf("Unexpected first migration %+v", migrations[0])
+	}
+	if migrations[1].Name != "add-index" || migrations[2].Name != "" {
+		t.Errorf("Unexpected names %q and %q", migrations[1].Name, migrations[2].Name)
+	}
+}
+
+func TestImportGolangMigrate(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"000001_foo.up.sql":   s1u,
+		"000001_foo.down.sql": s1d,
+		"000002_bar.up.sql":   s2u,
+		"000002_bar.down.sql": s2d,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_import_golang_migrate")
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := conn.ExecContext(ctx, s1u+`
+		CREATE TABLE schema_migrations (version bigint not null primary key, dirty boolean not null);
+		INSERT INTO schema_migrations VALUES (1, false);
+	`); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	migrator := New(conn, WithSource(DirSource(migrateDir)))
+	if err := migrator.ImportGolangMigrate(ctx, ""); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, err := migrator.Version(ctx); err != nil {
+		t.Fatal(err.Error())
+	} else if v != 2 {
+		t.Fatalf("Expected version 2, got %d", v)
+	}
+}
+
+func TestFlywayFilenames(t *testing.T) {
+	migrations, err := ParseFilenames([]string{
+		"V1__create_users.sql",
+		"U1__create_users.sql",
+		"V2__add_index.sql",
+		"R__views.sql",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("Expected 2 migrations, got %+v", migrations)
+	}
+	if migrations[0].Version != 1 || migrations[0].Name != "create_users" || migrations[0].UpFile != "V1__create_users.sql" || migrations[0].DownFile != "U1__create_users.sql" {
+		t.Errorf("Unexpected first migration %+v", migrations[0])
+	}
+	if migrations[1].Version != 2 || migrations[1].DownFile != "" {
+		t.Errorf("Unexpected second migration %+v", migrations[1])
+	}
+
+	repeatables := ParseRepeatableFilenames([]string{"V1__create_users.sql", "R__views.sql"})
+	if len(repeatables) != 1 || repeatables[0].Name != "views" {
+		t.Errorf("Unexpected repeatables %+v", repeatables)
+	}
+}
+
+func TestImportFlyway(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"V1__foo.sql": s1u,
+		"V2__bar.sql": s2u,
+		"V3__baz.sql": s3u,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_import_flyway")
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := conn.ExecContext(ctx, s1u+s2u+`
+		CREATE TABLE flyway_schema_history (
+			installed_rank int not null primary key,
+			version varchar(50),
+			description varchar(200) not null,
+			type varchar(20) not null,
+			success boolean not null
+		);
+		INSERT INTO flyway_schema_history VALUES
+			(1, '1', 'foo', 'SQL', true),
+			(2, NULL, 'views', 'SQL', true),
+			(3, '2', 'bar', 'SQL', true);
+	`); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	migrator := New(conn, WithSource(DirSource(migrateDir)), WithForwardOnly(true))
+	if err := migrator.ImportFlyway(ctx, ""); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, err := migrator.Version(ctx); err != nil {
+		t.Fatal(err.Error())
+	} else if v != 3 {
+		t.Fatalf("Expected version 3, got %d", v)
+	}
+}
@@ -0,0 +1,85 @@
+package pgmigrate
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// maxIncludeDepth stops include cycles
+const maxIncludeDepth = 10
+
+// includeTarget returns the file named by a line like
+//
+//	-- pgmigrate:include common/audit-triggers.sql
+func includeTarget(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, directivePrefix) {
+		return "", false
+	}
+	fields := strings.Fields(strings.TrimPrefix(line, directivePrefix))
+	if len(fields) != 2 || fields[0] != "include" {
+		return "", false
+	}
+	return fields[1], true
+}
+
+// expandIncludes replaces each include line in content with the content of
+// the file it names, read from source relative to its root, so shared
+// snippets such as audit triggers or grants can be used by many migrations.
+// Included files can include others.
+func expandIncludes(source MigrationSource, filename string, content []byte) ([]byte, error) {
+	return expandIncludesFrom(source, []string{filename}, content)
+}
+
+func expandIncludesFrom(source MigrationSource, stack []string, content []byte) ([]byte, error) {
+	if !bytes.Contains(content, []byte("include")) {
+		return content, nil
+	}
+
+	// Lines are split keeping their endings, so a file is unchanged, and its
+	// checksum the same, unless it includes something
+	expanded := &bytes.Buffer{}
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		target, ok := includeTarget(string(line))
+		if !ok {
+			expanded.Write(line)
+			continue
+		}
+
+		target = strings.TrimPrefix(path.Clean("/"+target), "/")
+		for _, including := range stack {
+			if including == target {
+				return nil, fmt.Errorf("%s includes itself through %s", target, strings.Join(stack, ", "))
+			}
+		}
+		if len(stack) > maxIncludeDepth {
+			return nil, fmt.Errorf("includes in %s are nested more than %d deep", stack[0], maxIncludeDepth)
+		}
+
+		included, err := readMigrationFile(source, target)
+		if err != nil {
+			return nil, fmt.Errorf("including %s in %s: %w", target, stack[len(stack)-1], err)
+		}
+		included, err = expandIncludesFrom(source, append(stack, target), included)
+		if err != nil {
+			return nil, err
+		}
+		expanded.Write(included)
+		if bytes.HasSuffix(line, []byte("\n")) && !bytes.HasSuffix(included, []byte("\n")) {
+			expanded.WriteByte('\n')
+		}
+	}
+	return expanded.Bytes(), nil
+}
+
+// readExpanded reads filename from source with its includes expanded, which
+// is the content checksums are taken over
+func readExpanded(source MigrationSource, filename string) ([]byte, error) {
+	content, err := readMigrationFile(source, filename)
+	if err != nil {
+		return nil, err
+	}
+	return expandIncludes(source, filename, content)
+}
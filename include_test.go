@@ -0,0 +1,65 @@
+package pgmigrate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandIncludes(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql": "-- pgmigrate: statement_timeout=1m\nCREATE TABLE foo (id int);\n-- pgmigrate:include common/audit.sql\nGRANT SELECT ON foo TO reader;",
+		"002-bar.up.sql": "-- we include nothing\r\nCREATE TABLE bar (id int);",
+		"003-baz.up.sql": "-- pgmigrate:include common/loop.sql\n",
+		"004-qux.up.sql": "-- pgmigrate:include common/missing.sql\n",
+	})
+	defer os.RemoveAll(migrateDir)
+	if err := os.Mkdir(filepath.Join(migrateDir, "common"), 0755); err != nil {
+		t.Fatal(err.Error())
+	}
+	for name, content := range map[string]string{
+		"audit.sql":  "CREATE TRIGGER foo_audit AFTER UPDATE ON foo FOR EACH ROW EXECUTE FUNCTION audit();\n-- pgmigrate:include common/grants.sql",
+		"grants.sql": "GRANT SELECT ON foo TO auditor;",
+		"loop.sql":   "-- pgmigrate:include common/loop.sql\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(migrateDir, "common", name), []byte(content), 0660); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	source := DirSource(migrateDir)
+
+	expanded, err := readExpanded(source, "001-foo.up.sql")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expect := "-- pgmigrate: statement_timeout=1m\nCREATE TABLE foo (id int);\nCREATE TRIGGER foo_audit AFTER UPDATE ON foo FOR EACH ROW EXECUTE FUNCTION audit();\nGRANT SELECT ON foo TO auditor;\nGRANT SELECT ON foo TO reader;"
+	if string(expanded) != expect {
+		t.Errorf("Expected %q, got %q", expect, expanded)
+	}
+
+	// The include line isn't mistaken for an unknown directive
+	if parsed, err := parseDirectives([]byte("-- pgmigrate:include common/audit.sql\n-- pgmigrate: no-transaction\n")); err != nil {
+		t.Errorf("Expected the include to be skipped, got %s", err.Error())
+	} else if !parsed.noTransaction {
+		t.Error("Expected the directive after the include to be read")
+	}
+
+	// Files without includes are byte for byte the same, so their
+	// checksums don't change
+	plain, err := readExpanded(source, "002-bar.up.sql")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(plain) != "-- we include nothing\r\nCREATE TABLE bar (id int);" {
+		t.Errorf("Expected the file unchanged, got %q", plain)
+	}
+
+	if _, err := readExpanded(source, "003-baz.up.sql"); err == nil || !strings.Contains(err.Error(), "includes itself") {
+		t.Errorf("Expected a cycle error, got %v", err)
+	}
+	if _, err := readExpanded(source, "004-qux.up.sql"); err == nil || !strings.Contains(err.Error(), "missing.sql") {
+		t.Errorf("Expected a missing include error, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package pgmigrate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestInterrupted(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":    s1u,
+		"001-foo.down.sql":  s1d,
+		"002-slow.up.sql":   "CREATE TABLE bar (id int); SELECT pg_sleep(30);",
+		"002-slow.down.sql": "DROP TABLE bar;",
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_interrupted")
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	migrator := New(conn, WithSource(DirSource(migrateDir)))
+	err := migrator.Up(ctx)
+	interrupted := &InterruptedError{}
+	if !errors.As(err, &interrupted) {
+		t.Fatalf("Expected an InterruptedError, got %v", err)
+	}
+	if interrupted.Version != 1 {
+		t.Errorf("Expected to stop at version 1, got %d", interrupted.Version)
+	}
+
+	// The slow migration was rolled back cleanly, so it can run again
+	if err := migrator.To(context.Background(), 1); err != nil {
+		t.Fatal(err.Error())
+	}
+	var tables int
+	if err := conn.QueryRowContext(context.Background(), `SELECT count(*) FROM pg_tables WHERE schemaname = current_schema() AND tablename = 'bar'`).Scan(&tables); err != nil {
+		t.Fatal(err.Error())
+	}
+	if tables != 0 {
+		t.Error("Expected the interrupted migration to be rolled back")
+	}
+}
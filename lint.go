@@ -0,0 +1,156 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// LintFinding is a statement which takes a heavy lock or rewrites a table
+type LintFinding struct {
+	Version  int    `json:"version"`
+	Filename string `json:"filename"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+func (finding LintFinding) String() string {
+	return fmt.Sprintf("%s: %s: %s", finding.Filename, finding.Rule, finding.Message)
+}
+
+var (
+	lintCreateTable = regexp.MustCompile(`(?i)^CREATE\s+(?:(?:GLOBAL\s+|LOCAL\s+)?(?:TEMP|TEMPORARY|UNLOGGED)\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([\w."]+)`)
+	lintAlterTable  = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?([\w."]+)`)
+	lintCreateIndex = regexp.MustCompile(`(?i)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+(CONCURRENTLY\s+)?.*?\bON\s+(?:ONLY\s+)?([\w."]+)`)
+	lintColumnType  = regexp.MustCompile(`(?i)\bALTER\s+(?:COLUMN\s+)?[\w"]+\s+(?:SET\s+DATA\s+)?TYPE\b`)
+	lintSetNotNull  = regexp.MustCompile(`(?i)\bALTER\s+(?:COLUMN\s+)?[\w"]+\s+SET\s+NOT\s+NULL\b`)
+	lintAddColumn   = regexp.MustCompile(`(?i)\bADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?([\w"]+)\s[^,]*?\bDEFAULT\s+([^,]*)`)
+	lintValidating  = regexp.MustCompile(`(?i)\bADD\s+(?:CONSTRAINT\s+[\w"]+\s+)?(?:FOREIGN\s+KEY|CHECK)\b`)
+	lintUnique      = regexp.MustCompile(`(?i)\bADD\s+(?:CONSTRAINT\s+[\w"]+\s+)?(?:UNIQUE|PRIMARY\s+KEY)\b`)
+	lintNotValid    = regexp.MustCompile(`(?i)\bNOT\s+VALID\b`)
+	lintUsingIndex  = regexp.MustCompile(`(?i)\bUSING\s+INDEX\b`)
+	lintRewrite     = regexp.MustCompile(`(?i)^(?:VACUUM\s+(?:\(\s*)?FULL\b|CLUSTER\b)`)
+	lintVolatile    = regexp.MustCompile(`(?i)\b(?:random|clock_timestamp|timeofday|gen_random_uuid|uuid_generate_v[14]|nextval)\s*\(`)
+)
+
+// constraintKeywords can follow ADD in ALTER TABLE without being a column
+var constraintKeywords = map[string]bool{"CONSTRAINT": true, "UNIQUE": true, "PRIMARY": true, "CHECK": true, "FOREIGN": true, "EXCLUDE": true}
+
+// Lint checks the up files in source for statements known to take heavy
+// locks or rewrite tables: CREATE INDEX without CONCURRENTLY, changing a
+// column's type, SET NOT NULL, adding a column with a default which forces a
+// rewrite, adding foreign key and check constraints without NOT VALID,
+// adding unique constraints without USING INDEX, and VACUUM FULL or CLUSTER.
+// Tables created in the same file are skipped, as nothing else can be using
+// them. serverVersion is the major version of the target server, as before
+// PostgreSQL 11 any default rewrites the table, or 0 for a current server.
+func Lint(source MigrationSource, serverVersion int) ([]LintFinding, error) {
+	migrations, err := listMigrations(source)
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]step, 0, len(migrations))
+	for _, migration := range migrations {
+		if migration.UpFile != "" {
+			steps = append(steps, step{Migration: migration, Direction: directionUp})
+		}
+	}
+	return lintSteps(steps, serverVersion)
+}
+
+// WithLint logs a lint_warning for each Lint finding in the up files about
+// to be applied, before any of them run, as an error so it is seen, with rule
+// and message fields. The run carries on regardless.
+func WithLint(serverVersion int) Option {
+	return func(cfg *config) {
+		cfg.lint = true
+		cfg.lintServerVersion = serverVersion
+	}
+}
+
+func lintSteps(steps []step, serverVersion int) ([]LintFinding, error) {
+	findings := []LintFinding{}
+	for _, step := range steps {
+		if step.Direction != directionUp || step.Func() != nil {
+			continue
+		}
+		content, err := readExpanded(step.source, step.UpFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, finding := range lintSQL(string(content), serverVersion) {
+			finding.Version = step.Version
+			finding.Filename = step.UpFile
+			findings = append(findings, finding)
+		}
+	}
+	return findings, nil
+}
+
+func lintSQL(content string, serverVersion int) []LintFinding {
+	findings := []LintFinding{}
+	add := func(rule string, format string, args ...interface{}) {
+		findings = append(findings, LintFinding{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	created := map[string]bool{}
+	for _, statement := range SplitStatements(content) {
+		statement = strings.Join(strings.Fields(stripComments(statement)), " ")
+
+		if match := lintCreateTable.FindStringSubmatch(statement); match != nil {
+			created[lintTableName(match[1])] = true
+			continue
+		}
+
+		if match := lintCreateIndex.FindStringSubmatch(statement); match != nil {
+			table := lintTableName(match[2])
+			if match[1] == "" && !created[table] {
+				add("create-index", "CREATE INDEX without CONCURRENTLY blocks writes to %s while the index builds", table)
+			}
+			continue
+		}
+
+		if lintRewrite.MatchString(statement) {
+			add("rewrite-table", "VACUUM FULL and CLUSTER rewrite the table under an ACCESS EXCLUSIVE lock")
+			continue
+		}
+
+		match := lintAlterTable.FindStringSubmatch(statement)
+		if match == nil {
+			continue
+		}
+		table := lintTableName(match[1])
+		if created[table] {
+			continue
+		}
+
+		if lintColumnType.MatchString(statement) {
+			add("alter-column-type", "changing a column's type rewrites %s under an ACCESS EXCLUSIVE lock", table)
+		}
+		if lintSetNotNull.MatchString(statement) {
+			add("set-not-null", "SET NOT NULL scans %s under an ACCESS EXCLUSIVE lock, validate a CHECK (column IS NOT NULL) NOT VALID constraint first", table)
+		}
+		for _, column := range lintAddColumn.FindAllStringSubmatch(statement, -1) {
+			if constraintKeywords[strings.ToUpper(column[1])] {
+				continue
+			}
+			if serverVersion > 0 && serverVersion < 11 {
+				add("add-column-default", "adding column %s with a default rewrites %s before PostgreSQL 11", column[1], table)
+			} else if lintVolatile.MatchString(column[2]) {
+				add("add-column-default", "adding column %s with a volatile default rewrites %s", column[1], table)
+			}
+		}
+		if lintValidating.MatchString(statement) && !lintNotValid.MatchString(statement) {
+			add("add-constraint", "adding a foreign key or check constraint scans %s while holding its lock, add it NOT VALID and VALIDATE CONSTRAINT separately", table)
+		}
+		if lintUnique.MatchString(statement) && !lintUsingIndex.MatchString(statement) {
+			add("add-unique-constraint", "adding a unique constraint or primary key builds its index under an ACCESS EXCLUSIVE lock on %s, build the index CONCURRENTLY and add the constraint USING INDEX", table)
+		}
+	}
+	return findings
+}
+
+// lintTableName normalises a table name for comparison
+func lintTableName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, `"`, ""))
+}
@@ -0,0 +1,61 @@
+package pgmigrate
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLintSQL(t *testing.T) {
+	for _, tc := range []struct {
+		sql           string
+		serverVersion int
+		rules         []string
+	}{
+		{"CREATE INDEX foo_idx ON foo (id);", 0, []string{"create-index"}},
+		{"CREATE UNIQUE INDEX CONCURRENTLY foo_idx ON foo (id);", 0, nil},
+		{"CREATE TABLE foo (id int); CREATE INDEX foo_idx ON foo (id); ALTER TABLE foo ADD CONSTRAINT foo_pk PRIMARY KEY (id);", 0, nil},
+		{"ALTER TABLE foo ALTER COLUMN id TYPE bigint;", 0, []string{"alter-column-type"}},
+		{"ALTER TABLE foo ALTER COLUMN id SET NOT NULL;", 0, []string{"set-not-null"}},
+		{"ALTER TABLE foo ADD COLUMN created timestamptz NOT NULL DEFAULT now();", 0, nil},
+		{"ALTER TABLE foo ADD COLUMN created timestamptz NOT NULL DEFAULT now();", 10, []string{"add-column-default"}},
+		{"ALTER TABLE foo ADD COLUMN token uuid DEFAULT gen_random_uuid();", 0, []string{"add-column-default"}},
+		{"ALTER TABLE foo ADD CONSTRAINT foo_bar_fk FOREIGN KEY (bar_id) REFERENCES bar (id);", 0, []string{"add-constraint"}},
+		{"ALTER TABLE foo ADD CONSTRAINT foo_bar_fk FOREIGN KEY (bar_id) REFERENCES bar (id) NOT VALID;", 0, nil},
+		{"ALTER TABLE foo ADD CONSTRAINT foo_key UNIQUE (key);", 0, []string{"add-unique-constraint"}},
+		{"ALTER TABLE foo ADD CONSTRAINT foo_key UNIQUE USING INDEX foo_key_idx;", 0, nil},
+		{"VACUUM FULL foo;", 0, []string{"rewrite-table"}},
+		{"-- CREATE INDEX foo_idx ON foo (id);\nSELECT 'CREATE INDEX x ON y';", 0, nil},
+	} {
+		findings := lintSQL(tc.sql, tc.serverVersion)
+		rules := []string{}
+		for _, finding := range findings {
+			rules = append(rules, finding.Rule)
+		}
+		if len(rules) != len(tc.rules) {
+			t.Errorf("%q: expected %v, got %v", tc.sql, tc.rules, rules)
+			continue
+		}
+		for idx := range rules {
+			if rules[idx] != tc.rules[idx] {
+				t.Errorf("%q: expected %v, got %v", tc.sql, tc.rules, rules)
+			}
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   "CREATE TABLE foo (id int);\nCREATE INDEX foo_idx ON foo (id);",
+		"001-foo.down.sql": "DROP TABLE foo;",
+		"002-bar.up.sql":   "CREATE INDEX foo_id_idx ON foo (id);",
+	})
+	defer os.RemoveAll(migrateDir)
+
+	findings, err := Lint(DirSource(migrateDir), 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(findings) != 1 || findings[0].Version != 2 || findings[0].Filename != "002-bar.up.sql" {
+		t.Errorf("Unexpected findings %+v", findings)
+	}
+}
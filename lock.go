@@ -0,0 +1,86 @@
+package pgmigrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+)
+
+// connPool is satisfied by *sql.DB. Session level advisory locks and settings
+// belong to a single server connection, so a pool has to be pinned to one for
+// the run.
+type connPool interface {
+	Conn(context.Context) (*sql.Conn, error)
+}
+
+// lockKey derives the pg_advisory_lock key from the migration table name, so
+// that migrators sharing a table exclude each other and no one else.
+func lockKey(table string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(table)) //nolint: errcheck
+	return int64(hash.Sum64())
+}
+
+// withSession runs callback on a single database session, holding the
+// advisory lock unless it has been turned off, with the search_path set for
+// WithSchema.
+func (m *Migrator) withSession(ctx context.Context, callback func(Queryer) error) error {
+	return m.withReadSession(ctx, func(conn Queryer) error {
+		if !m.cfg.lock {
+			return callback(conn)
+		}
+		return m.withLock(ctx, conn, callback)
+	})
+}
+
+// withReadSession runs callback on a single database session with the
+// search_path set for WithSchema, but without the advisory lock, so reads
+// don't wait for a run in progress
+func (m *Migrator) withReadSession(ctx context.Context, callback func(Queryer) error) error {
+	conn := m.conn
+	if pool, ok := conn.(connPool); ok {
+		dedicated, err := pool.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer dedicated.Close()
+		conn = dedicated
+	}
+
+	resetSearchPath, err := m.setSearchPath(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer resetSearchPath()
+
+	return callback(conn)
+}
+
+// withLock runs callback holding the advisory lock on conn's session
+func (m *Migrator) withLock(ctx context.Context, conn Queryer, callback func(Queryer) error) error {
+	key := m.cfg.table.lockKey()
+	acquired := false
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&acquired); err != nil {
+		return fmt.Errorf("acquiring migration lock: %w", err)
+	}
+	if !acquired {
+		// Another migrator has it, so wait for as long as ctx allows
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, key); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("%w: %w", ErrLocked, err)
+			}
+			return fmt.Errorf("acquiring migration lock: %w", err)
+		}
+	}
+
+	callbackErr := callback(conn)
+
+	// The lock must be released even if ctx has been cancelled, otherwise it
+	// stays with the session when the connection goes back to the pool.
+	if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, key); err != nil && callbackErr == nil {
+		return fmt.Errorf("releasing migration lock: %w", err)
+	}
+
+	return callbackErr
+}
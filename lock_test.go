@@ -0,0 +1,78 @@
+package pgmigrate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentMigrate(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_lock")
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	errs := make([]error, 4)
+	wg := sync.WaitGroup{}
+	for idx := range errs {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			errs[idx] = MigrateDatabase(ctx, conn, migrateDir, -1)
+		}(idx)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("Unable to migrate: %s", err.Error())
+		}
+	}
+
+	entries, err := History(ctx, conn)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected each migration to run once, got %d runs", len(entries))
+	}
+}
+
+func TestLockKey(t *testing.T) {
+	if lockKey("_migrate_") != lockKey("_migrate_") {
+		t.Error("Lock key should be stable")
+	}
+	if lockKey("_migrate_") == lockKey("other") {
+		t.Error("Lock keys should differ between tables")
+	}
+}
+
+func TestLockedError(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_locked_error")
+	defer conn.Close()
+
+	ctx := context.Background()
+	holder, err := conn.Conn(ctx)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer holder.Close()
+	if _, err := holder.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, defaultTable.lockKey()); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	if err := MigrateDatabase(waitCtx, conn, migrateDir, -1); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+}
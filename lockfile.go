@@ -0,0 +1,172 @@
+package pgmigrate
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LockFileName is the name of the lock file kept with the migrations
+const LockFileName = "pgmigrate.lock"
+
+// lockEntry is a line of a lock file, the checksum of one direction of a
+// version
+type lockEntry struct {
+	version   int
+	direction string
+	checksum  string
+	filename  string
+}
+
+func (entry lockEntry) key() string {
+	return fmt.Sprintf("%d %s", entry.version, entry.direction)
+}
+
+// WithLockFile checks, before anything runs, that the migration files match
+// the checksums in name, a lock file written by WriteLockFile and read from
+// the migration source, so edited or missing files are caught before they
+// reach a database.
+func WithLockFile(name string) Option {
+	return func(cfg *config) {
+		cfg.lockFile = name
+	}
+}
+
+// WriteLockFile writes the version and SHA-256 checksum of each migration
+// file in source, as pgmigrate.lock is kept. Go migrations have no file, and
+// aren't included.
+func WriteLockFile(source MigrationSource, w io.Writer) error {
+	migrations, err := listMigrations(source)
+	if err != nil {
+		return err
+	}
+	entries, err := lockEntries(migrations)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "# Written by pgmigrate lock, regenerate it rather than editing\n")
+	for _, entry := range entries {
+		if _, err := fmt.Fprintf(w, "%d %s %s %s\n", entry.version, entry.direction, entry.checksum, entry.filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyLockFile compares the migration files in source with the lock file
+// content, listing every file which was edited, added or removed since it was
+// written
+func VerifyLockFile(source MigrationSource, lockFile []byte) error {
+	migrations, err := listMigrations(source)
+	if err != nil {
+		return err
+	}
+	return verifyLock(migrations, lockFile)
+}
+
+func verifyLock(migrations []Migration, lockFile []byte) error {
+	locked, err := parseLockFile(lockFile)
+	if err != nil {
+		return err
+	}
+	entries, err := lockEntries(migrations)
+	if err != nil {
+		return err
+	}
+
+	problems := []string{}
+	for _, entry := range entries {
+		lockedEntry, ok := locked[entry.key()]
+		delete(locked, entry.key())
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s isn't in the lock file", entry.filename))
+		} else if lockedEntry.checksum != entry.checksum {
+			problems = append(problems, fmt.Sprintf("%s has changed since it was locked", entry.filename))
+		}
+	}
+	missing := make([]lockEntry, 0, len(locked))
+	for _, entry := range locked {
+		missing = append(missing, entry)
+	}
+	sortLockEntries(missing)
+	for _, entry := range missing {
+		problems = append(problems, fmt.Sprintf("%s is in the lock file but missing", entry.filename))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("migrations don't match %s: %s", LockFileName, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func lockEntries(migrations []Migration) ([]lockEntry, error) {
+	entries := []lockEntry{}
+	for _, migration := range migrations {
+		for _, file := range []struct {
+			name      string
+			direction string
+		}{
+			{migration.UpFile, directionUp},
+			{migration.DownFile, directionDown},
+		} {
+			if file.name == "" {
+				continue
+			}
+			if migration.source == nil {
+				return nil, fmt.Errorf("migration %d was not listed from a source, so %s can't be read", migration.Version, file.name)
+			}
+			content, err := readExpanded(migration.source, file.name)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, lockEntry{
+				version:   migration.Version,
+				direction: file.direction,
+				checksum:  checksum(content),
+				filename:  file.name,
+			})
+		}
+	}
+	sortLockEntries(entries)
+	return entries, nil
+}
+
+func sortLockEntries(entries []lockEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].version != entries[j].version {
+			return entries[i].version < entries[j].version
+		}
+		// up before down
+		return entries[i].direction > entries[j].direction
+	})
+}
+
+func parseLockFile(content []byte) (map[string]lockEntry, error) {
+	entries := map[string]lockEntry{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("%s line %d: expected a version, direction, checksum and file name", LockFileName, lineNumber)
+		}
+		version, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: bad version %q", LockFileName, lineNumber, fields[0])
+		}
+		if fields[1] != directionUp && fields[1] != directionDown {
+			return nil, fmt.Errorf("%s line %d: bad direction %q", LockFileName, lineNumber, fields[1])
+		}
+		entry := lockEntry{version: version, direction: fields[1], checksum: fields[2], filename: fields[3]}
+		entries[entry.key()] = entry
+	}
+	return entries, scanner.Err()
+}
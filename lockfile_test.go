@@ -0,0 +1,47 @@
+package pgmigrate
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLockFile(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	lockFile := &bytes.Buffer{}
+	if err := WriteLockFile(DirSource(migrateDir), lockFile); err != nil {
+		t.Fatal(err.Error())
+	}
+	if lines := strings.Split(strings.TrimSpace(lockFile.String()), "\n"); len(lines) != 7 || !strings.HasPrefix(lines[1], "1 up "+checksum([]byte(s1u))) {
+		t.Fatalf("Unexpected lock file %q", lockFile.String())
+	}
+	if err := ioutil.WriteFile(filepath.Join(migrateDir, LockFileName), lockFile.Bytes(), 0660); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := ParseDir(migrateDir, WithLockFile(LockFileName)); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(migrateDir, "002-bar.up.sql"), []byte(s2u+"\n-- edited"), 0660); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := os.Remove(filepath.Join(migrateDir, "003-baz.down.sql")); err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err := ParseDir(migrateDir, WithLockFile(LockFileName), WithForwardOnly(true))
+	if err == nil {
+		t.Fatal("Expected the edited and missing files to be reported")
+	}
+	if !strings.Contains(err.Error(), "002-bar.up.sql has changed") || !strings.Contains(err.Error(), "003-baz.down.sql is in the lock file but missing") {
+		t.Errorf("Unexpected error %s", err.Error())
+	}
+
+	if err := VerifyLockFile(DirSource(migrateDir), []byte("1 up abc\n")); err == nil {
+		t.Error("Expected an error for a malformed lock file")
+	}
+}
@@ -0,0 +1,47 @@
+package pgmigrate
+
+import (
+	"context"
+	"time"
+)
+
+// lockRetryDelay is the wait before the first retry, doubling for each
+// after it
+const lockRetryDelay = time.Second
+
+// WithLockTimeout runs files with timeout as their lock_timeout, unless they
+// set their own with a directive, so DDL waiting behind a long running
+// transaction gives up rather than queueing every other query on the table
+// behind it. A file which gives up is rolled back and tried again, up to
+// retries more times, waiting a second before the first retry and twice as
+// long before each after it. Each retry logs lock_retry as an error, with
+// attempt, of, delay and error fields.
+func WithLockTimeout(timeout time.Duration, retries int) Option {
+	return func(cfg *config) {
+		cfg.lockTimeout = timeout
+		cfg.lockRetries = retries
+	}
+}
+
+// retryLock reports whether a step which failed with err should run again,
+// having waited for the attempt's delay. Only failures to get a lock are
+// retried, and only once the failed attempt has been rolled back, which a
+// file without a transaction can't be, and neither can one file of a single
+// transaction run.
+func (cfg *config) retryLock(ctx context.Context, conn Queryer, step step, err error, attempt int) bool {
+	if attempt > cfg.lockRetries || sqlState(err) != sqlStateLockNotAvailable || ctx.Err() != nil || cfg.runTx != nil {
+		return false
+	}
+	if cfg.table.checkDirty(ctx, conn) != nil {
+		return false
+	}
+
+	delay := lockRetryDelay << (attempt - 1)
+	cfg.logger.Error("lock_retry", "version", step.Version, "filename", step.Filename(), "attempt", attempt, "of", cfg.lockRetries, "delay", delay, "error", err.Error())
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
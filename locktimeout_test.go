@@ -0,0 +1,52 @@
+package pgmigrate
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockTimeoutRetry(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"002-bar.up.sql":   "ALTER TABLE foo ADD COLUMN bar int;",
+		"002-bar.down.sql": "ALTER TABLE foo DROP COLUMN bar;",
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_lock_timeout")
+	defer conn.Close()
+
+	ctx := context.Background()
+	if err := New(conn, WithSource(DirSource(migrateDir))).To(ctx, 1); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Another session holds a lock on foo for a couple of seconds
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE foo IN ACCESS SHARE MODE`); err != nil {
+		t.Fatal(err.Error())
+	}
+	go func() {
+		time.Sleep(2 * time.Second)
+		tx.Rollback() //nolint: errcheck
+	}()
+
+	out := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(out, nil))
+	if err := New(conn, WithSource(DirSource(migrateDir)), WithLogger(logger), WithLockTimeout(100*time.Millisecond, 3)).Up(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(out.String(), "lock_retry") {
+		t.Errorf("Expected the retries to be logged, got %s", out.String())
+	}
+
+}
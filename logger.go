@@ -0,0 +1,99 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger receives progress and errors from a migration run. Fields are
+// alternating keys and values, such as "version", 2, "filename",
+// "002-bar.up.sql". *slog.Logger satisfies Logger.
+type Logger interface {
+	Info(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+}
+
+// LogLevel is how much of a run is logged, see WithLogLevel
+type LogLevel int
+
+const (
+	// LogQuiet logs only errors, and warnings logged as errors
+	LogQuiet LogLevel = iota
+
+	// LogSummary logs each run and each migration when it is done, but not
+	// the progress within a file
+	LogSummary
+
+	// LogVerbose logs every event, such as migration_start and
+	// statement_done
+	LogVerbose
+
+	// LogDebug also logs the SQL of each file as migration_sql before it
+	// runs, with its rendered content as the sql field
+	LogDebug
+)
+
+// eventLevels is the level each info event is logged at, where it isn't
+// LogSummary
+var eventLevels = map[string]LogLevel{
+	"migration_start": LogVerbose,
+	"statement_done":  LogVerbose,
+	"shadow_start":    LogVerbose,
+	"migration_sql":   LogDebug,
+}
+
+// WithLogLevel sets how much is sent to the logger. Errors are always logged.
+// Without it, a logger passed to WithLogger gets every event up to
+// LogVerbose, and the standard log package, as LogQuiet, only errors.
+func WithLogLevel(level LogLevel) Option {
+	return func(cfg *config) {
+		cfg.logLevel = &level
+	}
+}
+
+// levelLogger drops the info events above level
+type levelLogger struct {
+	logger Logger
+	level  LogLevel
+}
+
+func (ll levelLogger) Info(msg string, fields ...interface{}) {
+	eventLevel, ok := eventLevels[msg]
+	if !ok {
+		eventLevel = LogSummary
+	}
+	if eventLevel <= ll.level {
+		ll.logger.Info(msg, fields...)
+	}
+}
+
+func (ll levelLogger) Error(msg string, fields ...interface{}) {
+	ll.logger.Error(msg, fields...)
+}
+
+// stdLogger writes to the standard library log package
+type stdLogger struct{}
+
+var defaultLogger Logger = stdLogger{}
+
+func (sl stdLogger) Info(msg string, fields ...interface{}) {
+	log.Print(formatLogLine(msg, fields))
+}
+
+func (sl stdLogger) Error(msg string, fields ...interface{}) {
+	log.Print(formatLogLine(msg, fields))
+}
+
+func formatLogLine(msg string, fields []interface{}) string {
+	line := &strings.Builder{}
+	line.WriteString(msg)
+	for idx := 0; idx < len(fields); idx += 2 {
+		if idx+1 >= len(fields) {
+			fmt.Fprintf(line, " %v", fields[idx])
+			break
+		}
+		fmt.Fprintf(line, " %v=%v", fields[idx], fields[idx+1])
+	}
+	return line.String()
+}
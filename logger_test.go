@@ -0,0 +1,122 @@
+package pgmigrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatLogLine(t *testing.T) {
+	for _, tc := range []struct {
+		msg    string
+		fields []interface{}
+		expect string
+	}{
+		{"migrate", nil, "migrate"},
+		{"migration done", []interface{}{"version", 2, "duration", time.Second}, "migration done version=2 duration=1s"},
+		{"odd", []interface{}{"version", 2, "dangling"}, "odd version=2 dangling"},
+	} {
+		if got := formatLogLine(tc.msg, tc.fields); got != tc.expect {
+			t.Errorf("Expected %q, got %q", tc.expect, got)
+		}
+	}
+}
+
+var _ Logger = (*slog.Logger)(nil)
+
+type failingSource struct{}
+
+func (failingSource) List() ([]Migration, error) {
+	return nil, nil
+}
+
+func (failingSource) ReadFile(name string) ([]byte, error) {
+	return nil, errors.New("no such file")
+}
+
+func TestSlogEvents(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(out, nil))
+
+	migration := Migration{Version: 4, Name: "foo", UpFile: "004-foo.up.sql"}
+	if err := runFile(context.Background(), nil, failingSource{}, step{Migration: migration, Direction: directionUp}, newConfig([]Option{WithLogger(logger)})); err == nil {
+		t.Fatal("Expected the read to fail")
+	}
+
+	events := []map[string]interface{}{}
+	decoder := json.NewDecoder(out)
+	for decoder.More() {
+		event := map[string]interface{}{}
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatal(err.Error())
+		}
+		events = append(events, event)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[0]["msg"] != "migration_start" || events[1]["msg"] != "migration_failed" {
+		t.Errorf("Wrong events %v", events)
+	}
+	failed := events[1]
+	if failed["level"] != "ERROR" || failed["version"] != float64(4) || failed["filename"] != "004-foo.up.sql" {
+		t.Errorf("Wrong failure event %v", failed)
+	}
+	if _, ok := failed["duration"]; !ok {
+		t.Errorf("Expected a duration in %v", failed)
+	}
+}
+
+type recordingLogger struct {
+	events []string
+}
+
+func (rl *recordingLogger) Info(msg string, fields ...interface{}) {
+	rl.events = append(rl.events, "info "+msg)
+}
+
+func (rl *recordingLogger) Error(msg string, fields ...interface{}) {
+	rl.events = append(rl.events, "error "+msg)
+}
+
+func TestLogLevel(t *testing.T) {
+	for _, tc := range []struct {
+		level  LogLevel
+		expect string
+	}{
+		{LogQuiet, "error migration_failed"},
+		{LogSummary, "info migrate,info migration_done,error migration_failed"},
+		{LogVerbose, "info migrate,info migration_start,info migration_done,error migration_failed"},
+		{LogDebug, "info migrate,info migration_start,info migration_sql,info migration_done,error migration_failed"},
+	} {
+		recorder := &recordingLogger{}
+		logger := newConfig([]Option{WithLogger(recorder), WithLogLevel(tc.level)}).logger
+		logger.Info("migrate")
+		logger.Info("migration_start")
+		logger.Info("migration_sql")
+		logger.Info("migration_done")
+		logger.Error("migration_failed")
+		if got := strings.Join(recorder.events, ","); got != tc.expect {
+			t.Errorf("Level %d: expected %q, got %q", tc.level, tc.expect, got)
+		}
+	}
+
+	// Without a level, a logger gets every event but the SQL, as it did
+	// before there were levels
+	recorder := &recordingLogger{}
+	logger := newConfig([]Option{WithLogger(recorder)}).logger
+	logger.Info("migration_start")
+	logger.Info("migration_sql")
+	if got := strings.Join(recorder.events, ","); got != "info migration_start" {
+		t.Errorf("Expected every event but the SQL without a level, got %q", got)
+	}
+	if logger := newConfig(nil).logger.(levelLogger); logger.logger != defaultLogger || logger.level != LogQuiet {
+		t.Errorf("Expected the default logger to log only errors without a level, got %#v", logger)
+	}
+}
@@ -0,0 +1,61 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// MemoryMigration is the SQL for one version of a MemorySource. Either
+// direction can be left empty.
+type MemoryMigration struct {
+	Name string
+	Up   string
+	Down string
+}
+
+// MemorySource serves migrations held in memory, keyed by version, so that
+// migration wiring can be tested without writing files.
+type MemorySource map[int]MemoryMigration
+
+func (source MemorySource) List() ([]Migration, error) {
+	migrations := make([]Migration, 0, len(source))
+	for version, memory := range source {
+		migration := Migration{
+			source:  source,
+			Version: version,
+			Name:    memory.Name,
+		}
+		if memory.Up != "" {
+			migration.UpFile = memoryFilename(version, memory.Name, directionUp)
+		}
+		if memory.Down != "" {
+			migration.DownFile = memoryFilename(version, memory.Name, directionDown)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func (source MemorySource) ReadFile(name string) ([]byte, error) {
+	for version, memory := range source {
+		switch name {
+		case memoryFilename(version, memory.Name, directionUp):
+			return []byte(memory.Up), nil
+		case memoryFilename(version, memory.Name, directionDown):
+			return []byte(memory.Down), nil
+		}
+	}
+	return nil, fmt.Errorf("reading %s: %w", name, os.ErrNotExist)
+}
+
+// memoryFilename is the name a file would have in a DirSource
+func memoryFilename(version int, name string, direction string) string {
+	if name == "" {
+		return fmt.Sprintf("%d.%s.sql", version, direction)
+	}
+	return fmt.Sprintf("%d-%s.%s.sql", version, name, direction)
+}
@@ -0,0 +1,50 @@
+package pgmigrate
+
+import (
+	"context"
+	"testing"
+)
+
+var testMemorySource = MemorySource{
+	1: {Name: "foo", Up: s1u, Down: s1d},
+	2: {Name: "bar", Up: s2u, Down: s2d},
+	3: {Name: "baz", Up: s3u},
+}
+
+func TestMemorySource(t *testing.T) {
+	migrations, err := testMemorySource.List()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 3 {
+		t.Fatalf("Expected 3 migrations, got %d", len(migrations))
+	}
+	if migrations[1].UpFile != "2-bar.up.sql" || migrations[2].DownFile != "" {
+		t.Errorf("Unexpected migrations %+v", migrations)
+	}
+
+	down, err := migrations[1].DownSQL()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if down != s2d {
+		t.Errorf("Wrong down content %q", down)
+	}
+
+	if _, err := testMemorySource.ReadFile("4-missing.up.sql"); err == nil {
+		t.Error("Expected an error reading a missing file")
+	}
+}
+
+func TestMemorySourceMigrate(t *testing.T) {
+	conn := getTestConn(t, "test_memory_source")
+	defer conn.Close()
+
+	ctx := context.Background()
+	if err := MigrateSource(ctx, conn, testMemorySource, -1, WithForwardOnly(true)); err != nil {
+		t.Fatalf("Unable to migrate: %s", err.Error())
+	}
+	if _, err := conn.ExecContext(ctx, `SELECT * FROM baz`); err != nil {
+		t.Errorf("Expected baz to be created: %s", err.Error())
+	}
+}
@@ -0,0 +1,50 @@
+package pgmigrate
+
+import (
+	"time"
+)
+
+// Metrics receives measurements of each run, for exporting to a monitoring
+// system. The prommetrics package has Prometheus collectors which satisfy
+// it. Calls come from the goroutine running the migrations.
+type Metrics interface {
+	// MigrationApplied is called as each file or Go migration succeeds. In a
+	// single transaction run it is called before the run commits.
+	MigrationApplied(version int, direction string, duration time.Duration)
+
+	// MigrationFailed is called as each fails
+	MigrationFailed(version int, direction string, duration time.Duration)
+
+	// SchemaVersion is called with the version recorded in table when a run
+	// starts and each time the run changes it
+	SchemaVersion(table string, version int)
+}
+
+// WithMetrics reports runs to metrics
+func WithMetrics(metrics Metrics) Option {
+	return func(cfg *config) {
+		cfg.metrics = metrics
+	}
+}
+
+func (cfg *config) reportStep(step step, duration time.Duration, err error) {
+	if cfg.metrics == nil {
+		return
+	}
+	if err != nil {
+		cfg.metrics.MigrationFailed(step.Version, step.Direction, duration)
+		return
+	}
+	cfg.metrics.MigrationApplied(step.Version, step.Direction, duration)
+}
+
+// reportVersion records the version of the database, which changes as each
+// step commits
+func (cfg *config) reportVersion(version int) {
+	if cfg.report != nil {
+		cfg.report.Version = version
+	}
+	if cfg.metrics != nil {
+		cfg.metrics.SchemaVersion(cfg.table.String(), version)
+	}
+}
@@ -0,0 +1,37 @@
+package pgmigrate
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type recordingMetrics struct {
+	applied  []int
+	failed   []int
+	versions []int
+}
+
+func (m *recordingMetrics) MigrationApplied(version int, direction string, duration time.Duration) {
+	m.applied = append(m.applied, version)
+}
+
+func (m *recordingMetrics) MigrationFailed(version int, direction string, duration time.Duration) {
+	m.failed = append(m.failed, version)
+}
+
+func (m *recordingMetrics) SchemaVersion(table string, version int) {
+	m.versions = append(m.versions, version)
+}
+
+func TestMetricsFailure(t *testing.T) {
+	metrics := &recordingMetrics{}
+	migration := Migration{Version: 4, Name: "foo", UpFile: "004-foo.up.sql"}
+	cfg := newConfig([]Option{WithMetrics(metrics)})
+	if err := runFile(context.Background(), nil, failingSource{}, step{Migration: migration, Direction: directionUp}, cfg); err == nil {
+		t.Fatal("Expected the read to fail")
+	}
+	if len(metrics.failed) != 1 || metrics.failed[0] != 4 || len(metrics.applied) != 0 {
+		t.Errorf("Expected one failure of version 4, got %+v", metrics)
+	}
+}
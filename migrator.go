@@ -0,0 +1,158 @@
+package pgmigrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Migrator runs the migrations from a source against a database. It is
+// configured with the same Options as the package level functions.
+type Migrator struct {
+	conn Queryer
+	cfg  *config
+}
+
+// New creates a Migrator for conn. Without WithSource, migrations are read
+// from the ./migrations directory.
+func New(conn Queryer, opts ...Option) *Migrator {
+	return &Migrator{
+		conn: conn,
+		cfg:  newConfig(opts),
+	}
+}
+
+// Up migrates to the latest version
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, -1)
+}
+
+// Down reverts every migration, back to version 0. Like any run down, it
+// needs WithAllowDown.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.To(ctx, 0)
+}
+
+// UpSteps applies the next n migrations, or as many as there are
+func (m *Migrator) UpSteps(ctx context.Context, n int) error {
+	if n < 1 {
+		return fmt.Errorf("steps must be at least 1, got %d", n)
+	}
+	targetVersion, err := m.stepTarget(ctx, n)
+	if err != nil {
+		return err
+	}
+	return m.To(ctx, targetVersion)
+}
+
+// DownSteps reverts the last n applied migrations, stopping at version 0. Like
+// Down, it needs WithAllowDown.
+func (m *Migrator) DownSteps(ctx context.Context, n int) error {
+	if n < 1 {
+		return fmt.Errorf("steps must be at least 1, got %d", n)
+	}
+	targetVersion, err := m.stepTarget(ctx, -n)
+	if err != nil {
+		return err
+	}
+	return m.To(ctx, targetVersion)
+}
+
+// stepTarget is the version n migrations above, or for a negative n below,
+// the current version
+func (m *Migrator) stepTarget(ctx context.Context, n int) (int, error) {
+	currentVersion, err := m.cfg.table.peekVersion(ctx, m.conn)
+	if err != nil && err != errNoTable {
+		return 0, err
+	}
+	byVersion, _, err := loadMigrations(m.cfg)
+	if err != nil {
+		return 0, err
+	}
+	return offsetVersion(byVersion, currentVersion, n), nil
+}
+
+// To migrates up or down to targetVersion. A targetVersion of -1 migrates to
+// the latest version.
+func (m *Migrator) To(ctx context.Context, targetVersion int) error {
+	if m.cfg.dryRun != nil {
+		return printPlan(ctx, m.conn, m.cfg, targetVersion, m.cfg.dryRun)
+	}
+
+	if m.cfg.shadowSchema || m.cfg.shadowDatabase != nil {
+		if err := m.rehearse(ctx, targetVersion); err != nil {
+			return err
+		}
+	}
+
+	cfg := m.cfg
+	if _, ok := m.conn.(connPool); ok && (cfg.blockerThreshold > 0 || cfg.idleBlockerAge > 0) {
+		watched := *cfg
+		watched.monitor = m.conn
+		cfg = &watched
+	}
+	cfg, finishReport := cfg.startReport()
+	err := m.withSession(ctx, func(conn Queryer) error {
+		return migrate(ctx, conn, cfg.source, targetVersion, cfg)
+	})
+	finishReport(ctx, err)
+	return err
+}
+
+// Version returns the version the database is currently migrated to,
+// creating the tracking table if it doesn't exist yet
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	return m.cfg.table.getVersion(ctx, m.conn)
+}
+
+// ReadVersion returns the version the database is currently migrated to
+// without creating anything
+func (m *Migrator) ReadVersion(ctx context.Context) (int, error) {
+	currentVersion, err := m.cfg.table.peekVersion(ctx, m.conn)
+	if err == errNoTable {
+		return 0, nil
+	}
+	return currentVersion, err
+}
+
+// Validate checks the migrations from the source, including any Go
+// migrations, form a complete sequence without duplicates. It doesn't touch
+// the database, so can be called at startup.
+func (m *Migrator) Validate() error {
+	_, _, err := loadMigrations(m.cfg)
+	return err
+}
+
+// Problems lists everything wrong with the migrations from the source, where
+// Validate stops at the first: versions missing an up, or a down without
+// WithForwardOnly, including the last, down files with no up, and versions
+// which don't fit the scheme. A source which can't be read at all, or has
+// badly named or duplicate files, is a single problem.
+func (m *Migrator) Problems() []error {
+	byVersion, firstMigration, maxMigration, err := readMigrations(m.cfg)
+	if err != nil {
+		return []error{err}
+	}
+	return m.cfg.scheme.problems(byVersion, firstMigration, maxMigration, m.cfg.forwardOnly)
+}
+
+// offsetVersion counts n versions on from currentVersion through the known
+// versions, clamping at 0 and the highest version
+func offsetVersion(byVersion map[int]Migration, currentVersion int, n int) int {
+	versions := []int{0}
+	for version := range byVersion {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	// position is the index of the last version at or below current
+	position := sort.SearchInts(versions, currentVersion+1) - 1
+	target := position + n
+	if target < 0 {
+		target = 0
+	}
+	if target >= len(versions) {
+		target = len(versions) - 1
+	}
+	return versions[target]
+}
@@ -0,0 +1,104 @@
+package pgmigrate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestMigrator(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_migrator")
+	defer conn.Close()
+
+	ctx := context.Background()
+	migrator := New(conn, WithSource(DirSource(migrateDir)), WithLock(false))
+
+	assertVersion := func(expect int) {
+		t.Helper()
+		if v, err := getVersion(ctx, conn); err != nil {
+			t.Fatalf("Expected no error getting version: %s", err.Error())
+		} else if v != expect {
+			t.Fatalf("Wrong version %d (expected %d)", v, expect)
+		}
+	}
+
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Unable to migrate up: %s", err.Error())
+	}
+	assertVersion(3)
+
+	var downErr *DownNotAllowedError
+	if err := migrator.To(ctx, 1); !errors.As(err, &downErr) {
+		t.Fatalf("Expected a DownNotAllowedError, got %v", err)
+	}
+	assertVersion(3)
+
+	allowing := New(conn, WithSource(DirSource(migrateDir)), WithLock(false), WithAllowDown(true))
+	if err := allowing.To(ctx, 1); err != nil {
+		t.Fatalf("Unable to migrate to 1: %s", err.Error())
+	}
+	assertVersion(1)
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(statuses) != 3 || !statuses[0].Applied || statuses[1].Applied {
+		t.Errorf("Wrong status %+v", statuses)
+	}
+
+	if err := migrator.Down(ctx); !errors.As(err, &downErr) {
+		t.Fatalf("Expected Down to need AllowDown, got %v", err)
+	}
+	if err := allowing.Down(ctx); err != nil {
+		t.Fatalf("Unable to migrate down: %s", err.Error())
+	}
+	assertVersion(0)
+
+	if err := migrator.UpSteps(ctx, 2); err != nil {
+		t.Fatalf("Unable to migrate up 2 steps: %s", err.Error())
+	}
+	assertVersion(2)
+
+	if err := migrator.DownSteps(ctx, 1); !errors.As(err, &downErr) {
+		t.Fatalf("Expected DownSteps to need AllowDown, got %v", err)
+	}
+	if err := allowing.DownSteps(ctx, 1); err != nil {
+		t.Fatalf("Unable to migrate down 1 step: %s", err.Error())
+	}
+	assertVersion(1)
+}
+
+func TestNewDefaults(t *testing.T) {
+	migrator := New(nil)
+	if migrator.cfg.source != DirSource("./migrations") {
+		t.Errorf("Expected the default source, got %v", migrator.cfg.source)
+	}
+	if !migrator.cfg.lock {
+		t.Error("Expected locking to default on")
+	}
+}
+
+func TestOffsetVersion(t *testing.T) {
+	byVersion := testByVersion(10, 20, 30)
+	for _, tc := range []struct {
+		current, n, expect int
+	}{
+		{0, 1, 10},
+		{0, 5, 30},
+		{10, 2, 30},
+		{30, -1, 20},
+		{30, -3, 0},
+		{20, -5, 0},
+		{25, 1, 30},
+		{25, -1, 10},
+	} {
+		if got := offsetVersion(byVersion, tc.current, tc.n); got != tc.expect {
+			t.Errorf("%d by %d: expected %d, got %d", tc.current, tc.n, tc.expect, got)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package pgmigrate
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Module is a set of migrations which owns its own version track, so parts
+// of an application can number their migrations independently.
+type Module struct {
+	Name   string
+	Source MigrationSource
+}
+
+// WithModule tracks the run as module name, in its own table named after the
+// tracking table, e.g. _migrate_auth_ or schema_version_auth, with its own
+// history.
+func WithModule(name string) Option {
+	return func(cfg *config) {
+		cfg.module = name
+	}
+}
+
+// forModule is the tracking table for the module name
+func (t trackingTable) forModule(name string) trackingTable {
+	if strings.HasSuffix(t.name, "_") {
+		return trackingTable{schema: t.schema, name: t.name + name + "_"}
+	}
+	return trackingTable{schema: t.schema, name: t.name + "_" + name}
+}
+
+// ModulesFromDir treats each directory directly inside dir as a module named
+// after it, in name order
+func ModulesFromDir(dir string) ([]Module, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	modules := []Module{}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		modules = append(modules, Module{
+			Name:   entry.Name(),
+			Source: DirSource(filepath.Join(dir, entry.Name())),
+		})
+	}
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].Name < modules[j].Name
+	})
+	return modules, nil
+}
+
+// MigrateModules migrates each module to its latest version, in the order
+// given, stopping at the first which fails. Each module is tracked as if run
+// with WithModule.
+func MigrateModules(ctx context.Context, conn Queryer, modules []Module, opts ...Option) error {
+	seen := map[string]bool{}
+	for _, module := range modules {
+		if module.Name == "" {
+			return fmt.Errorf("module has no name")
+		}
+		if seen[module.Name] {
+			return fmt.Errorf("module %s is listed more than once", module.Name)
+		}
+		seen[module.Name] = true
+	}
+
+	for _, module := range modules {
+		migrator := New(conn, append(opts, WithSource(module.Source), WithModule(module.Name))...)
+		if err := migrator.Up(ctx); err != nil {
+			return fmt.Errorf("module %s: %w", module.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package pgmigrate
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModuleTable(t *testing.T) {
+	for _, tc := range []struct {
+		table         string
+		expectVersion string
+		expectHistory string
+	}{
+		{"_migrate_", `"_migrate_auth_"`, `"_migrate_auth_history_"`},
+		{"myapp.schema_version", `"myapp"."schema_version_auth"`, `"myapp"."schema_version_auth_history"`},
+	} {
+		cfg := newConfig([]Option{WithModule("auth"), WithTableName(tc.table)})
+		if got := cfg.table.versionTable(); got != tc.expectVersion {
+			t.Errorf("%s: expected version table %s, got %s", tc.table, tc.expectVersion, got)
+		}
+		if got := cfg.table.historyTable(); got != tc.expectHistory {
+			t.Errorf("%s: expected history table %s, got %s", tc.table, tc.expectHistory, got)
+		}
+	}
+}
+
+func writeModuleFiles(t *testing.T, modules map[string]map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for module, files := range modules {
+		if err := os.Mkdir(filepath.Join(dir, module), 0755); err != nil {
+			os.RemoveAll(dir)
+			t.Fatal(err.Error())
+		}
+		for name, content := range files {
+			if err := ioutil.WriteFile(filepath.Join(dir, module, name), []byte(content), 0660); err != nil {
+				os.RemoveAll(dir)
+				t.Fatal(err.Error())
+			}
+		}
+	}
+	return dir
+}
+
+var testModules = map[string]map[string]string{
+	"billing": {
+		"001-invoices.up.sql":   `CREATE TABLE invoices (id int);`,
+		"001-invoices.down.sql": `DROP TABLE invoices;`,
+	},
+	"auth": {
+		"001-users.up.sql":   `CREATE TABLE users (id int);`,
+		"001-users.down.sql": `DROP TABLE users;`,
+		"002-roles.up.sql":   `CREATE TABLE roles (id int);`,
+		"002-roles.down.sql": `DROP TABLE roles;`,
+	},
+}
+
+func TestModulesFromDir(t *testing.T) {
+	dir := writeModuleFiles(t, testModules)
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0660); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	modules, err := ModulesFromDir(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(modules) != 2 || modules[0].Name != "auth" || modules[1].Name != "billing" {
+		t.Fatalf("Expected the auth and billing modules, got %+v", modules)
+	}
+
+	migrations, err := modules[0].Source.List()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("Expected 2 auth migrations, got %+v", migrations)
+	}
+}
+
+func TestMigrateModules(t *testing.T) {
+	dir := writeModuleFiles(t, testModules)
+	defer os.RemoveAll(dir)
+
+	conn := getTestConn(t, "test_modules")
+	defer conn.Close()
+
+	ctx := context.Background()
+	modules, err := ModulesFromDir(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := MigrateModules(ctx, conn, modules); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for module, expect := range map[string]int{"auth": 2, "billing": 1} {
+		if v, err := New(conn, WithModule(module)).Version(ctx); err != nil {
+			t.Fatal(err.Error())
+		} else if v != expect {
+			t.Errorf("Expected %s at %d, got %d", module, expect, v)
+		}
+	}
+
+	if err := MigrateModules(ctx, conn, append(modules, modules[0])); err == nil {
+		t.Fatal("Expected an error for a repeated module")
+	}
+}
@@ -0,0 +1,70 @@
+package pgmigrate
+
+import (
+	"sync"
+)
+
+// NoticeRelay forwards the notices a server sends while migrating, such as
+// from RAISE NOTICE in a data migration reporting progress, to the logger of
+// the Migrator given it with WithNotices, tagged with the version and file
+// running. Drivers deliver notices to a handler set when connecting, so pass
+// Notice to it, as in
+//
+//	// lib/pq
+//	connector, _ := pq.NewConnector(url)
+//	db := sql.OpenDB(pq.ConnectorWithNoticeHandler(connector, func(notice *pq.Error) {
+//		relay.Notice(notice.Severity, notice.Message)
+//	}))
+//
+//	// pgx
+//	config.OnNotice = func(_ *pgconn.PgConn, notice *pgconn.Notice) {
+//		relay.Notice(notice.Severity, notice.Message)
+//	}
+//
+// Use a relay for one Migrator at a time, as it can't tell runs apart.
+type NoticeRelay struct {
+	mu     sync.Mutex
+	logger Logger
+	fields []interface{}
+}
+
+// Notice logs a notice as migration_notice, as an error for warnings and
+// otherwise as info. Notices arriving outside a migration are logged without
+// a version.
+func (r *NoticeRelay) Notice(severity string, message string) {
+	r.mu.Lock()
+	logger := r.logger
+	fields := append(append([]interface{}{}, r.fields...), "severity", severity, "message", message)
+	r.mu.Unlock()
+
+	if logger == nil {
+		logger = defaultLogger
+	}
+	if severity == "WARNING" {
+		logger.Error("migration_notice", fields...)
+	} else {
+		logger.Info("migration_notice", fields...)
+	}
+}
+
+// running tags the notices which follow with step, until the returned
+// function is called
+func (r *NoticeRelay) running(logger Logger, step step) func() {
+	r.mu.Lock()
+	r.logger = logger
+	r.fields = []interface{}{"version", step.Version, "filename", step.Filename()}
+	r.mu.Unlock()
+	return func() {
+		r.mu.Lock()
+		r.fields = nil
+		r.mu.Unlock()
+	}
+}
+
+// WithNotices forwards the notices delivered to relay to the logger as
+// migration_notice, with severity and message fields
+func WithNotices(relay *NoticeRelay) Option {
+	return func(cfg *config) {
+		cfg.notices = relay
+	}
+}
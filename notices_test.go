@@ -0,0 +1,55 @@
+package pgmigrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestNoticeRelay(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(out, nil))
+
+	relay := &NoticeRelay{}
+	migration := Migration{Version: 3, Name: "backfill", UpFile: "003-backfill.up.sql"}
+	done := relay.running(logger, step{Migration: migration, Direction: directionUp})
+	relay.Notice("NOTICE", "copied 100 rows")
+	relay.Notice("WARNING", "skipped 2 rows")
+	done()
+	relay.Notice("NOTICE", "after")
+
+	events := []map[string]interface{}{}
+	decoder := json.NewDecoder(out)
+	for decoder.More() {
+		event := map[string]interface{}{}
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatal(err.Error())
+		}
+		events = append(events, event)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	for idx, expect := range []struct {
+		level   string
+		message string
+		tagged  bool
+	}{
+		{"INFO", "copied 100 rows", true},
+		{"ERROR", "skipped 2 rows", true},
+		{"INFO", "after", false},
+	} {
+		event := events[idx]
+		if event["msg"] != "migration_notice" || event["level"] != expect.level || event["message"] != expect.message {
+			t.Errorf("Wrong event %v", event)
+		}
+		if _, ok := event["version"]; ok != expect.tagged {
+			t.Errorf("Expected tagged %v in %v", expect.tagged, event)
+		}
+	}
+	if events[0]["version"] != float64(3) || events[0]["filename"] != "003-backfill.up.sql" {
+		t.Errorf("Wrong tags %v", events[0])
+	}
+}
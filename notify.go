@@ -0,0 +1,212 @@
+package pgmigrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RunReport describes a finished run, for a Notifier. It marshals to JSON as
+// the payload Webhook posts.
+type RunReport struct {
+	// Table is the version tracking table the run migrated
+	Table string
+
+	// From is the version the run started at, To the version it was going
+	// to, and Version the version it finished at
+	From    int
+	To      int
+	Version int
+
+	// Applied lists the migrations which ran successfully, in order
+	Applied  []AppliedMigration
+	Duration time.Duration
+
+	// Err is why the run failed, and is nil if it succeeded
+	Err error
+}
+
+// AppliedMigration is one migration of a RunReport
+type AppliedMigration struct {
+	Migration
+	Filename  string
+	Direction string
+	Duration  time.Duration
+}
+
+// Notifier is told about each run as it finishes, to let people know about
+// schema changes, see Webhook
+type Notifier interface {
+	Notify(ctx context.Context, report RunReport) error
+}
+
+// WithNotifier tells notifier about each run as it finishes, whether it
+// succeeds or fails. Dry runs aren't reported. A notifier which fails is
+// logged as the error notify_failed, and doesn't fail the run.
+func WithNotifier(notifier Notifier) Option {
+	return func(cfg *config) {
+		cfg.notifier = notifier
+	}
+}
+
+// startReport returns a copy of cfg collecting a report of the run, and a
+// function which sends it once the run is done
+func (cfg *config) startReport() (*config, func(ctx context.Context, err error)) {
+	if cfg.notifier == nil {
+		return cfg, func(context.Context, error) {}
+	}
+	started := time.Now()
+	reporting := *cfg
+	reporting.report = &RunReport{Table: cfg.table.String()}
+	return &reporting, func(ctx context.Context, err error) {
+		report := *reporting.report
+		report.Duration = time.Since(started)
+		report.Err = err
+		if err := cfg.notifier.Notify(context.WithoutCancel(ctx), report); err != nil {
+			cfg.logger.Error("notify_failed", "error", err.Error())
+		}
+	}
+}
+
+// reportPlan records the versions of the run once they are known
+func (cfg *config) reportPlan(from int, to int) {
+	if cfg.report != nil {
+		cfg.report.From = from
+		cfg.report.To = to
+		cfg.report.Version = from
+	}
+}
+
+// reportApplied records a step which succeeded
+func (cfg *config) reportApplied(step step, duration time.Duration) {
+	if cfg.report == nil {
+		return
+	}
+	cfg.report.Applied = append(cfg.report.Applied, AppliedMigration{
+		Migration: step.Migration,
+		Filename:  step.Filename(),
+		Direction: step.Direction,
+		Duration:  duration,
+	})
+}
+
+// Webhook is a Notifier which posts each RunReport as JSON to a URL. The
+// payload has a text summary, which is all Slack's incoming webhooks read,
+// along with the details:
+//
+//	{
+//	  "text": "pgmigrate _migrate_: migrated from 1 to 3 in 1.2s",
+//	  "status": "succeeded",
+//	  "table": "_migrate_",
+//	  "from": 1,
+//	  "to": 3,
+//	  "version": 3,
+//	  "durationMs": 1200,
+//	  "applied": [
+//	    {"version": 2, "name": "bar", "filename": "002-bar.up.sql", "direction": "up", "durationMs": 700},
+//	    {"version": 3, "name": "baz", "filename": "003-baz.up.sql", "direction": "up", "durationMs": 500}
+//	  ]
+//	}
+//
+// Failed runs have the status "failed" and an "error".
+type Webhook struct {
+	URL string
+
+	// Client sends the request, http.DefaultClient if nil
+	Client *http.Client
+}
+
+type webhookPayload struct {
+	Text       string           `json:"text"`
+	Status     string           `json:"status"`
+	Table      string           `json:"table"`
+	From       int              `json:"from"`
+	To         int              `json:"to"`
+	Version    int              `json:"version"`
+	DurationMS int64            `json:"durationMs"`
+	Applied    []webhookApplied `json:"applied"`
+	Error      string           `json:"error,omitempty"`
+}
+
+type webhookApplied struct {
+	Version    int    `json:"version"`
+	Name       string `json:"name"`
+	Filename   string `json:"filename"`
+	Direction  string `json:"direction"`
+	DurationMS int64  `json:"durationMs"`
+}
+
+// Notify posts report to the URL, failing unless the response is a 2xx
+func (w Webhook) Notify(ctx context.Context, report RunReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body) //nolint: errcheck
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", res.Status)
+	}
+	return nil
+}
+
+// MarshalJSON writes the report as the payload Webhook posts, with the error
+// as a string and durations in milliseconds
+func (report RunReport) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newWebhookPayload(report))
+}
+
+func newWebhookPayload(report RunReport) webhookPayload {
+	payload := webhookPayload{
+		Status:     "succeeded",
+		Table:      report.Table,
+		From:       report.From,
+		To:         report.To,
+		Version:    report.Version,
+		DurationMS: report.Duration.Milliseconds(),
+		Applied:    []webhookApplied{},
+	}
+	for _, applied := range report.Applied {
+		payload.Applied = append(payload.Applied, webhookApplied{
+			Version:    applied.Version,
+			Name:       applied.Name,
+			Filename:   applied.Filename,
+			Direction:  applied.Direction,
+			DurationMS: applied.Duration.Milliseconds(),
+		})
+	}
+
+	summary := &strings.Builder{}
+	fmt.Fprintf(summary, "pgmigrate %s: ", report.Table)
+	if report.Err != nil {
+		payload.Status = "failed"
+		payload.Error = report.Err.Error()
+		fmt.Fprintf(summary, "failed migrating from %d to %d, the database is at version %d: %s", report.From, report.To, report.Version, report.Err)
+	} else if len(report.Applied) == 0 {
+		fmt.Fprintf(summary, "already at version %d", report.Version)
+	} else {
+		fmt.Fprintf(summary, "migrated from %d to %d in %s", report.From, report.Version, report.Duration.Round(time.Millisecond))
+	}
+	payload.Text = summary.String()
+	return payload
+}
@@ -0,0 +1,139 @@
+package pgmigrate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Wrong content type %s", r.Header.Get("Content-Type"))
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Error(err.Error())
+		}
+	}))
+	defer server.Close()
+
+	report := RunReport{
+		Table:   "_migrate_",
+		From:    1,
+		To:      3,
+		Version: 2,
+		Applied: []AppliedMigration{{
+			Migration: Migration{Version: 2, Name: "bar"},
+			Filename:  "002-bar.up.sql",
+			Direction: directionUp,
+			Duration:  700 * time.Millisecond,
+		}},
+		Duration: 1200 * time.Millisecond,
+		Err:      errors.New("executing 003-baz.up.sql: syntax error"),
+	}
+	if err := (Webhook{URL: server.URL}).Notify(context.Background(), report); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if received["status"] != "failed" || received["version"] != float64(2) || received["error"] != "executing 003-baz.up.sql: syntax error" {
+		t.Errorf("Wrong payload %v", received)
+	}
+	if text, _ := received["text"].(string); !strings.Contains(text, "the database is at version 2") {
+		t.Errorf("Wrong text %q", text)
+	}
+	applied, _ := received["applied"].([]interface{})
+	if len(applied) != 1 {
+		t.Fatalf("Expected 1 applied migration, got %v", received["applied"])
+	}
+	if first := applied[0].(map[string]interface{}); first["filename"] != "002-bar.up.sql" || first["durationMs"] != float64(700) {
+		t.Errorf("Wrong applied migration %v", first)
+	}
+}
+
+func TestWebhookStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := (Webhook{URL: server.URL}).Notify(context.Background(), RunReport{})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Expected the status to fail the notification, got %v", err)
+	}
+}
+
+func TestRunReportJSON(t *testing.T) {
+	encoded, err := json.Marshal(RunReport{Table: "_migrate_", From: 1, To: 1, Version: 1})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err.Error())
+	}
+	if decoded["status"] != "succeeded" || decoded["text"] != "pgmigrate _migrate_: already at version 1" {
+		t.Errorf("Wrong JSON %s", encoded)
+	}
+	if _, ok := decoded["error"]; ok {
+		t.Errorf("Expected no error in %s", encoded)
+	}
+}
+
+type recordingNotifier struct {
+	reports []RunReport
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, report RunReport) error {
+	n.reports = append(n.reports, report)
+	return nil
+}
+
+func TestNotifier(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"002-bar.up.sql":   s2u,
+		"002-bar.down.sql": s2d,
+		"003-bad.up.sql":   "CREATE TABLE baz (id int REFERENCES missing (id));",
+		"003-bad.down.sql": s3d,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_notifier")
+	defer conn.Close()
+
+	ctx := context.Background()
+	notifier := &recordingNotifier{}
+	migrator := New(conn, WithSource(DirSource(migrateDir)), WithNotifier(notifier))
+	if err := migrator.To(ctx, 2); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := migrator.Up(ctx); err == nil {
+		t.Fatal("Expected 003 to fail")
+	}
+
+	if len(notifier.reports) != 2 {
+		t.Fatalf("Expected 2 reports, got %d", len(notifier.reports))
+	}
+	done := notifier.reports[0]
+	if done.Err != nil || done.From != 0 || done.Version != 2 || len(done.Applied) != 2 {
+		t.Errorf("Wrong report of the first run %+v", done)
+	}
+	failed := notifier.reports[1]
+	if failed.Err == nil || failed.From != 2 || failed.To != 3 || failed.Version != 2 || len(failed.Applied) != 0 {
+		t.Errorf("Wrong report of the failed run %+v", failed)
+	}
+}
@@ -0,0 +1,172 @@
+package pgmigrate
+
+import (
+	"database/sql"
+	"io"
+	"text/template"
+	"time"
+)
+
+// Option configures a migration run
+type Option func(*config)
+
+type config struct {
+	table  trackingTable
+	source MigrationSource
+	lock   bool
+	dryRun io.Writer
+	logger Logger
+	scheme VersionScheme
+	module string
+	schema string
+
+	logLevel *LogLevel
+
+	goMigrations    []Migration
+	allowOutOfOrder bool
+	forwardOnly     bool
+	allowDown       bool
+	strict          bool
+
+	preScripts  string
+	postScripts string
+	environment string
+	variables   map[string]string
+	lockFile    string
+	signatures  SignatureVerifier
+
+	policy         []PolicyRule
+	policyOverride bool
+
+	lint              bool
+	lintServerVersion int
+
+	shadowSchema   bool
+	shadowDatabase Queryer
+
+	migrationTimeout time.Duration
+	lockTimeout      time.Duration
+	lockRetries      int
+	blockerThreshold time.Duration
+
+	idleBlockerAge        time.Duration
+	terminateIdleBlockers bool
+
+	singleTransaction bool
+	splitStatements   bool
+	notices           *NoticeRelay
+	metrics           Metrics
+	tracer            Tracer
+	hooks             Hooks
+	notifier          Notifier
+
+	// runTx is the transaction every file runs in, for WithSingleTransaction
+	runTx *sql.Tx
+
+	// report collects what happens in a run, for WithNotifier
+	report *RunReport
+
+	// monitor is a connection apart from the migration's session, for
+	// watching it
+	monitor Queryer
+
+	templateData  interface{}
+	templateFuncs template.FuncMap
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{
+		table:  defaultTable,
+		source: DirSource("./migrations"),
+		lock:   true,
+		logger: defaultLogger,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	level := LogVerbose
+	if _, ok := cfg.logger.(stdLogger); ok {
+		level = LogQuiet
+	}
+	if cfg.logLevel != nil {
+		level = *cfg.logLevel
+	}
+	cfg.logger = levelLogger{logger: cfg.logger, level: level}
+	if cfg.schema != "" {
+		cfg.table.schema = cfg.schema
+	}
+	if cfg.module != "" {
+		cfg.table = cfg.table.forModule(cfg.module)
+	}
+	if cfg.signatures != nil {
+		cfg.source = signedSource{MigrationSource: cfg.source, verifier: cfg.signatures}
+	}
+	if len(cfg.goMigrations) > 0 {
+		cfg.source = goSource{MigrationSource: cfg.source, migrations: cfg.goMigrations}
+	}
+	return cfg
+}
+
+// WithSource reads migrations from source
+func WithSource(source MigrationSource) Option {
+	return func(cfg *config) {
+		cfg.source = source
+	}
+}
+
+// WithTableName sets the table which tracks the current version, either as
+// table or schema.table. The default is _migrate_, in the search_path. The
+// history table is created alongside it.
+func WithTableName(name string) Option {
+	return func(cfg *config) {
+		if name != "" {
+			cfg.table = parseTableName(name)
+		}
+	}
+}
+
+// WithLock sets whether to hold an advisory lock for the run, which stops
+// concurrent migrators from racing each other. It is on by default.
+func WithLock(lock bool) Option {
+	return func(cfg *config) {
+		cfg.lock = lock
+	}
+}
+
+// WithDryRun writes the plan, including the SQL of each file, to w instead of
+// running it. The database is only read from.
+func WithDryRun(w io.Writer) Option {
+	return func(cfg *config) {
+		cfg.dryRun = w
+	}
+}
+
+// WithLogger sends progress and errors to logger instead of the standard log
+// package. Each file logs migration_start, followed by migration_done or
+// migration_failed, with version, filename, direction and duration fields.
+// migration_done adds rows_affected, and migration_failed adds error along
+// with any details sent by the server. Repeatable migrations log the same
+// events with the direction repeatable and no version.
+func WithLogger(logger Logger) Option {
+	return func(cfg *config) {
+		cfg.logger = logger
+	}
+}
+
+// WithForwardOnly allows migrations without down files, for teams which never
+// roll back. Migrating down then fails only when it reaches a migration
+// without one.
+func WithForwardOnly(forwardOnly bool) Option {
+	return func(cfg *config) {
+		cfg.forwardOnly = forwardOnly
+	}
+}
+
+// WithAllowDown permits runs which migrate down. Without it a target below
+// the current version, including Migrator.Down and DownSteps, is refused with
+// a DownNotAllowedError rather than running destructive down files.
+func WithAllowDown(allow bool) Option {
+	return func(cfg *config) {
+		cfg.allowDown = allow
+	}
+}
@@ -0,0 +1,68 @@
+// Package otelmigrate traces pgmigrate runs with OpenTelemetry, as a span for
+// each run with a child span for each migration file, so deploy traces show
+// where the time went:
+//
+//	migrator := pgmigrate.New(db, pgmigrate.WithTracer(otelmigrate.New(otel.GetTracerProvider())))
+package otelmigrate
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.daemonl.com/pgmigrate"
+)
+
+// InstrumentationName is the name of the tracer spans are created with
+const InstrumentationName = "gopkg.daemonl.com/pgmigrate/otelmigrate"
+
+// Tracer is a pgmigrate.Tracer creating OpenTelemetry spans
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+var _ pgmigrate.Tracer = (*Tracer)(nil)
+
+// New creates a Tracer with spans from provider
+func New(provider trace.TracerProvider) *Tracer {
+	return &Tracer{tracer: provider.Tracer(InstrumentationName)}
+}
+
+// StartRun starts a pgmigrate.run span with the versions the run goes from
+// and to
+func (t *Tracer) StartRun(ctx context.Context, from int, to int) (context.Context, func(error)) {
+	ctx, span := t.tracer.Start(ctx, "pgmigrate.run", trace.WithAttributes(
+		attribute.Int("pgmigrate.from_version", from),
+		attribute.Int("pgmigrate.to_version", to),
+	))
+	return ctx, func(err error) {
+		endSpan(span, err)
+	}
+}
+
+// StartMigration starts a span named after the file with its version,
+// filename and direction, and the rows it affected once it is done
+func (t *Tracer) StartMigration(ctx context.Context, migration pgmigrate.Migration, filename string, direction string) (context.Context, func(int64, error)) {
+	ctx, span := t.tracer.Start(ctx, fmt.Sprintf("pgmigrate.migration %s", filename), trace.WithAttributes(
+		attribute.Int("pgmigrate.version", migration.Version),
+		attribute.String("pgmigrate.name", migration.Name),
+		attribute.String("pgmigrate.filename", filename),
+		attribute.String("pgmigrate.direction", direction),
+	))
+	return ctx, func(rowsAffected int64, err error) {
+		if err == nil {
+			span.SetAttributes(attribute.Int64("pgmigrate.rows_affected", rowsAffected))
+		}
+		endSpan(span, err)
+	}
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,55 @@
+package otelmigrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gopkg.daemonl.com/pgmigrate"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	ctx, endRun := tracer.StartRun(context.Background(), 1, 3)
+	migration := pgmigrate.Migration{Version: 2, Name: "bar"}
+	_, endMigration := tracer.StartMigration(ctx, migration, "002-bar.up.sql", "up")
+	endMigration(12, nil)
+	_, endMigration = tracer.StartMigration(ctx, pgmigrate.Migration{Version: 3, Name: "baz"}, "003-baz.up.sql", "up")
+	endMigration(0, errors.New("syntax error"))
+	endRun(errors.New("syntax error"))
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+	run := spans[2]
+	if run.Name() != "pgmigrate.run" || run.Status().Code != codes.Error {
+		t.Errorf("Wrong run span %s %v", run.Name(), run.Status())
+	}
+	for _, span := range spans[:2] {
+		if span.Parent().SpanID() != run.SpanContext().SpanID() {
+			t.Errorf("Expected %s to be a child of the run", span.Name())
+		}
+	}
+
+	done := spans[0]
+	if done.Name() != "pgmigrate.migration 002-bar.up.sql" {
+		t.Errorf("Wrong span name %s", done.Name())
+	}
+	attributes := map[attribute.Key]attribute.Value{}
+	for _, kv := range done.Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	if attributes["pgmigrate.version"].AsInt64() != 2 || attributes["pgmigrate.filename"].AsString() != "002-bar.up.sql" || attributes["pgmigrate.rows_affected"].AsInt64() != 12 {
+		t.Errorf("Wrong attributes %v", attributes)
+	}
+	if spans[1].Status().Code != codes.Error {
+		t.Errorf("Expected the failed migration's span to be an error")
+	}
+}
@@ -0,0 +1,123 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OutOfOrderError is returned when migrations older than the current version
+// have never been applied, usually because they were merged from another
+// branch after newer migrations ran. WithAllowOutOfOrder applies them
+// instead.
+type OutOfOrderError struct {
+	Versions []int
+}
+
+func (err *OutOfOrderError) Error() string {
+	versions := make([]string, len(err.Versions))
+	for idx, version := range err.Versions {
+		versions[idx] = fmt.Sprint(version)
+	}
+	return fmt.Sprintf("migrations %s are older than the current version but were never applied", strings.Join(versions, ", "))
+}
+
+// WithAllowOutOfOrder sets whether to apply migrations which are older than
+// the current version but were never applied. They run before any other
+// steps, and leave the recorded version alone. Without it they are reported
+// as an OutOfOrderError.
+func WithAllowOutOfOrder(allow bool) Option {
+	return func(cfg *config) {
+		cfg.allowOutOfOrder = allow
+	}
+}
+
+// unappliedVersions finds migrations at or below currentVersion whose last
+// history entry isn't an up. Anything older than the first entry predates the
+// history table, and anything up to the last Force or Baseline was vouched
+// for by hand, so both are assumed to have been applied.
+func unappliedVersions(history []HistoryEntry, byVersion map[int]Migration, currentVersion int) []int {
+	versioned := []HistoryEntry{}
+	for _, entry := range history {
+		if entry.Direction != directionRepeatable {
+			versioned = append(versioned, entry)
+		}
+	}
+	history = versioned
+	if len(history) == 0 {
+		return nil
+	}
+
+	firstVersion := history[0].Version
+	lastDirection := map[int]string{}
+	for _, entry := range history {
+		if entry.Direction == directionForce || entry.Direction == directionBaseline {
+			firstVersion = entry.Version
+			continue
+		}
+		if entry.Version < firstVersion {
+			firstVersion = entry.Version
+		}
+		lastDirection[entry.Version] = entry.Direction
+	}
+
+	unapplied := []int{}
+	for version := range byVersion {
+		if version <= firstVersion || version > currentVersion {
+			continue
+		}
+		if lastDirection[version] != directionUp {
+			unapplied = append(unapplied, version)
+		}
+	}
+	sort.Ints(unapplied)
+	return unapplied
+}
+
+// checkOutOfOrder fails if there are unapplied migrations, unless allow is
+// set, in which case they are added to steps
+func checkOutOfOrder(steps []step, byVersion map[int]Migration, unapplied []int, currentVersion int, targetVersion int, allow bool) ([]step, error) {
+	if len(unapplied) == 0 {
+		return steps, nil
+	}
+	if !allow {
+		return nil, &OutOfOrderError{Versions: unapplied}
+	}
+	return addOutOfOrder(steps, byVersion, unapplied, currentVersion, targetVersion)
+}
+
+// addOutOfOrder adds the up steps for unapplied migrations at or below
+// targetVersion ahead of steps, and drops the down steps of any which were
+// never applied.
+func addOutOfOrder(steps []step, byVersion map[int]Migration, unapplied []int, currentVersion int, targetVersion int) ([]step, error) {
+	skip := map[int]bool{}
+	withUnapplied := []step{}
+	for _, version := range unapplied {
+		skip[version] = true
+		if version > targetVersion {
+			continue
+		}
+		migration := byVersion[version]
+		if !migration.hasUp() {
+			return nil, missingUpError(version)
+		}
+		withUnapplied = append(withUnapplied, step{
+			Migration:  migration,
+			Direction:  directionUp,
+			outOfOrder: true,
+			previous:   currentVersion,
+		})
+	}
+
+	for _, s := range steps {
+		if s.Direction == directionDown && skip[s.Version] {
+			// The step before returns straight to the version below this one
+			if last := len(withUnapplied) - 1; last >= 0 && withUnapplied[last].Direction == directionDown {
+				withUnapplied[last].previous = s.previous
+			}
+			continue
+		}
+		withUnapplied = append(withUnapplied, s)
+	}
+	return withUnapplied, nil
+}
@@ -0,0 +1,141 @@
+package pgmigrate
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testByVersion(versions ...int) map[int]Migration {
+	byVersion := map[int]Migration{}
+	for _, version := range versions {
+		byVersion[version] = Migration{Version: version, UpFile: "up", DownFile: "down"}
+	}
+	return byVersion
+}
+
+func TestUnappliedVersions(t *testing.T) {
+	byVersion := testByVersion(1, 2, 3, 4, 5, 6)
+	history := []HistoryEntry{
+		{Version: 3, Direction: directionUp},
+		{Version: 5, Direction: directionUp},
+		{Version: 6, Direction: directionUp},
+	}
+
+	unapplied := unappliedVersions(history, byVersion, 6)
+	if len(unapplied) != 1 || unapplied[0] != 4 {
+		t.Errorf("Expected only 4 to be unapplied, got %v", unapplied)
+	}
+
+	history = append(history, HistoryEntry{Version: 5, Direction: directionForce})
+	if unapplied := unappliedVersions(history, byVersion, 6); len(unapplied) != 0 {
+		t.Errorf("Expected nothing after a force, got %v", unapplied)
+	}
+
+	baselined := []HistoryEntry{{Version: 4, Direction: directionBaseline}, {Version: 6, Direction: directionUp}}
+	if unapplied := unappliedVersions(baselined, byVersion, 6); len(unapplied) != 1 || unapplied[0] != 5 {
+		t.Errorf("Expected everything up to the baseline to be applied, got %v", unapplied)
+	}
+
+	// Repeatables are recorded at version 0, which isn't the start of history
+	repeatableFirst := append([]HistoryEntry{{Direction: directionRepeatable}}, history[:3]...)
+	if unapplied := unappliedVersions(repeatableFirst, byVersion, 6); len(unapplied) != 1 || unapplied[0] != 4 {
+		t.Errorf("Expected repeatables to be ignored, got %v", unapplied)
+	}
+
+	if unapplied := unappliedVersions(nil, byVersion, 6); len(unapplied) != 0 {
+		t.Errorf("Expected nothing without history, got %v", unapplied)
+	}
+}
+
+func TestAddOutOfOrder(t *testing.T) {
+	byVersion := testByVersion(1, 2, 3, 4)
+
+	up, err := planSteps(byVersion, 3, 4)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := checkOutOfOrder(up, byVersion, []int{2}, 3, 4, false); err == nil {
+		t.Error("Expected an OutOfOrderError")
+	}
+	steps, err := checkOutOfOrder(up, byVersion, []int{2}, 3, 4, true)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(steps) != 2 || steps[0].Version != 2 || steps[0].ResultVersion() != 3 || steps[1].ResultVersion() != 4 {
+		t.Errorf("Unexpected up steps %+v", steps)
+	}
+
+	down, err := planSteps(byVersion, 4, 1)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	steps, err = addOutOfOrder(down, byVersion, []int{3}, 4, 1)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(steps) != 2 || steps[0].Version != 4 || steps[0].ResultVersion() != 2 || steps[1].Version != 2 {
+		t.Errorf("Unexpected down steps %+v", steps)
+	}
+}
+
+func TestOutOfOrder(t *testing.T) {
+	files := map[string]string{
+		"20240601000000-foo.up.sql":   s1u,
+		"20240601000000-foo.down.sql": s1d,
+		"20240603000000-baz.up.sql":   s3u,
+		"20240603000000-baz.down.sql": s3d,
+	}
+	migrateDir := writeMigrationFiles(t, files)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_out_of_order")
+	defer conn.Close()
+
+	ctx := context.Background()
+	migrator := New(conn, WithSource(DirSource(migrateDir)), WithVersionScheme(TimestampVersions))
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Unable to migrate: %s", err.Error())
+	}
+
+	// Merged late from another branch
+	for name, content := range map[string]string{
+		"20240602000000-bar.up.sql":   s2u,
+		"20240602000000-bar.down.sql": s2d,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(migrateDir, name), []byte(content), 0660); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	err := migrator.Up(ctx)
+	if _, ok := err.(*OutOfOrderError); !ok {
+		t.Fatalf("Expected an OutOfOrderError, got %v", err)
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, status := range statuses {
+		if expect := status.Version != 20240602000000; status.Applied != expect {
+			t.Errorf("Migration %d: expected applied=%v, as Pending reports", status.Version, expect)
+		}
+	}
+
+	allowing := New(conn, WithSource(DirSource(migrateDir)), WithVersionScheme(TimestampVersions), WithAllowOutOfOrder(true))
+	if err := allowing.Up(ctx); err != nil {
+		t.Fatalf("Unable to apply out of order: %s", err.Error())
+	}
+	if _, err := conn.ExecContext(ctx, `SELECT * FROM bar`); err != nil {
+		t.Errorf("Expected bar to be created: %s", err.Error())
+	}
+	version, err := allowing.Version(ctx)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if version != 20240603000000 {
+		t.Errorf("Expected the version to stay at 20240603000000, got %d", version)
+	}
+}
@@ -0,0 +1,173 @@
+// Package pgauth connects to Postgres through lib/pq with credentials fetched
+// for each new connection, so short lived tokens, such as RDS IAM's, are
+// fresh whenever the pool reconnects. The rdsiam package supplies them for
+// RDS.
+package pgauth
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Params are where, and as whom, a connection string connects, with libpq's
+// environment variables and defaults filled in
+type Params struct {
+	Host     string
+	Port     string
+	User     string
+	Database string
+}
+
+// Credentials are the user and password for a connection. An empty User
+// leaves the connection string's in place.
+type Credentials struct {
+	User     string
+	Password string
+}
+
+// Provider supplies the credentials for each new connection
+type Provider interface {
+	Credentials(ctx context.Context, params Params) (Credentials, error)
+}
+
+// ProviderFunc is a Provider which is a function
+type ProviderFunc func(ctx context.Context, params Params) (Credentials, error)
+
+func (fn ProviderFunc) Credentials(ctx context.Context, params Params) (Credentials, error) {
+	return fn(ctx, params)
+}
+
+// Connector is a driver.Connector for sql.OpenDB, which asks its Provider
+// for credentials before each connection
+type Connector struct {
+	dsn      string
+	params   Params
+	provider Provider
+}
+
+// NewConnector creates a Connector for dsn, a URL or key=value connection
+// string, which shouldn't have a password
+func NewConnector(dsn string, provider Provider) (*Connector, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		var err error
+		dsn, err = pq.ParseURL(dsn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	values, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	params, err := resolveParams(values)
+	if err != nil {
+		return nil, err
+	}
+	return &Connector{dsn: dsn, params: params, provider: provider}, nil
+}
+
+// Params are the connection's host, port, user and database
+func (c *Connector) Params() Params {
+	return c.params
+}
+
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	credentials, err := c.provider.Credentials(ctx, c.params)
+	if err != nil {
+		return nil, fmt.Errorf("fetching database credentials: %w", err)
+	}
+	dsn := c.dsn + " password=" + quote(credentials.Password)
+	if credentials.User != "" {
+		dsn += " user=" + quote(credentials.User)
+	}
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *Connector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// resolveParams fills in what values leaves out as libpq would
+func resolveParams(values map[string]string) (Params, error) {
+	params := Params{
+		Host:     firstOf(values["host"], os.Getenv("PGHOST"), "localhost"),
+		Port:     firstOf(values["port"], os.Getenv("PGPORT"), "5432"),
+		User:     firstOf(values["user"], os.Getenv("PGUSER")),
+		Database: firstOf(values["dbname"], os.Getenv("PGDATABASE")),
+	}
+	if params.User == "" {
+		current, err := user.Current()
+		if err != nil {
+			return Params{}, err
+		}
+		params.User = current.Username
+	}
+	if params.Database == "" {
+		params.Database = params.User
+	}
+	return params, nil
+}
+
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// parseDSN reads a key=value connection string, in which values can be
+// single quoted with backslash escapes
+func parseDSN(dsn string) (map[string]string, error) {
+	values := map[string]string{}
+	rest := strings.TrimSpace(dsn)
+	for rest != "" {
+		equals := strings.Index(rest, "=")
+		if equals == -1 {
+			return nil, fmt.Errorf("connection string %q is missing = after %q", dsn, rest)
+		}
+		key := strings.TrimSpace(rest[:equals])
+		rest = strings.TrimLeft(rest[equals+1:], " ")
+
+		value := &strings.Builder{}
+		if strings.HasPrefix(rest, "'") {
+			idx := 1
+			for ; idx < len(rest) && rest[idx] != '\''; idx++ {
+				if rest[idx] == '\\' && idx+1 < len(rest) {
+					idx++
+				}
+				value.WriteByte(rest[idx])
+			}
+			if idx == len(rest) {
+				return nil, fmt.Errorf("connection string %q has an unterminated quote", dsn)
+			}
+			rest = rest[idx+1:]
+		} else {
+			end := strings.IndexAny(rest, " \t\n")
+			if end == -1 {
+				end = len(rest)
+			}
+			value.WriteString(rest[:end])
+			rest = rest[end:]
+		}
+		values[key] = value.String()
+		rest = strings.TrimSpace(rest)
+	}
+	return values, nil
+}
+
+// quote quotes a connection string value
+func quote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
@@ -0,0 +1,74 @@
+package pgauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParseDSN(t *testing.T) {
+	values, err := parseDSN(`host=db.example.com port=6432  user='mig rator' password='it\'s' sslmode=require`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for key, expect := range map[string]string{
+		"host":     "db.example.com",
+		"port":     "6432",
+		"user":     "mig rator",
+		"password": "it's",
+		"sslmode":  "require",
+	} {
+		if values[key] != expect {
+			t.Errorf("%s: expected %q, got %q", key, expect, values[key])
+		}
+	}
+
+	for _, dsn := range []string{"host", "user='unterminated"} {
+		if _, err := parseDSN(dsn); err == nil {
+			t.Errorf("Expected an error for %q", dsn)
+		}
+	}
+}
+
+func TestConnectorParams(t *testing.T) {
+	t.Setenv("PGHOST", "")
+	t.Setenv("PGPORT", "")
+	t.Setenv("PGDATABASE", "")
+	t.Setenv("PGUSER", "fallback")
+
+	connector, err := NewConnector("postgres://migrator@db.example.com:6432/app?sslmode=require", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if params := connector.Params(); params != (Params{Host: "db.example.com", Port: "6432", User: "migrator", Database: "app"}) {
+		t.Errorf("Wrong params %+v", params)
+	}
+
+	connector, err = NewConnector("host=db.example.com", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if params := connector.Params(); params != (Params{Host: "db.example.com", Port: "5432", User: "fallback", Database: "fallback"}) {
+		t.Errorf("Wrong defaults %+v", params)
+	}
+}
+
+func TestConnectAsksProvider(t *testing.T) {
+	asked := []Params{}
+	connector, err := NewConnector("host=db.example.com user=migrator dbname=app", ProviderFunc(func(ctx context.Context, params Params) (Credentials, error) {
+		asked = append(asked, params)
+		return Credentials{}, errors.New("no token")
+	}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := connector.Connect(context.Background()); err == nil {
+			t.Fatal("Expected the provider's error")
+		}
+	}
+	if len(asked) != 2 || asked[0].User != "migrator" {
+		t.Errorf("Expected the provider to be asked for each connection, got %v", asked)
+	}
+}
@@ -0,0 +1,115 @@
+package pgmigrate
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+const (
+	sqlStateUndefinedTable    = "42P01"
+	sqlStateInvalidSchemaName = "3F000"
+	sqlStateQueryCanceled     = "57014"
+	sqlStateLockNotAvailable  = "55P03"
+	sqlStateUndefinedColumn   = "42703"
+)
+
+// sqlStateError is implemented by the server errors of lib/pq (since v1.10)
+// and pgx (*pgconn.PgError), and of most other drivers.
+type sqlStateError interface {
+	error
+	SQLState() string
+}
+
+// sqlState returns the SQLSTATE code of a server error, or "" if err didn't
+// come from the server.
+func sqlState(err error) string {
+	var stateErr sqlStateError
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState()
+	}
+	return ""
+}
+
+// serverError holds the fields of an error reported by the server
+type serverError struct {
+	Message  string
+	Detail   string
+	Hint     string
+	Position string
+	Table    string
+	Where    string
+}
+
+// fieldGetter is lib/pq's accessor for error fields by protocol field code
+type fieldGetter interface {
+	Get(byte) string
+}
+
+// asServerError extracts the server's error fields from err without
+// depending on a particular driver. Drivers without an accessor, like pgx,
+// are read by the conventional field names on the error struct.
+func asServerError(err error) (serverError, bool) {
+	var stateErr sqlStateError
+	if !errors.As(err, &stateErr) {
+		return serverError{}, false
+	}
+
+	if getter, ok := stateErr.(fieldGetter); ok {
+		return serverError{
+			Message:  getter.Get('M'),
+			Detail:   getter.Get('D'),
+			Hint:     getter.Get('H'),
+			Position: getter.Get('P'),
+			Table:    getter.Get('t'),
+			Where:    getter.Get('W'),
+		}, true
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(stateErr))
+	if value.Kind() != reflect.Struct {
+		return serverError{Message: stateErr.Error()}, true
+	}
+	field := func(names ...string) string {
+		for _, name := range names {
+			fieldValue := value.FieldByName(name)
+			if !fieldValue.IsValid() || fieldValue.IsZero() {
+				continue
+			}
+			return fmt.Sprint(fieldValue.Interface())
+		}
+		return ""
+	}
+	return serverError{
+		Message:  field("Message"),
+		Detail:   field("Detail"),
+		Hint:     field("Hint"),
+		Position: field("Position"),
+		Table:    field("TableName", "Table"),
+		Where:    field("Where"),
+	}, true
+}
+
+// pgErrorFields are the log fields for the server's error details, if err
+// came from the server
+func pgErrorFields(err error) []interface{} {
+	fields := []interface{}{}
+	pgErr, ok := asServerError(err)
+	if !ok {
+		return fields
+	}
+	fields = append(fields, "message", pgErr.Message)
+	if pgErr.Detail != "" {
+		fields = append(fields, "detail", pgErr.Detail)
+	}
+	if pgErr.Position != "" {
+		fields = append(fields, "position", pgErr.Position)
+	}
+	if pgErr.Table != "" {
+		fields = append(fields, "table", pgErr.Table)
+	}
+	if pgErr.Where != "" {
+		fields = append(fields, "where", pgErr.Where)
+	}
+	return fields
+}
@@ -0,0 +1,66 @@
+package pgmigrate
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+)
+
+type stateError string
+
+func (err stateError) Error() string {
+	return "server error " + string(err)
+}
+
+func (err stateError) SQLState() string {
+	return string(err)
+}
+
+func TestSQLState(t *testing.T) {
+	wrapped := fmt.Errorf("querying: %w", stateError(sqlStateUndefinedTable))
+	if got := sqlState(wrapped); got != sqlStateUndefinedTable {
+		t.Errorf("Expected %s, got %q", sqlStateUndefinedTable, got)
+	}
+	if got := sqlState(&pq.Error{Code: "3F000"}); got != sqlStateInvalidSchemaName {
+		t.Errorf("Expected %s, got %q", sqlStateInvalidSchemaName, got)
+	}
+	if got := sqlState(&pgconn.PgError{Code: "42P01"}); got != sqlStateUndefinedTable {
+		t.Errorf("Expected %s, got %q", sqlStateUndefinedTable, got)
+	}
+	if got := sqlState(errors.New("not from the server")); got != "" {
+		t.Errorf("Expected no state, got %q", got)
+	}
+}
+
+func TestAsServerError(t *testing.T) {
+	for name, err := range map[string]error{
+		"pq": &pq.Error{
+			Code:     "42601",
+			Message:  "syntax error",
+			Position: "12",
+			Table:    "foo",
+		},
+		"pgx": &pgconn.PgError{
+			Code:      "42601",
+			Message:   "syntax error",
+			Position:  12,
+			TableName: "foo",
+		},
+	} {
+		details, ok := asServerError(fmt.Errorf("executing: %w", err))
+		if !ok {
+			t.Errorf("%s: expected a server error", name)
+			continue
+		}
+		if details.Message != "syntax error" || details.Position != "12" || details.Table != "foo" {
+			t.Errorf("%s: wrong details %+v", name, details)
+		}
+	}
+
+	if _, ok := asServerError(errors.New("not from the server")); ok {
+		t.Error("Expected a plain error not to be a server error")
+	}
+}
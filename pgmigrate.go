@@ -3,234 +3,433 @@ package pgmigrate
 import (
 	"context"
 	"database/sql"
-	"database/sql/driver"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
-	"os"
-	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
-
-	"github.com/lib/pq"
 )
 
-var shouldLog = os.Getenv("PGMIGRATE_LOG") != ""
+// execer is satisfied by both Queryer and *sql.Tx
+type execer interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+}
 
 type Queryer interface {
 	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
 	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
 	BeginTx(context.Context, *sql.TxOptions) (*sql.Tx, error)
 }
 
-func getVersion(ctx context.Context, conn Queryer) (int, error) {
-	currentVersionRow := conn.QueryRowContext(ctx, `SELECT version FROM _migrate_`)
-	currentVersion := 0
-	if err := currentVersionRow.Scan(&currentVersion); err != nil {
-		pgErr, ok := err.(*pq.Error)
-		if !ok {
-			return 0, err
-		}
-		if pgErr.Code.Name() != "undefined_table" {
-			return 0, pgErr
-		}
-		if _, err = conn.ExecContext(ctx, `
-		CREATE TABLE _migrate_ (version int primary key);
-		INSERT INTO _migrate_ (version) VALUES (0);
-		`); err != nil {
-			return 0, err
+// Version returns the version the database is currently migrated to,
+// creating the tracking table at version 0 if it doesn't exist yet
+func Version(ctx context.Context, conn Queryer) (int, error) {
+	return New(conn).Version(ctx)
+}
+
+// ReadVersion returns the version the database is currently migrated to
+// without creating anything, so it is safe for read only health checks. A
+// database which has never been migrated is at version 0.
+func ReadVersion(ctx context.Context, conn Queryer) (int, error) {
+	return New(conn).ReadVersion(ctx)
+}
+
+// MigrateDatabase migrates to targetVersion using the .sql files in
+// migrationsDir. A targetVersion of -1 migrates to the latest version.
+func MigrateDatabase(ctx context.Context, conn Queryer, migrationsDir string, targetVersion int, opts ...Option) error {
+	return MigrateSource(ctx, conn, DirSource(migrationsDir), targetVersion, opts...)
+}
+
+// MigrateSource migrates to targetVersion using the migrations listed by
+// source. A targetVersion of -1 migrates to the latest version.
+func MigrateSource(ctx context.Context, conn Queryer, source MigrationSource, targetVersion int, opts ...Option) error {
+	return New(conn, append(opts, WithSource(source))...).To(ctx, targetVersion)
+}
+
+func migrate(ctx context.Context, conn Queryer, source MigrationSource, targetVersion int, cfg *config) (err error) {
+	if cfg.preScripts != "" || cfg.postScripts != "" {
+		// Whatever the scripts set, such as a role, lasts only for this run
+		saved, saveErr := sessionSettings(ctx, conn)
+		if saveErr != nil {
+			return saveErr
 		}
+		defer func() {
+			if restoreErr := restoreSession(conn, saved); restoreErr != nil && err == nil {
+				err = restoreErr
+			}
+		}()
 	}
-	return currentVersion, nil
+
+	if err := runScripts(ctx, conn, cfg.preScripts, cfg.logger); err != nil {
+		return err
+	}
+
+	if err := migrateSteps(ctx, conn, source, targetVersion, cfg); err != nil {
+		return err
+	}
+
+	return runScripts(ctx, conn, cfg.postScripts, cfg.logger)
 }
 
-func MigrateDatabase(ctx context.Context, conn Queryer, migrationsDir string, targetVersion int) error {
+func migrateSteps(ctx context.Context, conn Queryer, source MigrationSource, targetVersion int, cfg *config) error {
 
-	currentVersion, err := getVersion(ctx, conn)
+	table := cfg.table
+	currentVersion, err := table.getVersion(ctx, conn)
 	if err != nil {
 		return err
 	}
 
-	if shouldLog {
-		log.Printf("Migrate from %d to %d", currentVersion, targetVersion)
+	if err := table.ensureDirtyColumn(ctx, conn); err != nil {
+		return err
 	}
 
-	migrateFiles, err := ioutil.ReadDir(migrationsDir)
-	if err != nil {
+	if err := table.ensureFailureColumns(ctx, conn); err != nil {
 		return err
 	}
 
-	upFiles := map[int]string{}
-	downFiles := map[int]string{}
-	maxMigration := 0
+	if err := table.ensureHistoryTable(ctx, conn); err != nil {
+		return err
+	}
 
-	for _, file := range migrateFiles {
-		name := file.Name()
-		parts := strings.Split(name, ".")
-		if len(parts) != 3 {
-			continue
-		}
-		if parts[2] != "sql" {
-			continue
+	if cfg.scheme == TimestampVersions {
+		if err := table.ensureBigintVersions(ctx, conn); err != nil {
+			return err
 		}
+	}
 
-		numberStr := strings.Split(parts[0], "-")[0]
-		numberUI64, err := strconv.ParseUint(numberStr, 10, 64)
-		if err != nil {
-			return fmt.Errorf("invalid version filename %s", name)
-		}
-		number := int(numberUI64)
+	if err := table.checkDirty(ctx, conn); err != nil {
+		return err
+	}
 
-		if maxMigration < number {
-			maxMigration = number
-		}
+	cfg.logger.Info("migrate", "from", currentVersion, "to", targetVersion)
+	cfg.reportVersion(currentVersion)
 
-		switch parts[1] {
-		case "up":
-			upFiles[number] = name
-		case "down":
-			downFiles[number] = name
-		default:
-			return fmt.Errorf("Bad filename: %s", name)
-		}
+	byVersion, maxMigration, err := loadMigrations(cfg)
+	if err != nil {
+		return err
 	}
 
-	for idx := 1; idx < maxMigration; idx++ {
-		if _, ok := upFiles[idx]; !ok {
-			return fmt.Errorf("Missing Up migration %d", idx)
-		}
-		if _, ok := downFiles[idx]; !ok {
-			return fmt.Errorf("Missing Down migration %d", idx)
-		}
+	if err := table.verifyChecksums(ctx, conn, source, byVersion, currentVersion); err != nil {
+		return err
 	}
 
 	if targetVersion == -1 {
 		targetVersion = maxMigration
 	}
+	cfg.reportPlan(currentVersion, targetVersion)
 
-	if targetVersion > currentVersion {
-		for idx := currentVersion + 1; idx <= targetVersion; idx++ {
-			if err := runFile(ctx, conn, filepath.Join(migrationsDir, upFiles[idx]), idx); err != nil {
-				return err
-			}
+	steps, err := planSteps(byVersion, currentVersion, targetVersion)
+	if err != nil {
+		return err
+	}
+
+	history, err := table.history(ctx, conn)
+	if err != nil {
+		return err
+	}
+	steps, err = checkOutOfOrder(steps, byVersion, unappliedVersions(history, byVersion, currentVersion), currentVersion, targetVersion, cfg.allowOutOfOrder)
+	if err != nil {
+		return err
+	}
+
+	if err := checkDown(steps, currentVersion, targetVersion, cfg.allowDown); err != nil {
+		return err
+	}
+
+	if err := checkPolicy(cfg, source, steps); err != nil {
+		return err
+	}
+
+	if cfg.lint {
+		findings, err := lintSteps(steps, cfg.lintServerVersion)
+		if err != nil {
+			return err
 		}
-	} else if targetVersion < currentVersion {
-		for idx := currentVersion; idx > targetVersion; idx-- {
-			if err := runFile(ctx, conn, filepath.Join(migrationsDir, downFiles[idx]), idx-1); err != nil {
-				return err
-			}
+		for _, finding := range findings {
+			cfg.logger.Error("lint_warning", "version", finding.Version, "filename", finding.Filename, "rule", finding.Rule, "message", finding.Message)
 		}
 	}
 
-	return nil
+	ctx, endRun := cfg.startRun(ctx, currentVersion, targetVersion)
+	err = runPlanned(ctx, conn, source, steps, currentVersion, targetVersion, targetVersion == maxMigration, cfg)
+	endRun(err)
+	return err
 }
 
-func runFile(ctx context.Context, conn Queryer, filename string, version int) error {
-	if shouldLog {
-		log.Printf("File: %s", filename)
+// runPlanned runs the planned steps, then the repeatable migrations if the
+// run reaches the latest version
+func runPlanned(ctx context.Context, conn Queryer, source MigrationSource, steps []step, currentVersion int, targetVersion int, latest bool, cfg *config) error {
+	if cfg.singleTransaction {
+		if err := runSingleTransaction(ctx, conn, source, steps, currentVersion, targetVersion, cfg); err != nil {
+			return err
+		}
+	} else if err := runSteps(ctx, conn, source, steps, currentVersion, targetVersion, cfg); err != nil {
+		return err
 	}
-	bytes, err := ioutil.ReadFile(filename)
-	if err != nil {
+	if err := cfg.table.clearFailure(ctx, conn); err != nil {
 		return err
 	}
 
-	tx, err := conn.BeginTx(ctx, nil)
-	if err != nil {
-		return err
+	if latest {
+		return applyRepeatables(ctx, conn, cfg)
 	}
+	return nil
+}
 
-	if _, err := conn.ExecContext(ctx, string(bytes)); err != nil {
-		tx.Rollback() //nolint: errcheck
-		if err, ok := err.(*pq.Error); ok {
-			log.Printf("PG Error in %s: %s", filename, err.Message)
-			if err.Detail != "" {
-				log.Printf("Detail: %s", err.Detail)
-			}
-			if err.Position != "" {
-				log.Printf("Position: %s", err.Position)
-			}
-			if err.Table != "" {
-				log.Printf("Table: %s", err.Table)
-			}
-			if err.Where != "" {
-				log.Printf("Where: %s", err.Where)
+// runSteps runs each step in turn, stopping at the first which fails, which
+// is recorded for Resume
+func runSteps(ctx context.Context, conn Queryer, source MigrationSource, steps []step, currentVersion int, targetVersion int, cfg *config) error {
+	version := currentVersion
+	for _, step := range steps {
+		if err := runFile(ctx, conn, source, step, cfg); err != nil {
+			cfg.table.recordFailure(conn, targetVersion, step, err)
+			if ctx.Err() != nil {
+				return &InterruptedError{Version: version, Err: err}
 			}
+			return err
 		}
-		return fmt.Errorf("executing %s: %w", filename, err)
+		version = step.ResultVersion()
+		cfg.reportVersion(version)
 	}
+	return nil
+}
 
-	if _, err := conn.ExecContext(ctx, `UPDATE _migrate_ SET version = $1;`, version); err != nil {
-		tx.Rollback() //nolint: errcheck
-		return err
+// InterruptedError is returned when ctx is cancelled part way through a run.
+// The migration in progress was rolled back, unless it ran without a
+// transaction, leaving the database at Version.
+type InterruptedError struct {
+	Version int
+	Err     error
+}
+
+func (err *InterruptedError) Error() string {
+	return fmt.Sprintf("interrupted, the database is at version %d: %s", err.Version, err.Err)
+}
+
+func (err *InterruptedError) Unwrap() error {
+	return err.Err
+}
+
+// runFile applies one file, logging migration_start then either
+// migration_done or migration_failed
+func runFile(ctx context.Context, conn Queryer, source MigrationSource, step step, cfg *config) error {
+	logger := cfg.logger
+
+	fields := []interface{}{"version", step.Version, "filename", step.Filename(), "direction", step.Direction}
+	logger.Info("migration_start", fields...)
+
+	stopWatching := watchBlockers(ctx, conn, cfg, step)
+	defer stopWatching()
+	if cfg.notices != nil {
+		defer cfg.notices.running(logger, step)()
 	}
 
-	if err := tx.Commit(); err != nil {
+	ctx, endSpan := cfg.startMigration(ctx, step)
+	started := time.Now()
+	rowsAffected, err := applyWithTimeout(ctx, conn, cfg, source, step, started)
+	for attempt := 1; err != nil && cfg.retryLock(ctx, conn, step, err, attempt); attempt++ {
+		rowsAffected, err = applyWithTimeout(ctx, conn, cfg, source, step, started)
+	}
+	duration := time.Since(started)
+	endSpan(rowsAffected, err)
+	cfg.reportStep(step, duration, err)
+	fields = append(fields, "duration", duration)
+	if err != nil {
+		migrationErr := newMigrationError(step, err)
+		err = migrationErr
+		fields = append(fields, "error", err.Error())
+		if migrationErr.Line > 0 {
+			fields = append(fields, "line", migrationErr.Line, "column", migrationErr.Column)
+		}
+		logger.Error("migration_failed", append(fields, pgErrorFields(err)...)...)
+		cfg.hooks.onError(ctx, step, err)
 		return err
 	}
 
+	cfg.reportApplied(step, duration)
+	logger.Info("migration_done", append(fields, "rows_affected", rowsAffected)...)
 	return nil
 }
 
-type CallbackConnector struct {
-	*pq.Connector
-	Callback func(context.Context, driver.Conn) error
-}
+func applyFile(ctx context.Context, conn Queryer, cfg *config, source MigrationSource, step step, started time.Time) (int64, error) {
+	table := cfg.table
+	migration := step.Migration
+	filename := step.Filename()
+	fn := step.Func()
+
+	var bytes []byte
+	var rendered renderedFile
+	var fileDirectives directives
+	if fn == nil {
+		var err error
+		rendered, err = renderFile(cfg, source, filename)
+		if err != nil {
+			return 0, err
+		}
+		bytes = rendered.content
 
-func (tc *CallbackConnector) Connect(ctx context.Context) (driver.Conn, error) {
-	conn, err := tc.Connector.Connect(ctx)
-	if err != nil {
-		return nil, err
+		fileDirectives, err = parseDirectives(bytes)
+		if err != nil {
+			return 0, fmt.Errorf("reading directives in %s: %w", filename, err)
+		}
+		if len(fileDirectives.environments) > 0 {
+			return 0, fmt.Errorf("%s: the environments directive is only for seed files", filename)
+		}
+		if fileDirectives.statementTimeout == 0 {
+			fileDirectives.statementTimeout = cfg.migrationTimeout
+		}
+		if fileDirectives.lockTimeout == 0 {
+			fileDirectives.lockTimeout = cfg.lockTimeout
+		}
 	}
-	if err := tc.Callback(ctx, conn); err != nil {
-		return nil, err
+
+	if cfg.runTx != nil {
+		return applyInRunTransaction(ctx, cfg.runTx, cfg, step, fileDirectives, rendered, started)
 	}
-	return conn, nil
-}
 
-func GetTestSchema(testURL string, name string) (*sql.DB, error) {
+	if err := table.markDirty(ctx, conn, migration.Version); err != nil {
+		return 0, err
+	}
 
-	connector, err := pq.NewConnector(testURL)
-	if err != nil {
-		return nil, err
+	var rowsAffected int64
+	if fileDirectives.noTransaction {
+		var err error
+		rowsAffected, err = execNoTransaction(ctx, conn, cfg, step, fileDirectives, string(bytes))
+		if err != nil {
+			// There is no telling how much of the file ran, so it stays dirty
+			return 0, fmt.Errorf("executing %s without a transaction, the database is now dirty: %w", filename, err)
+		}
 	}
 
-	conn := sql.OpenDB(connector)
+	tx, err := conn.BeginTx(ctx, fileDirectives.txOptions())
 	if err != nil {
-		return nil, err
+		if !fileDirectives.noTransaction {
+			table.clearDirty(ctx, conn) //nolint: errcheck
+		}
+		return 0, err
 	}
 
-	for tries := 0; tries < 30; tries++ {
-		err = conn.Ping()
-		if err == nil {
-			break
+	// A clean rollback leaves the database as it was, so it isn't dirty. A
+	// cancelled ctx has already rolled the transaction back, and would stop
+	// the flag being cleared.
+	rollback := func() {
+		if err := tx.Rollback(); (err == nil || errors.Is(err, sql.ErrTxDone)) && !fileDirectives.noTransaction {
+			table.clearDirty(context.Background(), conn) //nolint: errcheck
 		}
-		time.Sleep(time.Second)
 	}
+
+	fileRowsAffected, err := applyInTx(ctx, tx, cfg, step, fileDirectives, rendered, started)
 	if err != nil {
-		return nil, err
+		rollback()
+		return 0, err
+	}
+	if !fileDirectives.noTransaction {
+		rowsAffected = fileRowsAffected
 	}
 
-	ctx := context.Background()
-	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`
-		DROP SCHEMA IF EXISTS %s CASCADE;
-		CREATE SCHEMA %s;
-	`, name, name)); err != nil {
-		return nil, err
+	if err := tx.Commit(); err != nil {
+		return 0, err
 	}
-	conn.Close()
 
-	testConnector := &CallbackConnector{
-		Connector: connector,
-		Callback: func(ctx context.Context, conn driver.Conn) error {
-			execerCtx := conn.(driver.ExecerContext)
-			_, err := execerCtx.ExecContext(ctx, fmt.Sprintf("SET search_path TO %s", name), []driver.NamedValue{})
-			if err != nil {
-				return fmt.Errorf("preparing connection to search_path: %w", err)
+	return rowsAffected, nil
+}
+
+// applyInTx runs a step's Go function or, unless it ran without a
+// transaction, its file in tx, and records the new version and history
+func applyInTx(ctx context.Context, tx *sql.Tx, cfg *config, step step, fileDirectives directives, rendered renderedFile, started time.Time) (int64, error) {
+	table := cfg.table
+	migration := step.Migration
+	filename := step.Filename()
+
+	if err := cfg.hooks.beforeEach(ctx, tx, step); err != nil {
+		return 0, err
+	}
+
+	var rowsAffected int64
+	if fn := step.Func(); fn != nil {
+		if err := fn(ctx, tx); err != nil {
+			return 0, fmt.Errorf("running %s: %w", filename, err)
+		}
+	} else if !fileDirectives.noTransaction {
+		for _, statement := range fileDirectives.setStatements(true) {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return 0, fmt.Errorf("applying directives in %s: %w", filename, err)
 			}
-			return nil
-		},
+		}
+		var err error
+		rowsAffected, err = execSQL(ctx, tx, cfg, step, string(rendered.content))
+		if err != nil {
+			return 0, fmt.Errorf("executing %s: %w", filename, err)
+		}
+	}
+
+	if err := cfg.hooks.afterEach(ctx, tx, step); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET version = $1, dirty_version = NULL;`, table.versionTable()), step.ResultVersion()); err != nil {
+		return 0, err
+	}
+
+	// Go functions have no content to checksum, so they are never verified
+	if err := table.recordHistory(ctx, tx, HistoryEntry{
+		Version:   migration.Version,
+		Name:      migration.Name,
+		Filename:  filename,
+		Checksum:  rendered.checksum,
+		Direction: step.Direction,
+		AppliedAt: started,
+		Duration:  time.Since(started),
+		Variables: rendered.variables,
+	}); err != nil {
+		return 0, err
 	}
+	return rowsAffected, nil
+}
 
-	return sql.OpenDB(testConnector), nil
+// execNoTransaction runs content directly on the session, with any timeouts
+// set on the session for just this file
+func execNoTransaction(ctx context.Context, conn Queryer, cfg *config, step step, fileDirectives directives, content string) (int64, error) {
+	for _, statement := range fileDirectives.setStatements(false) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return 0, err
+		}
+	}
+	rowsAffected, execErr := execSQL(ctx, conn, cfg, step, content)
+	for _, statement := range fileDirectives.resetStatements() {
+		if _, err := conn.ExecContext(ctx, statement); err != nil && execErr == nil {
+			return 0, err
+		}
+	}
+	return rowsAffected, execErr
+}
+
+// execSQL runs a file's content as one query or, with WithSplitStatements, a
+// statement at a time, returning the rows affected
+func execSQL(ctx context.Context, conn execer, cfg *config, step step, content string) (int64, error) {
+	cfg.logger.Info("migration_sql", "version", step.Version, "filename", step.Filename(), "sql", content)
+	if !cfg.splitStatements {
+		result, err := conn.ExecContext(ctx, content)
+		if err != nil {
+			return 0, &locatedError{err: err, content: content}
+		}
+		rowsAffected, _ := result.RowsAffected()
+		return rowsAffected, nil
+	}
+
+	statements := SplitStatements(content)
+	var rowsAffected int64
+	offset := 0
+	for idx, statement := range statements {
+		offset += strings.Index(content[offset:], statement)
+		result, err := conn.ExecContext(ctx, statement)
+		if err != nil {
+			return 0, fmt.Errorf("statement %d of %d: %w", idx+1, len(statements), &locatedError{err: err, content: content, offset: offset})
+		}
+		offset += len(statement)
+		statementRows, _ := result.RowsAffected()
+		rowsAffected += statementRows
+		cfg.logger.Info("statement_done", "version", step.Version, "filename", step.Filename(), "statement", idx+1, "of", len(statements), "rows_affected", statementRows)
+	}
+	return rowsAffected, nil
 }
@@ -2,11 +2,12 @@ package pgmigrate
 
 import (
 	"context"
-	"io/ioutil"
+	"database/sql"
 	"os"
-	"path/filepath"
 	"strings"
 	"testing"
+
+	_ "github.com/lib/pq"
 )
 
 var s1u string = `CREATE TABLE foo (id int);`
@@ -16,36 +17,35 @@ var s2d string = `DROP TABLE bar`
 var s3u string = `CREATE TABLE baz (id int);`
 var s3d string = `DROP TABLE baz;`
 
-func TestMigrate(t *testing.T) {
-	migrateDir, err := ioutil.TempDir("", "")
-	if err != nil {
-		t.Fatal(err.Error())
-	}
-
-	defer os.RemoveAll(migrateDir)
+var testMigrations = map[string]string{
+	"001-foo.up.sql":   s1u,
+	"001-foo.down.sql": s1d,
+	"002-bar.up.sql":   s2u,
+	"002-bar.down.sql": s2d,
+	"003-baz.up.sql":   s3u,
+	"003-baz.down.sql": s3d,
+}
 
-	for key, content := range map[string]string{
-		"001-foo.up.sql":   s1u,
-		"001-foo.down.sql": s1d,
-		"002-bar.up.sql":   s2u,
-		"002-bar.down.sql": s2d,
-		"003-baz.up.sql":   s3u,
-		"003-baz.down.sql": s3d,
-	} {
-		if err := ioutil.WriteFile(filepath.Join(migrateDir, key), []byte(content), 0660); err != nil {
-			t.Fatal(err.Error())
-		}
-	}
+func getTestConn(t *testing.T, schema string) *sql.DB {
+	t.Helper()
 
 	testURL := os.Getenv("TEST_DB")
 	if !strings.Contains(testURL, "test") {
 		t.Fatalf("Not a test URL: %s", testURL)
 	}
 
-	conn, err := GetTestSchema(testURL, "test")
+	conn, err := GetTestSchema(testURL, schema)
 	if err != nil {
 		t.Fatal(err.Error())
 	}
+	return conn
+}
+
+func TestMigrate(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test")
 
 	ctx := context.Background()
 	defer conn.Close()
@@ -69,7 +69,7 @@ func TestMigrate(t *testing.T) {
 
 	assertVersion(2)
 
-	if err := MigrateDatabase(ctx, conn, migrateDir, 1); err != nil {
+	if err := MigrateDatabase(ctx, conn, migrateDir, 1, WithAllowDown(true)); err != nil {
 		t.Fatalf("Unable to migrate: %s", err.Error())
 	}
 
@@ -82,3 +82,58 @@ func TestMigrate(t *testing.T) {
 	assertVersion(3)
 
 }
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"002-bad.up.sql":   `CREATE TABLE bar (id int); SELECT * FROM missing;`,
+		"002-bad.down.sql": s2d,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_rollback")
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	if err := MigrateDatabase(ctx, conn, migrateDir, -1); err == nil {
+		t.Fatal("Expected the bad migration to fail")
+	}
+
+	if v, err := getVersion(ctx, conn); err != nil {
+		t.Fatal(err.Error())
+	} else if v != 1 {
+		t.Fatalf("Wrong version %d (expected 1)", v)
+	}
+
+	if _, err := conn.ExecContext(ctx, `SELECT * FROM bar`); err == nil {
+		t.Fatal("Expected the statements before the failure to be rolled back")
+	}
+
+	entries, err := History(ctx, conn)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected only the successful migration in history, got %d", len(entries))
+	}
+}
+
+func TestReadVersion(t *testing.T) {
+	conn := getTestConn(t, "test_read_version")
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	version, err := ReadVersion(ctx, conn)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if version != 0 {
+		t.Errorf("Expected version 0, got %d", version)
+	}
+	if _, err := defaultTable.peekVersion(ctx, conn); err != errNoTable {
+		t.Errorf("Expected ReadVersion not to create the table, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+// Package pgxmigrate runs pgmigrate migrations with pgx, for applications
+// which have a pgxpool.Pool or pgx.Conn rather than a database/sql pool.
+package pgxmigrate
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gopkg.daemonl.com/pgmigrate"
+)
+
+// OpenDB wraps pool as a *sql.DB which shares the pool's connections.
+// Closing the *sql.DB does not close the pool.
+func OpenDB(pool *pgxpool.Pool) *sql.DB {
+	return stdlib.OpenDBFromPool(pool)
+}
+
+// OpenConnConfig opens a *sql.DB with the same configuration as conn.
+// database/sql can't share a single pgx.Conn, so migrations run on a new
+// connection, which is closed with the *sql.DB.
+func OpenConnConfig(conn *pgx.Conn) *sql.DB {
+	return stdlib.OpenDB(*conn.Config())
+}
+
+// New creates a pgmigrate.Migrator which runs on pool, along with the
+// *sql.DB wrapping it, which the caller closes once done with the Migrator
+func New(pool *pgxpool.Pool, opts ...pgmigrate.Option) (*pgmigrate.Migrator, *sql.DB) {
+	db := OpenDB(pool)
+	return pgmigrate.New(db, opts...), db
+}
+
+// MigrateDatabase is pgmigrate.MigrateDatabase using pool
+func MigrateDatabase(ctx context.Context, pool *pgxpool.Pool, migrationsDir string, targetVersion int, opts ...pgmigrate.Option) error {
+	db := OpenDB(pool)
+	defer db.Close()
+	return pgmigrate.MigrateDatabase(ctx, db, migrationsDir, targetVersion, opts...)
+}
+
+// MigrateConn is pgmigrate.MigrateDatabase using a new connection configured
+// like conn
+func MigrateConn(ctx context.Context, conn *pgx.Conn, migrationsDir string, targetVersion int, opts ...pgmigrate.Option) error {
+	db := OpenConnConfig(conn)
+	defer db.Close()
+	return pgmigrate.MigrateDatabase(ctx, db, migrationsDir, targetVersion, opts...)
+}
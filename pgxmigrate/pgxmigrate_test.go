@@ -0,0 +1,75 @@
+package pgxmigrate
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gopkg.daemonl.com/pgmigrate"
+)
+
+func TestMigratePool(t *testing.T) {
+	migrateDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(migrateDir)
+
+	for key, content := range map[string]string{
+		"001-foo.up.sql":   `CREATE TABLE foo (id int);`,
+		"001-foo.down.sql": `DROP TABLE foo;`,
+		"002-bar.up.sql":   `CREATE TABLE bar (id int); CREATE TABLE baz (id int);`,
+		"002-bar.down.sql": `DROP TABLE bar; DROP TABLE baz;`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(migrateDir, key), []byte(content), 0660); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	testURL := os.Getenv("TEST_DB")
+	if !strings.Contains(testURL, "test") {
+		t.Fatalf("Not a test URL: %s", testURL)
+	}
+
+	ctx := context.Background()
+
+	config, err := pgxpool.ParseConfig(testURL)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	config.ConnConfig.RuntimeParams["search_path"] = "test_pgx"
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer pool.Close()
+
+	if _, err := pool.Exec(ctx, `DROP SCHEMA IF EXISTS test_pgx CASCADE; CREATE SCHEMA test_pgx;`); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Starting from an empty schema exercises detecting undefined_table from
+	// a pgx error
+	if err := MigrateDatabase(ctx, pool, migrateDir, -1); err != nil {
+		t.Fatalf("Unable to migrate: %s", err.Error())
+	}
+
+	db := OpenDB(pool)
+	defer db.Close()
+	if v, err := pgmigrate.Version(ctx, db); err != nil {
+		t.Fatal(err.Error())
+	} else if v != 2 {
+		t.Fatalf("Wrong version %d (expected 2)", v)
+	}
+
+	migrator, migratorDB := New(pool, pgmigrate.WithSource(pgmigrate.DirSource(migrateDir)), pgmigrate.WithAllowDown(true))
+	defer migratorDB.Close()
+	if err := migrator.Down(ctx); err != nil {
+		t.Fatalf("Unable to migrate down: %s", err.Error())
+	}
+}
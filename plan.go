@@ -0,0 +1,230 @@
+package pgmigrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// step is a single file to run as part of a migration
+type step struct {
+	Migration
+	Direction string
+
+	// previous is the version a down step returns to, or the version an out
+	// of order up step leaves in place
+	previous   int
+	outOfOrder bool
+}
+
+// Filename is the file to run, or a label for a Go function
+func (s step) Filename() string {
+	if fn := s.Func(); fn != nil {
+		return fmt.Sprintf("go:%d-%s.%s", s.Version, s.Name, s.Direction)
+	}
+	if s.Direction == directionDown {
+		return s.DownFile
+	}
+	return s.UpFile
+}
+
+// Func is the Go function to run in place of a file, if any
+func (s step) Func() GoMigrationFunc {
+	if s.Direction == directionDown {
+		return s.DownFunc
+	}
+	return s.UpFunc
+}
+
+// ResultVersion is the version the database is at after the step has run
+func (s step) ResultVersion() int {
+	if s.Direction == directionDown || s.outOfOrder {
+		return s.previous
+	}
+	return s.Version
+}
+
+// loadMigrations lists and validates the migrations from the configured
+// source, returning them by version along with the highest version.
+func loadMigrations(cfg *config) (map[int]Migration, int, error) {
+	byVersion, firstMigration, maxMigration, err := readMigrations(cfg)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := cfg.scheme.validate(byVersion, firstMigration, maxMigration, cfg.forwardOnly); err != nil {
+		return nil, 0, err
+	}
+	return byVersion, maxMigration, nil
+}
+
+// readMigrations lists the migrations from the configured source by version,
+// along with the first version, which is later than 1 after a squash, and
+// the highest, without checking the versions fit the scheme
+func readMigrations(cfg *config) (map[int]Migration, int, int, error) {
+	migrations, err := listMigrations(cfg.source)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if cfg.strict {
+		if err := checkStrict(cfg.source, migrations); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	if cfg.lockFile != "" {
+		lockFile, err := cfg.source.ReadFile(cfg.lockFile)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("reading lock file: %w", err)
+		}
+		if err := verifyLock(migrations, lockFile); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+
+	byVersion := map[int]Migration{}
+	maxMigration := 0
+
+	for _, migration := range migrations {
+		if maxMigration < migration.Version {
+			maxMigration = migration.Version
+		}
+		byVersion[migration.Version] = migration
+	}
+
+	// A squashed first migration stands in for every version before it
+	firstMigration := 1
+	if len(migrations) > 0 && migrations[0].UpFile != "" {
+		first := migrations[0]
+		content, err := readExpanded(cfg.source, first.UpFile)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		fileDirectives, err := parseDirectives(content)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("%s: %w", first.UpFile, err)
+		}
+		if fileDirectives.squashed {
+			first.squashed = true
+			byVersion[first.Version] = first
+			firstMigration = first.Version
+		}
+	}
+	return byVersion, firstMigration, maxMigration, nil
+}
+
+// planSteps lists the files to run, in order, to get from currentVersion to
+// targetVersion. Both must be 0 or a known version.
+func planSteps(byVersion map[int]Migration, currentVersion int, targetVersion int) ([]step, error) {
+	versions := make([]int, 0, len(byVersion))
+	for version := range byVersion {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	if len(versions) > 0 && byVersion[versions[0]].squashed && currentVersion > 0 && currentVersion < versions[0] {
+		return nil, fmt.Errorf("version %d is part way through squashed migration %d, migrate past it with the original files first", currentVersion, versions[0])
+	}
+
+	steps := []step{}
+	if targetVersion > currentVersion {
+		if _, ok := byVersion[targetVersion]; !ok {
+			return nil, missingUpError(targetVersion)
+		}
+		for _, version := range versions {
+			if version <= currentVersion || version > targetVersion {
+				continue
+			}
+			migration := byVersion[version]
+			if !migration.hasUp() {
+				return nil, missingUpError(version)
+			}
+			steps = append(steps, step{Migration: migration, Direction: directionUp})
+		}
+	} else if targetVersion < currentVersion {
+		if _, ok := byVersion[currentVersion]; !ok {
+			return nil, missingDownError(currentVersion)
+		}
+		if _, ok := byVersion[targetVersion]; !ok && targetVersion != 0 {
+			return nil, fmt.Errorf("unknown target version %d", targetVersion)
+		}
+		for idx := len(versions) - 1; idx >= 0; idx-- {
+			version := versions[idx]
+			if version > currentVersion || version <= targetVersion {
+				continue
+			}
+			migration := byVersion[version]
+			if !migration.hasDown() {
+				return nil, missingDownError(version)
+			}
+			previous := 0
+			if idx > 0 {
+				previous = versions[idx-1]
+			}
+			steps = append(steps, step{Migration: migration, Direction: directionDown, previous: previous})
+		}
+	}
+	return steps, nil
+}
+
+// DownNotAllowedError is returned when a run would migrate down without
+// WithAllowDown
+type DownNotAllowedError struct {
+	From int
+	To   int
+}
+
+func (err *DownNotAllowedError) Error() string {
+	return fmt.Sprintf("migrating down from %d to %d requires AllowDown", err.From, err.To)
+}
+
+// checkDown refuses steps which include a down unless allow is set
+func checkDown(steps []step, currentVersion int, targetVersion int, allow bool) error {
+	if allow {
+		return nil
+	}
+	for _, step := range steps {
+		if step.Direction == directionDown {
+			return &DownNotAllowedError{From: currentVersion, To: targetVersion}
+		}
+	}
+	return nil
+}
+
+// resolvePlan works out the steps from the current version to targetVersion
+// without modifying the database.
+func resolvePlan(ctx context.Context, conn Queryer, cfg *config, targetVersion int) (int, int, []step, error) {
+	table := cfg.table
+	currentVersion, err := table.peekVersion(ctx, conn)
+	if err != nil && err != errNoTable {
+		return 0, 0, nil, err
+	}
+
+	byVersion, maxMigration, err := loadMigrations(cfg)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	if targetVersion == -1 {
+		targetVersion = maxMigration
+	}
+
+	steps, err := planSteps(byVersion, currentVersion, targetVersion)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if err := checkDown(steps, currentVersion, targetVersion, cfg.allowDown); err != nil {
+		return 0, 0, nil, err
+	}
+
+	history, err := table.history(ctx, conn)
+	if err != nil && sqlState(err) != sqlStateUndefinedTable {
+		return 0, 0, nil, err
+	}
+	steps, err = checkOutOfOrder(steps, byVersion, unappliedVersions(history, byVersion, currentVersion), currentVersion, targetVersion, cfg.allowOutOfOrder)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if err := checkPolicy(cfg, cfg.source, steps); err != nil {
+		return 0, 0, nil, err
+	}
+	return currentVersion, targetVersion, steps, nil
+}
@@ -0,0 +1,91 @@
+package pgmigrate
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPlanSteps(t *testing.T) {
+	byVersion := map[int]Migration{}
+	for version := 1; version <= 3; version++ {
+		byVersion[version] = Migration{
+			Version:  version,
+			UpFile:   "up",
+			DownFile: "down",
+		}
+	}
+
+	for _, tc := range []struct {
+		current, target int
+		expect          []int
+		direction       string
+	}{
+		{0, 3, []int{1, 2, 3}, directionUp},
+		{1, 2, []int{2}, directionUp},
+		{3, 1, []int{3, 2}, directionDown},
+		{2, 2, []int{}, ""},
+	} {
+		steps, err := planSteps(byVersion, tc.current, tc.target)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(steps) != len(tc.expect) {
+			t.Fatalf("%d to %d: expected %d steps, got %d", tc.current, tc.target, len(tc.expect), len(steps))
+		}
+		for idx, version := range tc.expect {
+			if steps[idx].Version != version || steps[idx].Direction != tc.direction {
+				t.Errorf("%d to %d: step %d was %d %s", tc.current, tc.target, idx, steps[idx].Version, steps[idx].Direction)
+			}
+		}
+	}
+
+	if _, err := planSteps(byVersion, 0, 4); err == nil {
+		t.Error("Expected an error planning past the last migration")
+	}
+}
+
+func TestForwardOnly(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql": s1u,
+		"002-bar.up.sql": s2u,
+		"003-baz.up.sql": s3u,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	if _, _, err := loadMigrations(newConfig([]Option{WithSource(DirSource(migrateDir))})); err == nil {
+		t.Error("Expected missing down files to be an error by default")
+	}
+
+	byVersion, maxMigration, err := loadMigrations(newConfig([]Option{WithSource(DirSource(migrateDir)), WithForwardOnly(true)}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := planSteps(byVersion, 0, maxMigration); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := planSteps(byVersion, maxMigration, 1); err == nil {
+		t.Error("Expected an error migrating down without down files")
+	}
+}
+
+func TestCheckDown(t *testing.T) {
+	byVersion := testByVersion(1, 2, 3)
+	down, err := planSteps(byVersion, 3, 1)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := checkDown(down, 3, 1, false); err == nil {
+		t.Error("Expected a DownNotAllowedError")
+	}
+	if err := checkDown(down, 3, 1, true); err != nil {
+		t.Error(err.Error())
+	}
+
+	up, err := planSteps(byVersion, 1, 3)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := checkDown(up, 1, 3, false); err != nil {
+		t.Error(err.Error())
+	}
+}
@@ -0,0 +1,255 @@
+package pgmigrate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const planHeader = "-- pgmigrate plan"
+
+// planExecMarker starts each unit of a plan script which is sent to the
+// server as a separate query
+const planExecMarker = "-- pgmigrate exec:"
+
+// WritePlan writes a single SQL script to w which takes the database from its
+// current version to targetVersion, including the statements which record
+// the new version and history. The script can be reviewed, then run with
+// ApplyPlan.
+func WritePlan(ctx context.Context, conn Queryer, source MigrationSource, targetVersion int, w io.Writer) error {
+	return New(conn, WithSource(source)).WritePlan(ctx, targetVersion, w)
+}
+
+// WritePlan writes a single SQL script to w which takes the database from its
+// current version to targetVersion.
+func (m *Migrator) WritePlan(ctx context.Context, targetVersion int, w io.Writer) error {
+	source := m.cfg.source
+	table := m.cfg.table
+	currentVersion, targetVersion, steps, err := resolvePlan(ctx, m.conn, m.cfg, targetVersion)
+	if err != nil {
+		return err
+	}
+
+	body := &bytes.Buffer{}
+	for _, step := range steps {
+		if step.Func() != nil {
+			return fmt.Errorf("%s is a Go migration, which can't be written as SQL", step.Filename())
+		}
+		rendered, err := renderFile(m.cfg, source, step.Filename())
+		if err != nil {
+			return err
+		}
+		content := rendered.content
+		variables, err := encodeVariables(rendered.variables)
+		if err != nil {
+			return err
+		}
+		fileDirectives, err := parseDirectives(content)
+		if err != nil {
+			return fmt.Errorf("reading directives in %s: %w", step.Filename(), err)
+		}
+
+		description := fmt.Sprintf("%s (%s %d)", step.Filename(), step.Direction, step.Version)
+		if fileDirectives.noTransaction {
+			fmt.Fprintf(body, "\n%s mark dirty before %s\nUPDATE %s SET dirty_version = %d;\n", planExecMarker, description, table.versionTable(), step.Version)
+			for _, statement := range fileDirectives.setStatements(false) {
+				fmt.Fprintf(body, "%s\n", statement)
+			}
+			fmt.Fprintf(body, "\n%s %s without a transaction\n", planExecMarker, description)
+			body.Write(content)
+			fmt.Fprintf(body, "\n\n%s record %s\n", planExecMarker, description)
+			for _, statement := range fileDirectives.resetStatements() {
+				fmt.Fprintf(body, "%s\n", statement)
+			}
+			fmt.Fprintf(body, "BEGIN;\n")
+		} else {
+			fmt.Fprintf(body, "\n%s %s\n%s\n", planExecMarker, description, fileDirectives.beginSQL())
+			for _, statement := range fileDirectives.setStatements(true) {
+				fmt.Fprintf(body, "%s\n", statement)
+			}
+			body.Write(content)
+			body.WriteString("\n;\n")
+		}
+		fmt.Fprintf(body, "UPDATE %s SET version = %d, dirty_version = NULL;\n", table.versionTable(), step.ResultVersion())
+		fmt.Fprintf(body, "INSERT INTO %s (version, name, filename, checksum, direction, applied_at, duration_ms, variables) VALUES (%d, %s, %s, %s, %s, now(), 0, %s);\n",
+			table.historyTable(),
+			step.Version,
+			quoteLiteral(step.Name),
+			quoteLiteral(step.Filename()),
+			quoteLiteral(rendered.checksum),
+			quoteLiteral(step.Direction),
+			quoteLiteral(variables),
+		)
+		fmt.Fprintf(body, "COMMIT;\n")
+	}
+
+	fmt.Fprintf(w, "%s\n-- from-version: %d\n-- to-version: %d\n-- checksum: %s\n",
+		planHeader, currentVersion, targetVersion, checksum(body.Bytes()))
+	_, err = w.Write(body.Bytes())
+	return err
+}
+
+// Plan is the migrations a run to a target version would apply, in order
+type Plan struct {
+	From  int        `json:"from"`
+	To    int        `json:"to"`
+	Steps []PlanStep `json:"steps"`
+}
+
+// PlanStep is one file, or Go function, of a Plan
+type PlanStep struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	Filename  string `json:"filename"`
+	Direction string `json:"direction"`
+
+	// ResultVersion is the version the database is at once the step has run
+	ResultVersion int `json:"resultVersion"`
+}
+
+// Plan lists the migrations which would take the database from its current
+// version to targetVersion, without running them. A targetVersion of -1 is
+// the latest version.
+func (m *Migrator) Plan(ctx context.Context, targetVersion int) (Plan, error) {
+	currentVersion, targetVersion, steps, err := resolvePlan(ctx, m.conn, m.cfg, targetVersion)
+	if err != nil {
+		return Plan{}, err
+	}
+	plan := Plan{From: currentVersion, To: targetVersion, Steps: make([]PlanStep, 0, len(steps))}
+	for _, step := range steps {
+		plan.Steps = append(plan.Steps, PlanStep{
+			Version:       step.Version,
+			Name:          step.Name,
+			Filename:      step.Filename(),
+			Direction:     step.Direction,
+			ResultVersion: step.ResultVersion(),
+		})
+	}
+	return plan, nil
+}
+
+// ApplyPlan runs a script created by WritePlan. It refuses to run if the
+// script has been edited, or if the database is no longer at the version the
+// plan was made from.
+func ApplyPlan(ctx context.Context, conn Queryer, script []byte) error {
+	return New(conn).ApplyPlan(ctx, script)
+}
+
+// ApplyPlan runs a script created by WritePlan, refusing if it has been
+// edited or the database has moved on, and a plan down without
+// WithAllowDown. The run is reported to WithNotifier, without the migrations
+// it applied, which the script doesn't record.
+func (m *Migrator) ApplyPlan(ctx context.Context, script []byte) error {
+	fromVersion, toVersion, body, err := parsePlan(script)
+	if err != nil {
+		return err
+	}
+	if toVersion < fromVersion && !m.cfg.allowDown {
+		return &DownNotAllowedError{From: fromVersion, To: toVersion}
+	}
+
+	cfg, finishReport := m.cfg.startReport()
+	err = m.withSession(ctx, func(conn Queryer) error {
+		table := cfg.table
+		currentVersion, err := table.getVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := table.ensureDirtyColumn(ctx, conn); err != nil {
+			return err
+		}
+		if err := table.ensureHistoryTable(ctx, conn); err != nil {
+			return err
+		}
+		if err := table.checkDirty(ctx, conn); err != nil {
+			return err
+		}
+
+		if currentVersion != fromVersion {
+			return fmt.Errorf("plan is from version %d, but the database is at version %d", fromVersion, currentVersion)
+		}
+		cfg.reportPlan(fromVersion, toVersion)
+
+		for _, unit := range splitPlanUnits(body) {
+			if _, err := conn.ExecContext(ctx, unit); err != nil {
+				// A failure part way leaves the failed step's transaction open
+				conn.ExecContext(ctx, `ROLLBACK`) //nolint: errcheck
+				return fmt.Errorf("applying plan: %w", err)
+			}
+		}
+		cfg.reportVersion(toVersion)
+		return nil
+	})
+	finishReport(ctx, err)
+	return err
+}
+
+// parsePlan checks a script's header, returning the versions it migrates from
+// and to, and its body
+func parsePlan(script []byte) (int, int, []byte, error) {
+	reader := bufio.NewReader(bytes.NewReader(script))
+	headers := map[string]string{}
+
+	first, err := reader.ReadString('\n')
+	if err != nil || strings.TrimSpace(first) != planHeader {
+		return 0, 0, nil, fmt.Errorf("not a pgmigrate plan")
+	}
+
+	headerLength := len(first)
+	for _, key := range []string{"from-version", "to-version", "checksum"} {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("reading plan header: %w", err)
+		}
+		headerLength += len(line)
+		prefix := "-- " + key + ": "
+		if !strings.HasPrefix(line, prefix) {
+			return 0, 0, nil, fmt.Errorf("plan header is missing %s", key)
+		}
+		headers[key] = strings.TrimSpace(strings.TrimPrefix(line, prefix))
+	}
+
+	body := script[headerLength:]
+	if checksum(body) != headers["checksum"] {
+		return 0, 0, nil, fmt.Errorf("plan has been modified since it was created")
+	}
+
+	fromVersion, err := strconv.Atoi(headers["from-version"])
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("invalid from-version in plan header")
+	}
+	toVersion, err := strconv.Atoi(headers["to-version"])
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("invalid to-version in plan header")
+	}
+	return fromVersion, toVersion, body, nil
+}
+
+// splitPlanUnits splits a plan body at each planExecMarker line
+func splitPlanUnits(body []byte) []string {
+	units := []string{}
+	current := &strings.Builder{}
+	flush := func() {
+		if strings.TrimSpace(current.String()) != "" {
+			units = append(units, current.String())
+		}
+		current.Reset()
+	}
+
+	for _, line := range strings.SplitAfter(string(body), "\n") {
+		if strings.HasPrefix(line, planExecMarker) {
+			flush()
+		}
+		current.WriteString(line)
+	}
+	flush()
+	return units
+}
+
+func quoteLiteral(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
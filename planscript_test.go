@@ -0,0 +1,148 @@
+package pgmigrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestPlanScript(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_planscript")
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	if err := MigrateDatabase(ctx, conn, migrateDir, 1); err != nil {
+		t.Fatalf("Unable to migrate: %s", err.Error())
+	}
+
+	script := &bytes.Buffer{}
+	if err := WritePlan(ctx, conn, DirSource(migrateDir), -1, script); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tampered := bytes.Replace(script.Bytes(), []byte("baz"), []byte("qux"), -1)
+	if err := ApplyPlan(ctx, conn, tampered); err == nil {
+		t.Fatal("Expected a modified plan to be rejected")
+	}
+
+	if err := ApplyPlan(ctx, conn, script.Bytes()); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if v, err := getVersion(ctx, conn); err != nil {
+		t.Fatal(err.Error())
+	} else if v != 3 {
+		t.Fatalf("Wrong version %d (expected 3)", v)
+	}
+
+	// The database has moved on, so the same plan can't run again
+	if err := ApplyPlan(ctx, conn, script.Bytes()); err == nil {
+		t.Fatal("Expected a stale plan to be rejected")
+	}
+}
+
+func TestPlanScriptDown(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_planscript_down")
+	defer conn.Close()
+
+	ctx := context.Background()
+	if err := MigrateDatabase(ctx, conn, migrateDir, -1); err != nil {
+		t.Fatalf("Unable to migrate: %s", err.Error())
+	}
+
+	var downErr *DownNotAllowedError
+	migrator := New(conn, WithSource(DirSource(migrateDir)))
+	if err := migrator.WritePlan(ctx, 1, &bytes.Buffer{}); !errors.As(err, &downErr) {
+		t.Fatalf("Expected a plan down to need AllowDown, got %v", err)
+	}
+
+	allowing := New(conn, WithSource(DirSource(migrateDir)), WithAllowDown(true))
+	script := &bytes.Buffer{}
+	if err := allowing.WritePlan(ctx, 1, script); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := migrator.ApplyPlan(ctx, script.Bytes()); !errors.As(err, &downErr) {
+		t.Fatalf("Expected applying a plan down to need AllowDown, got %v", err)
+	}
+	if err := allowing.ApplyPlan(ctx, script.Bytes()); err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, err := getVersion(ctx, conn); err != nil {
+		t.Fatal(err.Error())
+	} else if v != 1 {
+		t.Fatalf("Wrong version %d (expected 1)", v)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_plan")
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	if err := MigrateDatabase(ctx, conn, migrateDir, 1); err != nil {
+		t.Fatalf("Unable to migrate: %s", err.Error())
+	}
+
+	plan, err := New(conn, WithSource(DirSource(migrateDir))).Plan(ctx, -1)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if plan.From != 1 || plan.To != 3 || len(plan.Steps) != 2 {
+		t.Fatalf("Wrong plan %+v", plan)
+	}
+	if step := plan.Steps[0]; step.Version != 2 || step.Direction != directionUp || step.ResultVersion != 2 || step.Filename == "" {
+		t.Errorf("Wrong first step %+v", step)
+	}
+
+	if v, err := getVersion(ctx, conn); err != nil {
+		t.Fatal(err.Error())
+	} else if v != 1 {
+		t.Fatalf("Planning changed the version to %d", v)
+	}
+}
+
+func TestParsePlan(t *testing.T) {
+	body := "\nSELECT 1;\n"
+	script := planHeader + "\n-- from-version: 4\n-- to-version: 5\n-- checksum: " + checksum([]byte(body)) + "\n" + body
+
+	fromVersion, toVersion, parsedBody, err := parsePlan([]byte(script))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if fromVersion != 4 {
+		t.Errorf("Expected from version 4, got %d", fromVersion)
+	}
+	if toVersion != 5 {
+		t.Errorf("Expected to version 5, got %d", toVersion)
+	}
+	if string(parsedBody) != body {
+		t.Errorf("Wrong body %q", string(parsedBody))
+	}
+
+	if _, _, _, err := parsePlan([]byte("SELECT 1;")); err == nil {
+		t.Error("Expected an error for a script without a header")
+	}
+}
+
+func TestSplitPlanUnits(t *testing.T) {
+	units := splitPlanUnits([]byte("\n" + planExecMarker + " one\nSELECT 1;\n\n" + planExecMarker + " two\nSELECT 2;\n"))
+	if len(units) != 2 {
+		t.Fatalf("Expected 2 units, got %d: %q", len(units), units)
+	}
+	if units[1] != planExecMarker+" two\nSELECT 2;\n" {
+		t.Errorf("Wrong second unit %q", units[1])
+	}
+}
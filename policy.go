@@ -0,0 +1,161 @@
+package pgmigrate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// PolicyRule denies migrations whose SQL matches Pattern
+type PolicyRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// DestructivePolicy denies statements which lose data or rewrite columns
+var DestructivePolicy = []PolicyRule{
+	{Name: "drop-table", Pattern: regexp.MustCompile(`(?i)\bDROP\s+TABLE\b`)},
+	{Name: "drop-schema", Pattern: regexp.MustCompile(`(?i)\bDROP\s+SCHEMA\b`)},
+	{Name: "drop-column", Pattern: regexp.MustCompile(`(?i)\bDROP\s+COLUMN\b`)},
+	{Name: "truncate", Pattern: regexp.MustCompile(`(?i)\bTRUNCATE\b`)},
+	{Name: "alter-column-type", Pattern: regexp.MustCompile(`(?i)\bALTER\s+COLUMN\s+\S+\s+(SET\s+DATA\s+)?TYPE\b`)},
+}
+
+// PolicyViolation is a file which matched a denied pattern
+type PolicyViolation struct {
+	Version  int
+	Filename string
+	Rule     string
+}
+
+// PolicyViolationError is returned when migrations about to run match the
+// policy set with WithPolicy. WithPolicyOverride runs them anyway.
+type PolicyViolationError struct {
+	Violations []PolicyViolation
+}
+
+func (err *PolicyViolationError) Error() string {
+	violations := make([]string, len(err.Violations))
+	for idx, violation := range err.Violations {
+		violations[idx] = fmt.Sprintf("%s is denied by %s", violation.Filename, violation.Rule)
+	}
+	return fmt.Sprintf("migrations break the policy: %s", strings.Join(violations, ", "))
+}
+
+// WithPolicy denies running up migrations whose SQL, after includes and
+// variables, matches any of rules, outside of comments. It is checked when
+// the run is planned, before anything is applied, and by plan and dry runs.
+// Down files are left alone, as they are expected to drop things, and
+// guarded by WithAllowDown.
+func WithPolicy(rules ...PolicyRule) Option {
+	return func(cfg *config) {
+		cfg.policy = append(cfg.policy, rules...)
+	}
+}
+
+// WithPolicyOverride sets whether to run migrations the policy denies
+func WithPolicyOverride(override bool) Option {
+	return func(cfg *config) {
+		cfg.policyOverride = override
+	}
+}
+
+// ReadPolicy reads rules, one per line as a name and a regular expression,
+// as in
+//
+//	drop-table (?i)\bDROP\s+TABLE\b
+//
+// Blank lines and lines starting with # are skipped.
+func ReadPolicy(r io.Reader) ([]PolicyRule, error) {
+	rules := []PolicyRule{}
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("policy line %d: expected a name and a pattern", lineNumber)
+		}
+		pattern, err := regexp.Compile(strings.TrimSpace(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("policy line %d: %w", lineNumber, err)
+		}
+		rules = append(rules, PolicyRule{Name: fields[0], Pattern: pattern})
+	}
+	return rules, scanner.Err()
+}
+
+// checkPolicy fails if any up step matches the policy, unless it is
+// overridden
+func checkPolicy(cfg *config, source MigrationSource, steps []step) error {
+	if len(cfg.policy) == 0 || cfg.policyOverride {
+		return nil
+	}
+
+	violations := []PolicyViolation{}
+	for _, step := range steps {
+		if step.Direction != directionUp || step.Func() != nil {
+			continue
+		}
+		rendered, err := renderFile(cfg, source, step.Filename())
+		if err != nil {
+			return err
+		}
+		statements := stripComments(string(rendered.content))
+		for _, rule := range cfg.policy {
+			if rule.Pattern.MatchString(statements) {
+				violations = append(violations, PolicyViolation{
+					Version:  step.Version,
+					Filename: step.Filename(),
+					Rule:     rule.Name,
+				})
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return &PolicyViolationError{Violations: violations}
+	}
+	return nil
+}
+
+// stripComments blanks out -- and /* */ comments, leaving string literals
+// alone
+func stripComments(content string) string {
+	stripped := &strings.Builder{}
+	inString := false
+	for idx := 0; idx < len(content); idx++ {
+		c := content[idx]
+		if inString {
+			// a doubled '' closes and reopens the string
+			inString = c != '\''
+			stripped.WriteByte(c)
+			continue
+		}
+		switch {
+		case c == '\'':
+			inString = true
+			stripped.WriteByte(c)
+		case strings.HasPrefix(content[idx:], "--"):
+			end := strings.IndexByte(content[idx:], '\n')
+			if end == -1 {
+				return stripped.String()
+			}
+			// the newline is kept
+			idx += end - 1
+		case strings.HasPrefix(content[idx:], "/*"):
+			end := strings.Index(content[idx+2:], "*/")
+			if end == -1 {
+				return stripped.String()
+			}
+			stripped.WriteByte(' ')
+			idx += end + 3
+		default:
+			stripped.WriteByte(c)
+		}
+	}
+	return stripped.String()
+}
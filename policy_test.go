@@ -0,0 +1,70 @@
+package pgmigrate
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   "-- DROP TABLE in a comment is fine\nCREATE TABLE foo (id int); /* TRUNCATE */",
+		"001-foo.down.sql": "DROP TABLE foo;",
+		"002-bar.up.sql":   "ALTER TABLE foo ALTER COLUMN id TYPE bigint;\nTRUNCATE foo;",
+		"002-bar.down.sql": "SELECT 1;",
+	})
+	defer os.RemoveAll(migrateDir)
+
+	cfg := newConfig([]Option{WithSource(DirSource(migrateDir)), WithPolicy(DestructivePolicy...)})
+	byVersion, _, err := loadMigrations(cfg)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	down, err := planSteps(byVersion, 1, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := checkPolicy(cfg, cfg.source, down); err != nil {
+		t.Errorf("Expected down files to be allowed, got %s", err.Error())
+	}
+
+	up, err := planSteps(byVersion, 0, 2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = checkPolicy(cfg, cfg.source, up)
+	violationErr := &PolicyViolationError{}
+	if !errors.As(err, &violationErr) {
+		t.Fatalf("Expected a PolicyViolationError, got %v", err)
+	}
+	if len(violationErr.Violations) != 2 || violationErr.Violations[0].Rule != "truncate" || violationErr.Violations[1].Rule != "alter-column-type" || violationErr.Violations[0].Version != 2 {
+		t.Errorf("Unexpected violations %+v", violationErr.Violations)
+	}
+
+	cfg.policyOverride = true
+	if err := checkPolicy(cfg, cfg.source, up); err != nil {
+		t.Errorf("Expected the override to allow the migrations, got %s", err.Error())
+	}
+}
+
+func TestReadPolicy(t *testing.T) {
+	rules, err := ReadPolicy(strings.NewReader("# no grants\n\nno-grant (?i)\\bGRANT\\b\n"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(rules) != 1 || rules[0].Name != "no-grant" || !rules[0].Pattern.MatchString("grant select on foo to bar") {
+		t.Errorf("Unexpected rules %+v", rules)
+	}
+	if _, err := ReadPolicy(strings.NewReader("bad-pattern (\n")); err == nil {
+		t.Error("Expected an error for a bad pattern")
+	}
+}
+
+func TestStripComments(t *testing.T) {
+	stripped := stripComments("SELECT '-- not a comment', 'it''s' -- a comment\n/* block\n*/SELECT 2;")
+	if stripped != "SELECT '-- not a comment', 'it''s' \n SELECT 2;" {
+		t.Errorf("Unexpected result %q", stripped)
+	}
+}
@@ -0,0 +1,46 @@
+package prommetrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectors(t *testing.T) {
+	collectors := New()
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collectors); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	collectors.SchemaVersion("_migrate_", 1)
+	collectors.MigrationApplied(2, "up", 2*time.Second)
+	collectors.SchemaVersion("_migrate_", 2)
+	collectors.MigrationFailed(3, "up", time.Second)
+
+	expect := `
+# HELP pgmigrate_failures_total Migration files which failed
+# TYPE pgmigrate_failures_total counter
+pgmigrate_failures_total{direction="up",version="3"} 1
+# HELP pgmigrate_migrations_applied_total Migration files applied successfully
+# TYPE pgmigrate_migrations_applied_total counter
+pgmigrate_migrations_applied_total{direction="up"} 1
+# HELP pgmigrate_schema_version The version recorded in the tracking table
+# TYPE pgmigrate_schema_version gauge
+pgmigrate_schema_version{table="_migrate_"} 2
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expect),
+		"pgmigrate_failures_total",
+		"pgmigrate_migrations_applied_total",
+		"pgmigrate_schema_version",
+	); err != nil {
+		t.Error(err.Error())
+	}
+
+	if count := testutil.CollectAndCount(collectors, "pgmigrate_migration_duration_seconds"); count != 1 {
+		t.Errorf("Expected one duration histogram, got %d", count)
+	}
+}
@@ -0,0 +1,77 @@
+// Package rdsiam authenticates to Amazon RDS and Aurora with IAM, generating
+// a token for each new connection in place of a static password. Tokens last
+// 15 minutes, so a pool which reconnects later gets a fresh one.
+package rdsiam
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/rds/auth"
+	"gopkg.daemonl.com/pgmigrate/pgauth"
+)
+
+// Provider is a pgauth.Provider generating RDS IAM authentication tokens
+type Provider struct {
+	// AWSCredentials sign the tokens, and need rds-db:connect for the user
+	AWSCredentials aws.CredentialsProvider
+
+	// Region is the database's region. If empty, it is read from an RDS
+	// host name, such as db.abc123.eu-west-1.rds.amazonaws.com.
+	Region string
+}
+
+// New creates a Provider from the default AWS configuration, as the AWS CLI
+// would find it, from the environment, shared files or an instance role
+func New(ctx context.Context) (*Provider, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{AWSCredentials: cfg.Credentials, Region: cfg.Region}, nil
+}
+
+// Credentials generates a token for the connection's user
+func (p *Provider) Credentials(ctx context.Context, params pgauth.Params) (pgauth.Credentials, error) {
+	region := p.Region
+	if region == "" {
+		region = hostRegion(params.Host)
+	}
+	if region == "" {
+		return pgauth.Credentials{}, fmt.Errorf("no AWS region for %s, set AWS_REGION", params.Host)
+	}
+	token, err := auth.BuildAuthToken(ctx, net.JoinHostPort(params.Host, params.Port), region, params.User, p.AWSCredentials)
+	if err != nil {
+		return pgauth.Credentials{}, err
+	}
+	return pgauth.Credentials{Password: token}, nil
+}
+
+// hostRegion is the region in an RDS endpoint's host name, or empty
+func hostRegion(host string) string {
+	idx := strings.Index(host, ".rds.amazonaws.com")
+	if idx == -1 {
+		return ""
+	}
+	labels := strings.Split(host[:idx], ".")
+	return labels[len(labels)-1]
+}
+
+// Open opens a pool connecting to dsn, which shouldn't have a password, as
+// its user with IAM credentials from the default AWS configuration
+func Open(ctx context.Context, dsn string) (*sql.DB, error) {
+	provider, err := New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := pgauth.NewConnector(dsn, provider)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(connector), nil
+}
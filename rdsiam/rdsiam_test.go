@@ -0,0 +1,52 @@
+package rdsiam
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"gopkg.daemonl.com/pgmigrate/pgauth"
+)
+
+func TestCredentials(t *testing.T) {
+	provider := &Provider{AWSCredentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")}
+	params := pgauth.Params{Host: "db.abc123.eu-west-1.rds.amazonaws.com", Port: "5432", User: "migrator", Database: "app"}
+
+	creds, err := provider.Credentials(context.Background(), params)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if creds.User != "" {
+		t.Errorf("Expected the user to be left alone, got %q", creds.User)
+	}
+	if !strings.HasPrefix(creds.Password, "db.abc123.eu-west-1.rds.amazonaws.com:5432?") {
+		t.Fatalf("Wrong token %q", creds.Password)
+	}
+	query, err := url.ParseQuery(creds.Password[strings.Index(creds.Password, "?")+1:])
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if query.Get("DBUser") != "migrator" || !strings.Contains(query.Get("X-Amz-Credential"), "/eu-west-1/rds-db/") {
+		t.Errorf("Wrong token query %v", query)
+	}
+
+	if _, err := provider.Credentials(context.Background(), pgauth.Params{Host: "localhost", Port: "5432", User: "migrator"}); err == nil {
+		t.Error("Expected an error without a region")
+	}
+}
+
+func TestHostRegion(t *testing.T) {
+	for host, expect := range map[string]string{
+		"db.abc123.eu-west-1.rds.amazonaws.com":                   "eu-west-1",
+		"cluster.cluster-abc123.us-east-2.rds.amazonaws.com":      "us-east-2",
+		"db.abc123.cn-north-1.rds.amazonaws.com.cn":               "cn-north-1",
+		"proxy-abc.proxy-abc123.ap-southeast-2.rds.amazonaws.com": "ap-southeast-2",
+		"localhost": "",
+	} {
+		if got := hostRegion(host); got != expect {
+			t.Errorf("%s: expected %q, got %q", host, expect, got)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Registry collects Go migrations, usually from the init functions of the
+// files which define them, so they can be checked against the SQL files
+// before anything runs.
+type Registry struct {
+	byVersion map[int]Migration
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{byVersion: map[int]Migration{}}
+}
+
+// Register adds a Go migration. It fails if the migration has no function,
+// refers to files, or supplies a direction already registered for its
+// version.
+func (r *Registry) Register(migration Migration) error {
+	if migration.Version < 1 {
+		return fmt.Errorf("Go migration %s has invalid version %d", migration.Name, migration.Version)
+	}
+	if migration.UpFile != "" || migration.DownFile != "" {
+		return fmt.Errorf("Go migration %d can't refer to files", migration.Version)
+	}
+	if migration.UpFunc == nil && migration.DownFunc == nil {
+		return fmt.Errorf("Go migration %d has no functions", migration.Version)
+	}
+
+	existing, ok := r.byVersion[migration.Version]
+	if !ok {
+		r.byVersion[migration.Version] = migration
+		return nil
+	}
+	if migration.UpFunc != nil {
+		if existing.UpFunc != nil {
+			return fmt.Errorf("Go migration %d has more than one up function", migration.Version)
+		}
+		existing.UpFunc = migration.UpFunc
+	}
+	if migration.DownFunc != nil {
+		if existing.DownFunc != nil {
+			return fmt.Errorf("Go migration %d has more than one down function", migration.Version)
+		}
+		existing.DownFunc = migration.DownFunc
+	}
+	if existing.Name == "" {
+		existing.Name = migration.Name
+	}
+	r.byVersion[migration.Version] = existing
+	return nil
+}
+
+// MustRegister is Register, panicking on error, for use in init functions
+func (r *Registry) MustRegister(migration Migration) {
+	if err := r.Register(migration); err != nil {
+		panic(err.Error())
+	}
+}
+
+// Migrations lists the registered migrations in version order
+func (r *Registry) Migrations() []Migration {
+	migrations := make([]Migration, 0, len(r.byVersion))
+	for _, migration := range r.byVersion {
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations
+}
+
+// Merge combines the registered migrations with those listed by source into
+// one source, listing it immediately so that duplicate or conflicting
+// versions are reported at startup. Migrator.Validate then checks the
+// combined sequence for gaps.
+func (r *Registry) Merge(source MigrationSource) (MigrationSource, error) {
+	merged := goSource{MigrationSource: source, migrations: r.Migrations()}
+	if _, err := merged.List(); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
@@ -0,0 +1,63 @@
+package pgmigrate
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.MustRegister(Migration{Version: 3, Name: "backfill", UpFunc: noopMigration})
+	registry.MustRegister(Migration{Version: 3, DownFunc: noopMigration})
+	registry.MustRegister(Migration{Version: 2, DownFunc: noopMigration})
+
+	for _, bad := range []Migration{
+		{Version: 0, UpFunc: noopMigration},
+		{Version: 4},
+		{Version: 4, UpFile: "004-x.up.sql", UpFunc: noopMigration},
+		{Version: 3, UpFunc: noopMigration},
+	} {
+		if err := registry.Register(bad); err == nil {
+			t.Errorf("Expected an error registering %+v", bad)
+		}
+	}
+
+	migrations := registry.Migrations()
+	if len(migrations) != 2 || migrations[0].Version != 2 || migrations[1].Version != 3 {
+		t.Fatalf("Unexpected migrations %+v", migrations)
+	}
+	if migrations[1].Name != "backfill" || migrations[1].UpFunc == nil || migrations[1].DownFunc == nil {
+		t.Errorf("Expected both directions of 3 to be combined, got %+v", migrations[1])
+	}
+
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"002-bar.up.sql":   s2u,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	source, err := registry.Merge(DirSource(migrateDir))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := New(nil, WithSource(source)).Validate(); err != nil {
+		t.Error(err.Error())
+	}
+
+	conflicting := NewRegistry()
+	conflicting.MustRegister(Migration{Version: 2, UpFunc: noopMigration, DownFunc: noopMigration})
+	if _, err := conflicting.Merge(DirSource(migrateDir)); err == nil {
+		t.Error("Expected an error merging a Go migration over a file")
+	}
+
+	gap := NewRegistry()
+	gap.MustRegister(Migration{Version: 5, UpFunc: noopMigration, DownFunc: noopMigration})
+	source, err = gap.Merge(DirSource(migrateDir))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := New(nil, WithSource(source)).Validate(); err == nil {
+		t.Error("Expected an error for the gap before 5")
+	}
+}
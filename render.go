@@ -0,0 +1,115 @@
+package pgmigrate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// WithVariables substitutes ${NAME} in migration files with vars[NAME], for
+// names such as tablespaces or roles which differ between environments. Once
+// any variable is set, a ${NAME} which isn't one of them fails the run rather
+// than running with a hole in it. The variables each file used are recorded
+// in its history entry. Checksums are taken before substitution, so the same
+// files verify in every environment.
+func WithVariables(vars map[string]string) Option {
+	return func(cfg *config) {
+		if cfg.variables == nil {
+			cfg.variables = map[string]string{}
+		}
+		for name, value := range vars {
+			cfg.variables[name] = value
+		}
+	}
+}
+
+// WithEnvVariables is WithVariables with the values of the named environment
+// variables. Only the names listed are available to the files.
+func WithEnvVariables(names ...string) Option {
+	vars := map[string]string{}
+	for _, name := range names {
+		vars[name] = os.Getenv(name)
+	}
+	return WithVariables(vars)
+}
+
+// WithTemplateData renders migration files named like 001-foo.up.sql.tmpl
+// with text/template before they run, with data as the template's dot and
+// funcs available alongside the standard functions, such as to generate a
+// partition per month. As with variables, checksums are of the template,
+// not its output.
+func WithTemplateData(data interface{}, funcs template.FuncMap) Option {
+	return func(cfg *config) {
+		cfg.templateData = data
+		cfg.templateFuncs = funcs
+	}
+}
+
+// isTemplate reports whether filename, or the goose file holding its
+// section, is rendered with text/template
+func isTemplate(filename string) bool {
+	filename, _ = gooseSectionFile(filename)
+	return strings.HasSuffix(strings.TrimSuffix(filename, ".gz"), ".tmpl")
+}
+
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// renderedFile is a migration file ready to run
+type renderedFile struct {
+	content []byte
+
+	// checksum is of the file with its includes, before it was rendered or
+	// had variables substituted
+	checksum string
+
+	// variables are those substituted into the file
+	variables map[string]string
+}
+
+// renderFile reads filename from source, expands its includes, renders it if
+// it is a template, then substitutes any variables
+func renderFile(cfg *config, source MigrationSource, filename string) (renderedFile, error) {
+	content, err := readExpanded(source, filename)
+	if err != nil {
+		return renderedFile{}, err
+	}
+	return renderContent(cfg, filename, content)
+}
+
+func renderContent(cfg *config, filename string, content []byte) (renderedFile, error) {
+	rendered := renderedFile{content: content, checksum: checksum(content)}
+	if isTemplate(filename) {
+		tmpl, err := template.New(filename).Funcs(cfg.templateFuncs).Parse(string(content))
+		if err != nil {
+			return renderedFile{}, fmt.Errorf("parsing template %s: %w", filename, err)
+		}
+		output := &bytes.Buffer{}
+		if err := tmpl.Execute(output, cfg.templateData); err != nil {
+			return renderedFile{}, fmt.Errorf("rendering template %s: %w", filename, err)
+		}
+		rendered.content = output.Bytes()
+	}
+	if len(cfg.variables) == 0 {
+		return rendered, nil
+	}
+
+	rendered.variables = map[string]string{}
+	var missing []string
+	rendered.content = variablePattern.ReplaceAllFunc(rendered.content, func(match []byte) []byte {
+		name := string(match[2 : len(match)-1])
+		value, ok := cfg.variables[name]
+		if !ok {
+			missing = append(missing, name)
+			return match
+		}
+		rendered.variables[name] = value
+		return []byte(value)
+	})
+	if len(missing) > 0 {
+		return renderedFile{}, fmt.Errorf("%s uses undefined variables %s", filename, strings.Join(uniqueStrings(missing), ", "))
+	}
+	return rendered, nil
+}
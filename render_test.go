@@ -0,0 +1,128 @@
+package pgmigrate
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestRenderContent(t *testing.T) {
+	content := []byte(`CREATE TABLE foo (id int) TABLESPACE ${TABLESPACE}; GRANT SELECT ON foo TO ${READER};`)
+
+	// Without variables, files are left alone
+	plain, err := renderContent(newConfig(nil), "001-foo.up.sql", content)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(plain.content) != string(content) || plain.variables != nil {
+		t.Errorf("Expected the file untouched, got %q", plain.content)
+	}
+
+	cfg := newConfig([]Option{WithVariables(map[string]string{"TABLESPACE": "fast", "READER": "analyst", "UNUSED": "x"})})
+	rendered, err := renderContent(cfg, "001-foo.up.sql", content)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if expect := `CREATE TABLE foo (id int) TABLESPACE fast; GRANT SELECT ON foo TO analyst;`; string(rendered.content) != expect {
+		t.Errorf("Expected %q, got %q", expect, rendered.content)
+	}
+	if len(rendered.variables) != 2 || rendered.variables["READER"] != "analyst" {
+		t.Errorf("Expected only the used variables, got %v", rendered.variables)
+	}
+	if rendered.checksum != plain.checksum {
+		t.Errorf("Expected the checksum to be taken before substitution")
+	}
+
+	cfg = newConfig([]Option{WithVariables(map[string]string{"TABLESPACE": "fast"})})
+	if _, err := renderContent(cfg, "001-foo.up.sql", content); err == nil || !strings.Contains(err.Error(), "READER") {
+		t.Errorf("Expected an error naming READER, got %v", err)
+	}
+
+	os.Setenv("PGMIGRATE_TEST_READER", "from_env")
+	defer os.Unsetenv("PGMIGRATE_TEST_READER")
+	cfg = newConfig([]Option{WithEnvVariables("PGMIGRATE_TEST_READER")})
+	fromEnv, err := renderContent(cfg, "f.sql", []byte(`${PGMIGRATE_TEST_READER}`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(fromEnv.content) != "from_env" {
+		t.Errorf("Expected the environment value, got %q", fromEnv.content)
+	}
+}
+
+func TestVariablesHistory(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   `CREATE TABLE ${TABLE} (id int);`,
+		"001-foo.down.sql": `DROP TABLE ${TABLE};`,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_variables")
+	defer conn.Close()
+
+	ctx := context.Background()
+	migrator := New(conn, WithSource(DirSource(migrateDir)), WithVariables(map[string]string{"TABLE": "renamed"}))
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := conn.ExecContext(ctx, `SELECT * FROM renamed`); err != nil {
+		t.Fatalf("Expected the substituted table: %s", err.Error())
+	}
+
+	entries, err := migrator.History(ctx)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 1 || entries[0].Variables["TABLE"] != "renamed" {
+		t.Fatalf("Expected the variables in the history, got %+v", entries)
+	}
+
+	// A different environment's values still verify against the checksum
+	other := New(conn, WithSource(DirSource(migrateDir)), WithVariables(map[string]string{"TABLE": "other"}))
+	if err := other.Up(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	migrations, err := ParseFilenames([]string{"001-partitions.up.sql.tmpl", "001-partitions.down.sql"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 1 || migrations[0].UpFile != "001-partitions.up.sql.tmpl" || migrations[0].Name != "partitions" {
+		t.Fatalf("Expected the template to be the up file, got %+v", migrations)
+	}
+
+	data := struct{ Months []string }{Months: []string{"2024_01", "2024_02"}}
+	cfg := newConfig([]Option{
+		WithTemplateData(data, template.FuncMap{"upper": strings.ToUpper}),
+		WithVariables(map[string]string{"OWNER": "app"}),
+	})
+	content := []byte(`{{range .Months}}CREATE TABLE events_{{.}} PARTITION OF events; {{end}}-- {{upper "${owner}"}}`)
+	rendered, err := renderContent(cfg, "001-partitions.up.sql.tmpl", content)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// Variables are substituted into the template's output
+	if expect := `CREATE TABLE events_2024_01 PARTITION OF events; CREATE TABLE events_2024_02 PARTITION OF events; -- app`; string(rendered.content) != expect {
+		t.Errorf("Unexpected render %q", rendered.content)
+	}
+	if rendered.checksum != checksum(content) {
+		t.Errorf("Expected the checksum of the template itself")
+	}
+
+	// Files without .tmpl are never treated as templates
+	plain, err := renderContent(cfg, "002-plain.up.sql", []byte(`SELECT '{{not a template}}';`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(plain.content) != `SELECT '{{not a template}}';` {
+		t.Errorf("Expected the plain file untouched, got %q", plain.content)
+	}
+
+	if _, err := renderContent(cfg, "003-bad.up.sql.tmpl", []byte(`{{.Missing}}`)); err == nil {
+		t.Error("Expected an error rendering a missing field")
+	}
+}
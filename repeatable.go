@@ -0,0 +1,198 @@
+package pgmigrate
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// directionRepeatable records a run of a repeatable migration, which has no
+// version
+const directionRepeatable = "repeatable"
+
+// Repeatable is a migration without a version, such as the definition of a
+// view or function, which is applied again whenever its content changes.
+// Repeatables run after the versioned migrations, in name order, whenever a
+// run reaches the latest version.
+type Repeatable struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+}
+
+// RepeatableSource is implemented by sources which can hold repeatable
+// migrations, named like R-description.sql. DirSource, RecursiveDirSource
+// and ArchiveSource all do.
+type RepeatableSource interface {
+	ListRepeatable() ([]Repeatable, error)
+}
+
+// ParseRepeatableFilenames picks the repeatable migrations, named like
+// R-views.sql, R-views.sql.tmpl, R-views.sql.gz or Flyway's R__views.sql, out
+// of names, in name order. Like ParseFilenames, slash separated paths are
+// matched on their last element.
+func ParseRepeatableFilenames(names []string) []Repeatable {
+	repeatables := []Repeatable{}
+	for _, name := range names {
+		base := strings.TrimSuffix(strings.TrimSuffix(path.Base(name), ".gz"), ".tmpl")
+		if !strings.HasSuffix(base, ".sql") || strings.Count(base, ".") != 1 {
+			continue
+		}
+		// Flyway names repeatables R__description.sql
+		var repeatableName string
+		switch {
+		case strings.HasPrefix(base, "R-"):
+			repeatableName = strings.TrimPrefix(base, "R-")
+		case strings.HasPrefix(base, "R__"):
+			repeatableName = strings.TrimPrefix(base, "R__")
+		default:
+			continue
+		}
+		repeatables = append(repeatables, Repeatable{
+			Name: strings.TrimSuffix(repeatableName, ".sql"),
+			File: name,
+		})
+	}
+	sort.Slice(repeatables, func(i, j int) bool {
+		if repeatables[i].Name != repeatables[j].Name {
+			return repeatables[i].Name < repeatables[j].Name
+		}
+		return repeatables[i].File < repeatables[j].File
+	})
+	return repeatables
+}
+
+// listRepeatables lists the repeatables in source, if it can hold any
+func listRepeatables(source MigrationSource) ([]Repeatable, error) {
+	if wrapped, ok := source.(goSource); ok {
+		source = wrapped.MigrationSource
+	}
+	repeatableSource, ok := source.(RepeatableSource)
+	if !ok {
+		return nil, nil
+	}
+	return repeatableSource.ListRepeatable()
+}
+
+// pendingRepeatable is a repeatable whose content has changed since it last
+// ran, or which has never run
+type pendingRepeatable struct {
+	Repeatable
+	content []byte
+	sum     string
+}
+
+// pendingRepeatables reads every repeatable, returning those whose checksum
+// doesn't match the last run recorded in the history
+func (t trackingTable) pendingRepeatables(ctx context.Context, conn Queryer, source MigrationSource) ([]pendingRepeatable, error) {
+	repeatables, err := listRepeatables(source)
+	if err != nil || len(repeatables) == 0 {
+		return nil, err
+	}
+
+	applied := map[string]string{}
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf(`
+		SELECT DISTINCT ON (filename) filename, checksum
+		FROM %s
+		WHERE direction = $1
+		ORDER BY filename, id DESC
+	`, t.historyTable()), directionRepeatable)
+	if err != nil && sqlState(err) != sqlStateUndefinedTable {
+		return nil, err
+	}
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var filename, sum string
+			if err := rows.Scan(&filename, &sum); err != nil {
+				return nil, err
+			}
+			applied[filename] = sum
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	pending := []pendingRepeatable{}
+	for _, repeatable := range repeatables {
+		content, err := readExpanded(source, repeatable.File)
+		if err != nil {
+			return nil, err
+		}
+		sum := checksum(content)
+		if applied[repeatable.File] == sum {
+			continue
+		}
+		pending = append(pending, pendingRepeatable{Repeatable: repeatable, content: content, sum: sum})
+	}
+	return pending, nil
+}
+
+// applyRepeatables runs each pending repeatable in its own transaction,
+// recording it in the history
+func applyRepeatables(ctx context.Context, conn Queryer, cfg *config) error {
+	table := cfg.table
+	pending, err := table.pendingRepeatables(ctx, conn, cfg.source)
+	if err != nil {
+		return err
+	}
+
+	for _, repeatable := range pending {
+		fields := []interface{}{"filename", repeatable.File, "direction", directionRepeatable}
+		cfg.logger.Info("migration_start", fields...)
+		started := time.Now()
+		rendered, err := renderContent(cfg, repeatable.File, repeatable.content)
+		if err == nil {
+			err = table.applyRepeatable(ctx, conn, repeatable.Repeatable, rendered, started)
+		}
+		fields = append(fields, "duration", time.Since(started))
+		if err != nil {
+			fields = append(fields, "error", err.Error())
+			cfg.logger.Error("migration_failed", append(fields, pgErrorFields(err)...)...)
+			return err
+		}
+		cfg.logger.Info("migration_done", fields...)
+	}
+	return nil
+}
+
+func (t trackingTable) applyRepeatable(ctx context.Context, conn Queryer, repeatable Repeatable, rendered renderedFile, started time.Time) error {
+	fileDirectives, err := parseDirectives(rendered.content)
+	if err != nil {
+		return fmt.Errorf("reading directives in %s: %w", repeatable.File, err)
+	}
+	if fileDirectives.noTransaction {
+		return fmt.Errorf("%s: repeatable migrations always run in a transaction", repeatable.File)
+	}
+
+	tx, err := conn.BeginTx(ctx, fileDirectives.txOptions())
+	if err != nil {
+		return err
+	}
+	for _, statement := range fileDirectives.setStatements(true) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			tx.Rollback() //nolint: errcheck
+			return fmt.Errorf("applying directives in %s: %w", repeatable.File, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, string(rendered.content)); err != nil {
+		tx.Rollback() //nolint: errcheck
+		return fmt.Errorf("executing %s: %w", repeatable.File, err)
+	}
+	if err := t.recordHistory(ctx, tx, HistoryEntry{
+		Name:      repeatable.Name,
+		Filename:  repeatable.File,
+		Checksum:  rendered.checksum,
+		Direction: directionRepeatable,
+		AppliedAt: started,
+		Duration:  time.Since(started),
+		Variables: rendered.variables,
+	}); err != nil {
+		tx.Rollback() //nolint: errcheck
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,111 @@
+package pgmigrate
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRepeatableFilenames(t *testing.T) {
+	repeatables := ParseRepeatableFilenames([]string{
+		"001-foo.up.sql",
+		"R-views.sql",
+		"security/R-grants.sql.gz",
+		"R-notes.txt",
+		"R-odd.up.sql",
+	})
+	if len(repeatables) != 2 {
+		t.Fatalf("Expected 2 repeatables, got %+v", repeatables)
+	}
+	if repeatables[0].Name != "grants" || repeatables[0].File != "security/R-grants.sql.gz" {
+		t.Errorf("Unexpected first repeatable %+v", repeatables[0])
+	}
+	if repeatables[1].Name != "views" || repeatables[1].File != "R-views.sql" {
+		t.Errorf("Unexpected second repeatable %+v", repeatables[1])
+	}
+}
+
+func TestDirSourceRepeatable(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"R-foo_view.sql":   `CREATE OR REPLACE VIEW foo_view AS SELECT id FROM foo;`,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	migrations, err := DirSource(migrateDir).List()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 1 {
+		t.Fatalf("Expected the repeatable not to be a versioned migration, got %+v", migrations)
+	}
+
+	repeatables, err := listRepeatables(newConfig([]Option{WithSource(DirSource(migrateDir)), WithGoMigrations(Migration{Version: 2, Name: "noop", UpFunc: noopMigration})}).source)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(repeatables) != 1 || repeatables[0].Name != "foo_view" {
+		t.Fatalf("Expected the view, got %+v", repeatables)
+	}
+}
+
+func TestRepeatable(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"R-foo_view.sql":   `CREATE OR REPLACE VIEW foo_view AS SELECT id FROM foo;`,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_repeatable")
+	defer conn.Close()
+
+	ctx := context.Background()
+	migrator := New(conn, WithSource(DirSource(migrateDir)))
+
+	countRuns := func() int {
+		t.Helper()
+		entries, err := migrator.History(ctx)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		runs := 0
+		for _, entry := range entries {
+			if entry.Direction == directionRepeatable {
+				runs++
+			}
+		}
+		return runs
+	}
+
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := conn.ExecContext(ctx, `SELECT id FROM foo_view`); err != nil {
+		t.Fatalf("Expected the view to exist: %s", err.Error())
+	}
+
+	// Unchanged, it doesn't run again
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	if runs := countRuns(); runs != 1 {
+		t.Fatalf("Expected 1 run, got %d", runs)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(migrateDir, "R-foo_view.sql"), []byte(`CREATE OR REPLACE VIEW foo_view AS SELECT id, id * 2 AS double FROM foo;`), 0660); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	if runs := countRuns(); runs != 2 {
+		t.Fatalf("Expected the changed view to run again, got %d runs", runs)
+	}
+	if _, err := conn.ExecContext(ctx, `SELECT double FROM foo_view`); err != nil {
+		t.Fatalf("Expected the new view: %s", err.Error())
+	}
+}
@@ -0,0 +1,85 @@
+package pgmigrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RunFailure records the last run which failed, so it can be picked up again
+// with Resume once the cause has been fixed
+type RunFailure struct {
+	// Version is the version the run stopped at, the last which succeeded
+	Version int
+	// Target is the version the run was migrating to
+	Target   int
+	Filename string
+	Error    string
+	FailedAt time.Time
+}
+
+func (t trackingTable) ensureFailureColumns(ctx context.Context, conn Queryer) error {
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`
+		ALTER TABLE %s
+			ADD COLUMN IF NOT EXISTS failed_target bigint,
+			ADD COLUMN IF NOT EXISTS failed_file text,
+			ADD COLUMN IF NOT EXISTS failed_error text,
+			ADD COLUMN IF NOT EXISTS failed_at timestamptz`, t.versionTable()))
+	return err
+}
+
+// recordFailure notes the step which failed a run to targetVersion. It runs
+// once the step has been rolled back, when ctx may already be cancelled.
+func (t trackingTable) recordFailure(conn Queryer, targetVersion int, step step, err error) {
+	conn.ExecContext(context.Background(), fmt.Sprintf( //nolint: errcheck
+		`UPDATE %s SET failed_target = $1, failed_file = $2, failed_error = $3, failed_at = now()`, t.versionTable()),
+		targetVersion, step.Filename(), err.Error())
+}
+
+func (t trackingTable) clearFailure(ctx context.Context, conn Queryer) error {
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET failed_target = NULL, failed_file = NULL, failed_error = NULL, failed_at = NULL`, t.versionTable()))
+	return err
+}
+
+// LastFailure returns the failure of the last run, or nil if it succeeded or
+// nothing has run
+func (m *Migrator) LastFailure(ctx context.Context) (*RunFailure, error) {
+	failure := &RunFailure{}
+	target := sql.NullInt64{}
+	filename := sql.NullString{}
+	message := sql.NullString{}
+	failedAt := sql.NullTime{}
+	err := m.conn.QueryRowContext(ctx, fmt.Sprintf(`SELECT version, failed_target, failed_file, failed_error, failed_at FROM %s`, m.cfg.table.versionTable())).
+		Scan(&failure.Version, &target, &filename, &message, &failedAt)
+	if err != nil {
+		if state := sqlState(err); state == sqlStateUndefinedTable || state == sqlStateUndefinedColumn {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !target.Valid {
+		return nil, nil
+	}
+	failure.Target = int(target.Int64)
+	failure.Filename = filename.String
+	failure.Error = message.String
+	failure.FailedAt = failedAt.Time
+	return failure, nil
+}
+
+// Resume continues the last run, which failed, to the version it was
+// migrating to, logging resume with the from and to versions and the
+// filename and error of the failure. A run which left the database dirty
+// must be repaired and marked with Force first.
+func (m *Migrator) Resume(ctx context.Context) error {
+	failure, err := m.LastFailure(ctx)
+	if err != nil {
+		return err
+	}
+	if failure == nil {
+		return fmt.Errorf("nothing to resume, the last run didn't fail")
+	}
+	m.cfg.logger.Info("resume", "from", failure.Version, "to", failure.Target, "filename", failure.Filename, "error", failure.Error)
+	return m.To(ctx, failure.Target)
+}
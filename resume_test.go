@@ -0,0 +1,58 @@
+package pgmigrate
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResume(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"002-bar.up.sql":   "CREATE TABLE bar (id int REFERENCES missing (id));",
+		"002-bar.down.sql": s2d,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_resume")
+	defer conn.Close()
+
+	ctx := context.Background()
+	migrator := New(conn, WithSource(DirSource(migrateDir)))
+	if failure, err := migrator.LastFailure(ctx); err != nil || failure != nil {
+		t.Fatalf("Expected no failure before anything ran, got %v, %v", failure, err)
+	}
+	if err := migrator.Resume(ctx); err == nil {
+		t.Error("Expected nothing to resume")
+	}
+
+	if err := migrator.Up(ctx); err == nil {
+		t.Fatal("Expected 002 to fail")
+	}
+	failure, err := migrator.LastFailure(ctx)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if failure == nil || failure.Version != 1 || failure.Target != 2 || failure.Filename != "002-bar.up.sql" || !strings.Contains(failure.Error, "missing") {
+		t.Fatalf("Unexpected failure %+v", failure)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(migrateDir, "002-bar.up.sql"), []byte(s2u), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := migrator.Resume(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, err := migrator.ReadVersion(ctx); err != nil {
+		t.Fatal(err.Error())
+	} else if v != 2 {
+		t.Errorf("Expected version 2, got %d", v)
+	}
+	if failure, err := migrator.LastFailure(ctx); err != nil || failure != nil {
+		t.Errorf("Expected the failure to be cleared, got %v, %v", failure, err)
+	}
+}
@@ -0,0 +1,90 @@
+package pgmigrate
+
+import (
+	"context"
+	"sort"
+)
+
+// TestingT is the part of *testing.T used by the test helpers, so the
+// package doesn't import testing
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// VerifyReversible applies every migration in dir one at a time, then reverts
+// them all one at a time, then applies them again, failing t unless each down
+// leaves the schema as it was before its up, and the second run up ends with
+// the same schema as the first. Schemas are compared by their tables, views,
+// sequences, columns, constraints, indexes, triggers, types and functions,
+// not by data. Run it against an empty test schema, such as from
+// GetTestSchema.
+func VerifyReversible(t TestingT, db Queryer, dir string, opts ...Option) {
+	t.Helper()
+	ctx := context.Background()
+	migrator := New(db, append(opts, WithSource(DirSource(dir)), WithAllowDown(true))...)
+
+	byVersion, _, err := loadMigrations(migrator.cfg)
+	if err != nil {
+		t.Fatalf("loading migrations: %s", err)
+		return
+	}
+	versions := []int{0}
+	for version := range byVersion {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	snapshots := make([]string, len(versions))
+	for idx, version := range versions {
+		if err := migrator.To(ctx, version); err != nil {
+			t.Fatalf("migrating up to %d: %s", version, err)
+			return
+		}
+		if snapshots[idx], err = migrator.describeSchema(ctx); err != nil {
+			t.Fatalf("%s", err)
+			return
+		}
+	}
+
+	for idx := len(versions) - 2; idx >= 0; idx-- {
+		if err := migrator.To(ctx, versions[idx]); err != nil {
+			t.Fatalf("migrating down from %d: %s", versions[idx+1], err)
+			return
+		}
+		schema, err := migrator.describeSchema(ctx)
+		if err != nil {
+			t.Fatalf("%s", err)
+			return
+		}
+		if schema != snapshots[idx] {
+			t.Errorf("down migration %d doesn't reverse its up, the schema differs from before it:\n%s", versions[idx+1], diffLines(snapshots[idx], schema))
+		}
+	}
+
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrating up again: %s", err)
+		return
+	}
+	schema, err := migrator.describeSchema(ctx)
+	if err != nil {
+		t.Fatalf("%s", err)
+		return
+	}
+	if want := snapshots[len(snapshots)-1]; schema != want {
+		t.Errorf("migrating up again gave a different schema:\n%s", diffLines(want, schema))
+	}
+}
+
+// describeSchema describes the schema the migrations run in, leaving out the
+// tracking tables
+func (m *Migrator) describeSchema(ctx context.Context) (string, error) {
+	var description string
+	err := m.withSession(ctx, func(conn Queryer) error {
+		var err error
+		description, err = describeSchema(ctx, conn, m.cfg.table.name)
+		return err
+	})
+	return description, err
+}
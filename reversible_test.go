@@ -0,0 +1,72 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// recordingT collects the failures reported to it
+type recordingT struct {
+	errors []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) Fatalf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines("table a\ntable b\ntable c", "table a\ntable c\ntable d")
+	if want := "- table b\n+ table d\n"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got := diffLines("table a", "table a"); got != "" {
+		t.Errorf("Expected no difference, got %q", got)
+	}
+}
+
+func TestVerifyReversible(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"002-bar.up.sql":   "ALTER TABLE foo ADD COLUMN bar text NOT NULL DEFAULT ''; CREATE INDEX foo_bar ON foo (bar);",
+		"002-bar.down.sql": "ALTER TABLE foo DROP COLUMN bar;",
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_reversible")
+	defer conn.Close()
+
+	recorder := &recordingT{}
+	VerifyReversible(recorder, conn, migrateDir)
+	if len(recorder.errors) != 0 {
+		t.Fatalf("Expected the migrations to be reversible, got %v", recorder.errors)
+	}
+
+	leaky := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"002-bar.up.sql":   "CREATE TABLE bar (id int); CREATE INDEX foo_id ON foo (id);",
+		"002-bar.down.sql": "DROP TABLE bar;",
+	})
+	defer os.RemoveAll(leaky)
+
+	conn = getTestConn(t, "test_reversible")
+	defer conn.Close()
+
+	recorder = &recordingT{}
+	VerifyReversible(recorder, conn, leaky)
+	if len(recorder.errors) != 1 {
+		t.Fatalf("Expected one failure, got %v", recorder.errors)
+	}
+	if !strings.Contains(recorder.errors[0], "down migration 2") || !strings.Contains(recorder.errors[0], "+   index foo_id") {
+		t.Errorf("Expected the leftover index to be reported, got %s", recorder.errors[0])
+	}
+}
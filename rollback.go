@@ -0,0 +1,25 @@
+package pgmigrate
+
+import (
+	"context"
+	"database/sql"
+)
+
+// WithRollback runs fn in a transaction which is rolled back when it
+// returns, so test cases sharing one migrated schema leave nothing behind
+// for the next. It isn't an Option. Code under test must use tx rather than
+// db to see its own changes.
+func WithRollback(t TestingT, db *sql.DB, fn func(tx *sql.Tx)) {
+	t.Helper()
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("beginning test transaction: %s", err)
+		return
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			t.Errorf("rolling back test transaction: %s", err)
+		}
+	}()
+	fn(tx)
+}
@@ -0,0 +1,40 @@
+package pgmigrate
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestWithRollback(t *testing.T) {
+	conn := getTestConn(t, "test_rollback")
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := conn.ExecContext(ctx, s1u); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for idx := 0; idx < 2; idx++ {
+		WithRollback(t, conn, func(tx *sql.Tx) {
+			if _, err := tx.ExecContext(ctx, `INSERT INTO foo (id) VALUES (1)`); err != nil {
+				t.Fatal(err.Error())
+			}
+			var count int
+			if err := tx.QueryRowContext(ctx, `SELECT count(*) FROM foo`).Scan(&count); err != nil {
+				t.Fatal(err.Error())
+			}
+			if count != 1 {
+				t.Errorf("Expected only this case's row, got %d", count)
+			}
+		})
+	}
+
+	var count int
+	if err := conn.QueryRowContext(ctx, `SELECT count(*) FROM foo`).Scan(&count); err != nil {
+		t.Fatal(err.Error())
+	}
+	if count != 0 {
+		t.Errorf("Expected the rows to be rolled back, got %d", count)
+	}
+}
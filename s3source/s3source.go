@@ -0,0 +1,91 @@
+// Package s3source reads pgmigrate migrations from an S3 bucket, so a central
+// bucket can serve several deploy jobs without the files being baked into
+// each image.
+package s3source
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"gopkg.daemonl.com/pgmigrate"
+)
+
+// Client is the part of the S3 API the source uses, satisfied by *s3.Client
+type Client interface {
+	ListObjectsV2(context.Context, *s3.ListObjectsV2Input, ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	GetObject(context.Context, *s3.GetObjectInput, ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// Source is a pgmigrate.MigrationSource reading the files directly under a
+// prefix in a bucket, named as they would be in a directory.
+type Source struct {
+	ctx    context.Context
+	client Client
+	bucket string
+	prefix string
+
+	// ETags pins files, by name, to the object with that ETag. Listing or
+	// reading a pinned file fails if it has been replaced since.
+	ETags map[string]string
+}
+
+// New creates a Source for the files under prefix in bucket. A prefix which
+// doesn't end in / has one added. ctx is used for every request.
+func New(ctx context.Context, client Client, bucket string, prefix string) *Source {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &Source{
+		ctx:    ctx,
+		client: client,
+		bucket: bucket,
+		prefix: prefix,
+	}
+}
+
+func (s *Source) List() ([]pgmigrate.Migration, error) {
+	names := []string{}
+	input := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(s.prefix),
+		Delimiter: aws.String("/"),
+	}
+	for {
+		output, err := s.client.ListObjectsV2(s.ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("listing s3://%s/%s: %w", s.bucket, s.prefix, err)
+		}
+		for _, object := range output.Contents {
+			name := strings.TrimPrefix(aws.ToString(object.Key), s.prefix)
+			if etag, ok := s.ETags[name]; ok && etag != aws.ToString(object.ETag) {
+				return nil, fmt.Errorf("%s has ETag %s, but is pinned to %s", name, aws.ToString(object.ETag), etag)
+			}
+			names = append(names, name)
+		}
+		if !aws.ToBool(output.IsTruncated) {
+			break
+		}
+		input.ContinuationToken = output.NextContinuationToken
+	}
+	return pgmigrate.ParseFilenames(names)
+}
+
+func (s *Source) ReadFile(name string) ([]byte, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + name),
+	}
+	if etag, ok := s.ETags[name]; ok {
+		input.IfMatch = aws.String(etag)
+	}
+	output, err := s.client.GetObject(s.ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("reading s3://%s/%s%s: %w", s.bucket, s.prefix, name, err)
+	}
+	defer output.Body.Close()
+	return ioutil.ReadAll(output.Body)
+}
@@ -0,0 +1,95 @@
+package s3source
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type object struct {
+	etag    string
+	content string
+}
+
+// fakeClient serves objects from a map, one key per page
+type fakeClient map[string]object
+
+func (client fakeClient) ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input, opts ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	keys := []string{}
+	for key := range client {
+		if strings.HasPrefix(key, aws.ToString(input.Prefix)) && key > aws.ToString(input.ContinuationToken) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}, nil
+	}
+	first := keys[0]
+	for _, key := range keys {
+		if key < first {
+			first = key
+		}
+	}
+	return &s3.ListObjectsV2Output{
+		Contents:              []types.Object{{Key: aws.String(first), ETag: aws.String(client[first].etag)}},
+		IsTruncated:           aws.Bool(true),
+		NextContinuationToken: aws.String(first),
+	}, nil
+}
+
+func (client fakeClient) GetObject(ctx context.Context, input *s3.GetObjectInput, opts ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	object, ok := client[aws.ToString(input.Key)]
+	if !ok {
+		return nil, errors.New("no such key")
+	}
+	if input.IfMatch != nil && aws.ToString(input.IfMatch) != object.etag {
+		return nil, errors.New("precondition failed")
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewBufferString(object.content))}, nil
+}
+
+func TestSource(t *testing.T) {
+	client := fakeClient{
+		"migrations/001-foo.up.sql":   {etag: `"a"`, content: `CREATE TABLE foo (id int);`},
+		"migrations/001-foo.down.sql": {etag: `"b"`, content: `DROP TABLE foo;`},
+		"migrations/002-bar.up.sql":   {etag: `"c"`, content: `CREATE TABLE bar (id int);`},
+		"other/003-baz.up.sql":        {etag: `"d"`, content: `CREATE TABLE baz (id int);`},
+	}
+
+	source := New(context.Background(), client, "bucket", "migrations")
+	migrations, err := source.List()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 2 || migrations[0].DownFile != "001-foo.down.sql" || migrations[1].Name != "bar" {
+		t.Fatalf("Unexpected migrations %+v", migrations)
+	}
+
+	content, err := source.ReadFile("001-foo.up.sql")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(content) != `CREATE TABLE foo (id int);` {
+		t.Errorf("Wrong content %q", string(content))
+	}
+
+	source.ETags = map[string]string{"002-bar.up.sql": `"c"`}
+	if _, err := source.ReadFile("002-bar.up.sql"); err != nil {
+		t.Errorf("Expected the pinned file to match: %s", err.Error())
+	}
+
+	source.ETags["002-bar.up.sql"] = `"old"`
+	if _, err := source.List(); err == nil {
+		t.Error("Expected listing to fail for a replaced file")
+	}
+	if _, err := source.ReadFile("002-bar.up.sql"); err == nil {
+		t.Error("Expected reading to fail for a replaced file")
+	}
+}
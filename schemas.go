@@ -0,0 +1,82 @@
+package pgmigrate
+
+import (
+	"context"
+	"fmt"
+)
+
+// WithSchema runs the migrations in schema, for schema per tenant databases.
+// The session's search_path is set to just the schema for the run, so
+// unqualified names in the files resolve there, and the tracking table is
+// kept in the schema too, overriding any schema given to WithTableName.
+func WithSchema(schema string) Option {
+	return func(cfg *config) {
+		cfg.schema = schema
+	}
+}
+
+// setSearchPath points the session at the configured schema, returning a
+// function which puts back the search_path it had, which may have been set
+// after connecting rather than being the server's default
+func (m *Migrator) setSearchPath(ctx context.Context, conn Queryer) (func(), error) {
+	if m.cfg.schema == "" {
+		return func() {}, nil
+	}
+	previous := ""
+	if err := conn.QueryRowContext(ctx, `SELECT current_setting('search_path')`).Scan(&previous); err != nil {
+		return nil, fmt.Errorf("reading search_path: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`SET search_path TO %s`, quoteIdentifier(m.cfg.schema))); err != nil {
+		return nil, fmt.Errorf("setting search_path: %w", err)
+	}
+	return func() {
+		conn.ExecContext(context.Background(), `SELECT set_config('search_path', $1, false)`, previous) //nolint: errcheck
+	}, nil
+}
+
+// MigrateSchemas migrates each schema to the latest version in turn, each
+// tracked independently as if run with WithSchema, stopping at the first
+// which fails.
+func MigrateSchemas(ctx context.Context, conn Queryer, schemas []string, opts ...Option) error {
+	for _, schema := range schemas {
+		if err := New(conn, append(opts, WithSchema(schema))...).Up(ctx); err != nil {
+			return fmt.Errorf("schema %s: %w", schema, err)
+		}
+	}
+	return nil
+}
+
+// SchemasLike lists the schemas whose names match the SQL LIKE pattern, such
+// as tenant_%, in name order
+func SchemasLike(ctx context.Context, conn Queryer, pattern string) ([]string, error) {
+	return querySchemas(ctx, conn, `SELECT nspname FROM pg_namespace WHERE nspname LIKE $1 ORDER BY nspname`, pattern)
+}
+
+// SchemasFromQuery lists the schemas named by query, which must return a
+// single text column, such as SELECT schema_name FROM tenants, so tenants
+// added since the last run are picked up.
+func SchemasFromQuery(ctx context.Context, conn Queryer, query string) ([]string, error) {
+	schemas, err := querySchemas(ctx, conn, query)
+	if err != nil {
+		return nil, fmt.Errorf("querying schemas: %w", err)
+	}
+	return schemas, nil
+}
+
+func querySchemas(ctx context.Context, conn Queryer, query string, args ...interface{}) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schemas := []string{}
+	for rows.Next() {
+		var schema string
+		if err := rows.Scan(&schema); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, rows.Err()
+}
@@ -0,0 +1,101 @@
+package pgmigrate
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestWithSchema(t *testing.T) {
+	cfg := newConfig([]Option{WithTableName("shared.schema_version"), WithSchema("tenant_a")})
+	if got := cfg.table.versionTable(); got != `"tenant_a"."schema_version"` {
+		t.Errorf("Expected the table in the tenant schema, got %s", got)
+	}
+	other := newConfig([]Option{WithTableName("shared.schema_version"), WithSchema("tenant_b")})
+	if cfg.table.lockKey() == other.table.lockKey() {
+		t.Errorf("Expected each schema to have its own lock")
+	}
+}
+
+func TestMigrateSchemas(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_schemas")
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := conn.ExecContext(ctx, `
+		DROP SCHEMA IF EXISTS test_tenant_a CASCADE;
+		DROP SCHEMA IF EXISTS test_tenant_b CASCADE;
+		CREATE SCHEMA test_tenant_a;
+		CREATE SCHEMA test_tenant_b;
+	`); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	schemas, err := SchemasLike(ctx, conn, "test\\_tenant\\_%")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(schemas) != 2 || schemas[0] != "test_tenant_a" || schemas[1] != "test_tenant_b" {
+		t.Fatalf("Expected both tenant schemas, got %v", schemas)
+	}
+
+	queried, err := SchemasFromQuery(ctx, conn, `SELECT 'test_tenant_' || id FROM (VALUES ('b')) AS tenants (id)`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(queried) != 1 || queried[0] != "test_tenant_b" {
+		t.Fatalf("Expected the queried tenant, got %v", queried)
+	}
+
+	source := WithSource(DirSource(migrateDir))
+	if err := New(conn, source, WithSchema("test_tenant_a")).To(ctx, 1); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := MigrateSchemas(ctx, conn, schemas, source); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, schema := range schemas {
+		if v, err := New(conn, WithSchema(schema)).Version(ctx); err != nil {
+			t.Fatal(err.Error())
+		} else if v != 3 {
+			t.Errorf("Expected %s at 3, got %d", schema, v)
+		}
+		// The tables are created in the tenant's schema, not the test schema
+		if _, err := conn.ExecContext(ctx, `SELECT * FROM `+quoteIdentifier(schema)+`.baz`); err != nil {
+			t.Errorf("Expected baz in %s: %s", schema, err.Error())
+		}
+	}
+}
+
+func TestSchemaKeepsSearchPath(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_schema_search_path")
+	defer conn.Close()
+	// The run's session is the one the pool hands out next
+	conn.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	if _, err := conn.ExecContext(ctx, `DROP SCHEMA IF EXISTS test_tenant_c CASCADE; CREATE SCHEMA test_tenant_c`); err != nil {
+		t.Fatal(err.Error())
+	}
+	var before string
+	if err := conn.QueryRowContext(ctx, `SELECT current_setting('search_path')`).Scan(&before); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := New(conn, WithSource(DirSource(migrateDir)), WithSchema("test_tenant_c")).Up(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	var after string
+	if err := conn.QueryRowContext(ctx, `SELECT current_setting('search_path')`).Scan(&after); err != nil {
+		t.Fatal(err.Error())
+	}
+	if after != before {
+		t.Errorf("Expected the pool's search_path %q to be put back, got %q", before, after)
+	}
+}
@@ -0,0 +1,123 @@
+package pgmigrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// WithPreScripts runs every .sql file in dir, in name order, at the start of
+// every migration run, before the tracking table is read. They aren't
+// versioned or recorded. Scripts run on the session which runs the
+// migrations, outside any transaction, so a script can SET ROLE for the run.
+// Each logs script_done or script_failed. The role, session authorization and
+// any settings the scripts change are put back when the run finishes.
+func WithPreScripts(dir string) Option {
+	return func(cfg *config) {
+		cfg.preScripts = dir
+	}
+}
+
+// WithPostScripts runs every .sql file in dir, in name order, at the end of
+// every successful migration run, such as to refresh materialized views or
+// grant permissions on new tables. Like pre scripts they run on the session,
+// outside any transaction, aren't recorded, and have what they set put back.
+func WithPostScripts(dir string) Option {
+	return func(cfg *config) {
+		cfg.postScripts = dir
+	}
+}
+
+// runScripts runs the .sql and .sql.gz files in dir in name order, logging
+// script_done or script_failed for each
+func runScripts(ctx context.Context, conn Queryer, dir string, logger Logger) error {
+	if dir == "" {
+		return nil
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	source := DirSource(dir)
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(strings.TrimSuffix(name, ".gz"), ".sql") {
+			continue
+		}
+		content, err := readMigrationFile(source, name)
+		if err != nil {
+			return err
+		}
+
+		started := time.Now()
+		fields := []interface{}{"filename", name, "dir", dir}
+		if _, err := conn.ExecContext(ctx, string(content)); err != nil {
+			fields = append(fields, "duration", time.Since(started), "error", err.Error())
+			logger.Error("script_failed", append(fields, pgErrorFields(err)...)...)
+			return fmt.Errorf("running script %s: %w", name, err)
+		}
+		logger.Info("script_done", append(fields, "duration", time.Since(started))...)
+	}
+	return nil
+}
+
+// sessionSettings reads the settings of the session a user can change, to be
+// put back by restoreSession
+func sessionSettings(ctx context.Context, conn Queryer) (map[string]string, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT name, current_setting(name) FROM pg_settings WHERE context IN ('user', 'superuser')`)
+	if err != nil {
+		return nil, fmt.Errorf("reading session settings: %w", err)
+	}
+	defer rows.Close()
+	settings := map[string]string{}
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		settings[name] = value
+	}
+	return settings, rows.Err()
+}
+
+// restoreSession undoes what pre and post scripts did to the session: SET ROLE and SET
+// SESSION AUTHORIZATION, which RESET ALL leaves alone, and the settings which
+// differ from saved. If that fails, a connection pinned from a pool is thrown
+// away rather than going back to it as whoever the scripts became.
+func restoreSession(conn Queryer, saved map[string]string) error {
+	err := resetSession(conn, saved)
+	if err != nil {
+		if pinned, ok := conn.(*sql.Conn); ok {
+			pinned.Raw(func(interface{}) error { return driver.ErrBadConn }) //nolint: errcheck
+		}
+		return fmt.Errorf("resetting the session after the scripts: %w", err)
+	}
+	return nil
+}
+
+func resetSession(conn Queryer, saved map[string]string) error {
+	ctx := context.Background()
+	for _, statement := range []string{`RESET ROLE`, `RESET SESSION AUTHORIZATION`} {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	current, err := sessionSettings(ctx, conn)
+	if err != nil {
+		return err
+	}
+	for name, value := range saved {
+		if current[name] == value {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, `SELECT set_config($1, $2, false)`, name, value); err != nil {
+			return fmt.Errorf("restoring %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package pgmigrate
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestScripts(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+	preDir := writeMigrationFiles(t, map[string]string{
+		"01-runs.sql":   `CREATE TABLE IF NOT EXISTS runs (stage text, setting text);`,
+		"02-record.sql": `SET application_name = 'pgmigrate_test'; INSERT INTO runs (stage) VALUES ('pre');`,
+		"README.md":     "ignored",
+	})
+	defer os.RemoveAll(preDir)
+	postDir := writeMigrationFiles(t, map[string]string{
+		"record.sql": `INSERT INTO runs (stage, setting) VALUES ('post', current_setting('application_name'));`,
+	})
+	defer os.RemoveAll(postDir)
+
+	conn := getTestConn(t, "test_scripts")
+	defer conn.Close()
+
+	ctx := context.Background()
+	migrator := New(conn, WithSource(DirSource(migrateDir)), WithPreScripts(preDir), WithPostScripts(postDir))
+	for run := 0; run < 2; run++ {
+		if err := migrator.Up(ctx); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT stage, coalesce(setting, '') FROM runs`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer rows.Close()
+	stages := []string{}
+	for rows.Next() {
+		var stage, setting string
+		if err := rows.Scan(&stage, &setting); err != nil {
+			t.Fatal(err.Error())
+		}
+		if stage == "post" && setting != "pgmigrate_test" {
+			t.Errorf("Expected the post script to share the pre script's session, got %q", setting)
+		}
+		stages = append(stages, stage)
+	}
+	if len(stages) != 4 {
+		t.Fatalf("Expected the scripts to run on both runs, got %v", stages)
+	}
+}
+
+func TestPostScriptSession(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+	postDir := writeMigrationFiles(t, map[string]string{
+		"timeout.sql": `SET statement_timeout = '12s';`,
+	})
+	defer os.RemoveAll(postDir)
+
+	conn := getTestConn(t, "test_post_script_session")
+	defer conn.Close()
+	// The run's session is the one the pool hands out next
+	conn.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	var before string
+	if err := conn.QueryRowContext(ctx, `SELECT current_setting('statement_timeout')`).Scan(&before); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := New(conn, WithSource(DirSource(migrateDir)), WithPostScripts(postDir)).Up(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	var after string
+	if err := conn.QueryRowContext(ctx, `SELECT current_setting('statement_timeout')`).Scan(&after); err != nil {
+		t.Fatal(err.Error())
+	}
+	if after != before {
+		t.Errorf("Expected the post script's statement_timeout to be put back to %q, got %q", before, after)
+	}
+}
+
+func TestRestoreSession(t *testing.T) {
+	conn := getTestConn(t, "test_restore_session")
+	defer conn.Close()
+
+	ctx := context.Background()
+	if _, err := conn.ExecContext(ctx, `DO $$ BEGIN CREATE ROLE pgmigrate_script_role; EXCEPTION WHEN duplicate_object THEN NULL; END $$`); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := conn.ExecContext(ctx, `GRANT pgmigrate_script_role TO current_user`); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	session, err := conn.Conn(ctx)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer session.Close()
+
+	saved, err := sessionSettings(ctx, session)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := session.ExecContext(ctx, `SET ROLE pgmigrate_script_role; SET statement_timeout = '12s'`); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := restoreSession(session, saved); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var sameUser bool
+	var timeout, searchPath string
+	if err := session.QueryRowContext(ctx, `SELECT current_user = session_user, current_setting('statement_timeout'), current_setting('search_path')`).Scan(&sameUser, &timeout, &searchPath); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !sameUser {
+		t.Errorf("Expected the role to be reset")
+	}
+	if timeout != saved["statement_timeout"] {
+		t.Errorf("Expected statement_timeout %q, got %q", saved["statement_timeout"], timeout)
+	}
+	if searchPath != saved["search_path"] {
+		t.Errorf("Expected the connection's search_path %q to be kept, got %q", saved["search_path"], searchPath)
+	}
+}
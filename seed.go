@@ -0,0 +1,152 @@
+package pgmigrate
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// WithEnvironment names the environment being migrated, such as production
+// or staging. Seed files restricted with the environments directive only run
+// when it is one of theirs.
+func WithEnvironment(environment string) Option {
+	return func(cfg *config) {
+		cfg.environment = environment
+	}
+}
+
+// seedTable is the quoted name of the table recording seed files, which is
+// the version table's name with seeds appended, e.g. _migrate_seeds_
+func (t trackingTable) seedTable() string {
+	if strings.HasSuffix(t.name, "_") {
+		return t.qualified(t.name + "seeds_")
+	}
+	return t.qualified(t.name + "_seeds")
+}
+
+func (t trackingTable) ensureSeedTable(ctx context.Context, conn Queryer) error {
+	if t.schema != "" {
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS %s`, quoteIdentifier(t.schema))); err != nil {
+			return err
+		}
+	}
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			filename text primary key,
+			checksum text not null,
+			applied_at timestamptz not null
+		);
+	`, t.seedTable()))
+	return err
+}
+
+// Seed runs the .sql files in seedDir which have changed since they last ran,
+// in name order. See Migrator.Seed.
+func Seed(ctx context.Context, conn Queryer, seedDir string, opts ...Option) error {
+	return New(conn, opts...).Seed(ctx, seedDir)
+}
+
+// Seed loads reference data, such as country lists or default roles, from
+// the .sql files in seedDir. Seeds are tracked in their own table, apart from
+// the schema version: each file runs in its own transaction when it is new
+// or its content has changed, so should be idempotent, e.g. with INSERT ... ON
+// CONFLICT. A file which starts with
+//
+//	-- pgmigrate: environments=development,staging
+//
+// only runs when WithEnvironment names one of those environments.
+func (m *Migrator) Seed(ctx context.Context, seedDir string) error {
+	files, err := ioutil.ReadDir(seedDir)
+	if err != nil {
+		return err
+	}
+
+	return m.withSession(ctx, func(conn Queryer) error {
+		table := m.cfg.table
+		if err := table.ensureSeedTable(ctx, conn); err != nil {
+			return err
+		}
+
+		applied := map[string]string{}
+		rows, err := conn.QueryContext(ctx, fmt.Sprintf(`SELECT filename, checksum FROM %s`, table.seedTable()))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var filename, sum string
+			if err := rows.Scan(&filename, &sum); err != nil {
+				return err
+			}
+			applied[filename] = sum
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		source := DirSource(seedDir)
+		for _, file := range files {
+			name := file.Name()
+			if file.IsDir() || !strings.HasSuffix(strings.TrimSuffix(name, ".gz"), ".sql") {
+				continue
+			}
+			content, err := readMigrationFile(source, name)
+			if err != nil {
+				return err
+			}
+			sum := checksum(content)
+			if applied[name] == sum {
+				continue
+			}
+
+			fileDirectives, err := parseDirectives(content)
+			if err != nil {
+				return fmt.Errorf("reading directives in %s: %w", name, err)
+			}
+			if !fileDirectives.inEnvironment(m.cfg.environment) {
+				continue
+			}
+
+			started := time.Now()
+			fields := []interface{}{"filename", name, "dir", seedDir}
+			if err := table.applySeed(ctx, conn, name, content, sum, fileDirectives); err != nil {
+				fields = append(fields, "duration", time.Since(started), "error", err.Error())
+				m.cfg.logger.Error("seed_failed", append(fields, pgErrorFields(err)...)...)
+				return err
+			}
+			m.cfg.logger.Info("seed_done", append(fields, "duration", time.Since(started))...)
+		}
+		return nil
+	})
+}
+
+func (t trackingTable) applySeed(ctx context.Context, conn Queryer, name string, content []byte, sum string, fileDirectives directives) error {
+	if fileDirectives.noTransaction {
+		return fmt.Errorf("%s: seed files always run in a transaction", name)
+	}
+
+	tx, err := conn.BeginTx(ctx, fileDirectives.txOptions())
+	if err != nil {
+		return err
+	}
+	for _, statement := range fileDirectives.setStatements(true) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			tx.Rollback() //nolint: errcheck
+			return fmt.Errorf("applying directives in %s: %w", name, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, string(content)); err != nil {
+		tx.Rollback() //nolint: errcheck
+		return fmt.Errorf("executing seed %s: %w", name, err)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO %s (filename, checksum, applied_at) VALUES ($1, $2, now())
+		ON CONFLICT (filename) DO UPDATE SET checksum = EXCLUDED.checksum, applied_at = EXCLUDED.applied_at
+	`, t.seedTable()), name, sum); err != nil {
+		tx.Rollback() //nolint: errcheck
+		return err
+	}
+	return tx.Commit()
+}
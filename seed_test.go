@@ -0,0 +1,62 @@
+package pgmigrate
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSeed(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-countries.up.sql":   `CREATE TABLE countries (code text primary key, name text);`,
+		"001-countries.down.sql": `DROP TABLE countries;`,
+	})
+	defer os.RemoveAll(migrateDir)
+	seedDir := writeMigrationFiles(t, map[string]string{
+		"countries.sql": `INSERT INTO countries VALUES ('AU', 'Australia') ON CONFLICT (code) DO UPDATE SET name = EXCLUDED.name;`,
+		"test-data.sql": "-- pgmigrate: environments=development\nINSERT INTO countries VALUES ('XX', 'Testland') ON CONFLICT DO NOTHING;",
+	})
+	defer os.RemoveAll(seedDir)
+
+	conn := getTestConn(t, "test_seed")
+	defer conn.Close()
+
+	ctx := context.Background()
+	if err := MigrateDatabase(ctx, conn, migrateDir, -1); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	countCountries := func() int {
+		t.Helper()
+		count := 0
+		if err := conn.QueryRowContext(ctx, `SELECT count(*) FROM countries`).Scan(&count); err != nil {
+			t.Fatal(err.Error())
+		}
+		return count
+	}
+
+	if err := Seed(ctx, conn, seedDir, WithEnvironment("production")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if count := countCountries(); count != 1 {
+		t.Fatalf("Expected only the unrestricted seed in production, got %d rows", count)
+	}
+
+	// A changed seed runs again, and the version is untouched
+	if err := ioutil.WriteFile(filepath.Join(seedDir, "countries.sql"), []byte(`INSERT INTO countries VALUES ('NZ', 'New Zealand') ON CONFLICT DO NOTHING;`), 0660); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := Seed(ctx, conn, seedDir, WithEnvironment("development")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if count := countCountries(); count != 3 {
+		t.Fatalf("Expected 3 rows, got %d", count)
+	}
+	if v, err := ReadVersion(ctx, conn); err != nil {
+		t.Fatal(err.Error())
+	} else if v != 1 {
+		t.Fatalf("Expected seeding to leave the version at 1, got %d", v)
+	}
+}
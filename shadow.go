@@ -0,0 +1,80 @@
+package pgmigrate
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// WithShadowSchema sets whether to first rehearse each run in a new scratch
+// schema of the same database, migrating it from nothing through the current
+// version to the target, and then dropping it. The real run only goes ahead
+// if the rehearsal succeeds, catching syntax and ordering errors before they
+// touch the real schema. Files which name schemas explicitly, or create
+// roles or extensions, reach outside the scratch schema, so should use
+// WithShadowDatabase instead. The rehearsal logs shadow_start and
+// shadow_done around its own events.
+func WithShadowSchema(shadow bool) Option {
+	return func(cfg *config) {
+		cfg.shadowSchema = shadow
+	}
+}
+
+// WithShadowDatabase rehearses each run against conn, an empty scratch
+// database, like WithShadowSchema. The database isn't cleaned up afterwards.
+func WithShadowDatabase(conn Queryer) Option {
+	return func(cfg *config) {
+		cfg.shadowDatabase = conn
+	}
+}
+
+// rehearse runs the migration to targetVersion against the shadow schema or
+// database
+func (m *Migrator) rehearse(ctx context.Context, targetVersion int) error {
+	currentVersion, err := m.ReadVersion(ctx)
+	if err != nil {
+		return err
+	}
+	_, maxMigration, err := loadMigrations(m.cfg)
+	if err != nil {
+		return err
+	}
+	if targetVersion == -1 {
+		targetVersion = maxMigration
+	}
+
+	cfg := *m.cfg
+	cfg.shadowSchema = false
+	cfg.shadowDatabase = nil
+	cfg.allowDown = true
+	shadow := &Migrator{conn: m.conn, cfg: &cfg}
+
+	if m.cfg.shadowDatabase != nil {
+		shadow.conn = m.cfg.shadowDatabase
+		m.cfg.logger.Info("shadow_start", "from", currentVersion, "to", targetVersion)
+	} else {
+		suffix := make([]byte, 8)
+		if _, err := rand.Read(suffix); err != nil {
+			return err
+		}
+		cfg.schema = "pgmigrate_shadow_" + hex.EncodeToString(suffix)
+		cfg.table.schema = cfg.schema
+		if _, err := m.conn.ExecContext(ctx, fmt.Sprintf(`CREATE SCHEMA %s`, quoteIdentifier(cfg.schema))); err != nil {
+			return fmt.Errorf("creating shadow schema: %w", err)
+		}
+		defer m.conn.ExecContext(context.Background(), fmt.Sprintf(`DROP SCHEMA %s CASCADE`, quoteIdentifier(cfg.schema))) //nolint: errcheck
+		m.cfg.logger.Info("shadow_start", "schema", cfg.schema, "from", currentVersion, "to", targetVersion)
+	}
+
+	// The shadow passes through the current version, so a run down is
+	// rehearsed too
+	if err := shadow.To(ctx, currentVersion); err != nil {
+		return fmt.Errorf("shadow run failed, nothing was applied: %w", err)
+	}
+	if err := shadow.To(ctx, targetVersion); err != nil {
+		return fmt.Errorf("shadow run failed, nothing was applied: %w", err)
+	}
+	m.cfg.logger.Info("shadow_done")
+	return nil
+}
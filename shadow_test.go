@@ -0,0 +1,48 @@
+package pgmigrate
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestShadowSchema(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"002-bad.up.sql":   "CREATE TABLE bar (id int REFERENCES missing (id));",
+		"002-bad.down.sql": "DROP TABLE bar;",
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_shadow")
+	defer conn.Close()
+
+	ctx := context.Background()
+	migrator := New(conn, WithSource(DirSource(migrateDir)), WithShadowSchema(true))
+	if err := migrator.Up(ctx); err == nil {
+		t.Fatal("Expected the shadow run to fail")
+	}
+	if v, err := migrator.ReadVersion(ctx); err != nil {
+		t.Fatal(err.Error())
+	} else if v != 0 {
+		t.Fatalf("Expected nothing applied, got version %d", v)
+	}
+
+	var shadows int
+	if err := conn.QueryRowContext(ctx, `SELECT count(*) FROM pg_namespace WHERE nspname LIKE 'pgmigrate\_shadow\_%'`).Scan(&shadows); err != nil {
+		t.Fatal(err.Error())
+	}
+	if shadows != 0 {
+		t.Errorf("Expected the shadow schema to be dropped, found %d", shadows)
+	}
+
+	if err := migrator.To(ctx, 1); err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, err := migrator.ReadVersion(ctx); err != nil {
+		t.Fatal(err.Error())
+	} else if v != 1 {
+		t.Fatalf("Expected version 1, got %d", v)
+	}
+}
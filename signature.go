@@ -0,0 +1,69 @@
+package pgmigrate
+
+import (
+	"fmt"
+)
+
+// SignatureVerifier checks the detached signatures kept next to migration
+// files, see the sigverify package for minisign and OpenPGP
+type SignatureVerifier interface {
+	// SignatureName is the name of the signature file for filename, as in
+	// 001-description.up.sql.minisig
+	SignatureName(filename string) string
+
+	// Verify returns an error unless signature is a valid signature of
+	// content by a trusted key
+	Verify(content []byte, signature []byte) error
+}
+
+// WithSignatures requires every file read from the migration source,
+// including included files, repeatable migrations and the lock file, to have
+// a valid detached signature checked by verifier, so only approved SQL can
+// run. Files are checked as they are read, before any of them run.
+func WithSignatures(verifier SignatureVerifier) Option {
+	return func(cfg *config) {
+		cfg.signatures = verifier
+	}
+}
+
+// signedSource checks the signature of each file read from a source
+type signedSource struct {
+	MigrationSource
+	verifier SignatureVerifier
+}
+
+func (s signedSource) List() ([]Migration, error) {
+	migrations, err := s.MigrationSource.List()
+	if err != nil {
+		return nil, err
+	}
+	// The files are read back through the signature check
+	for idx := range migrations {
+		migrations[idx].source = s
+	}
+	return migrations, nil
+}
+
+func (s signedSource) ListRepeatable() ([]Repeatable, error) {
+	repeatableSource, ok := s.MigrationSource.(RepeatableSource)
+	if !ok {
+		return nil, nil
+	}
+	return repeatableSource.ListRepeatable()
+}
+
+func (s signedSource) ReadFile(name string) ([]byte, error) {
+	content, err := s.MigrationSource.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	signatureName := s.verifier.SignatureName(name)
+	signature, err := s.MigrationSource.ReadFile(signatureName)
+	if err != nil {
+		return nil, fmt.Errorf("reading the signature of %s: %w", name, err)
+	}
+	if err := s.verifier.Verify(content, signature); err != nil {
+		return nil, fmt.Errorf("%s is not validly signed: %w", name, err)
+	}
+	return content, nil
+}
@@ -0,0 +1,79 @@
+package pgmigrate
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// reverseVerifier accepts signatures which are the content reversed
+type reverseVerifier struct{}
+
+func (reverseVerifier) SignatureName(filename string) string {
+	return filename + ".rev"
+}
+
+func (reverseVerifier) Verify(content []byte, signature []byte) error {
+	reversed := make([]byte, len(content))
+	for idx, b := range content {
+		reversed[len(content)-1-idx] = b
+	}
+	if !bytes.Equal(reversed, signature) {
+		return fmt.Errorf("bad signature")
+	}
+	return nil
+}
+
+func reversed(content string) string {
+	runes := []rune(content)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func TestSignatures(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":       "-- pgmigrate:include common.sql\n",
+		"001-foo.up.sql.rev":   reversed("-- pgmigrate:include common.sql\n"),
+		"001-foo.down.sql":     s1d,
+		"001-foo.down.sql.rev": reversed(s1d),
+		"common.sql":           s1u,
+		"common.sql.rev":       reversed(s1u),
+	})
+	defer os.RemoveAll(migrateDir)
+
+	migrations, err := ParseDir(migrateDir, WithSignatures(reverseVerifier{}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cfg := newConfig([]Option{WithSource(DirSource(migrateDir)), WithSignatures(reverseVerifier{})})
+	if rendered, err := renderFile(cfg, migrations[0].source, migrations[0].UpFile); err != nil {
+		t.Fatal(err.Error())
+	} else if string(rendered.content) != s1u+"\n" {
+		t.Errorf("Unexpected content %q", string(rendered.content))
+	}
+
+	for name, content := range map[string]string{
+		"common.sql.rev":   "tampered",
+		"001-foo.down.sql": s1d + "\n-- edited",
+	} {
+		if err := ioutil.WriteFile(migrateDir+"/"+name, []byte(content), 0660); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if _, err := readExpanded(cfg.source, migrations[0].UpFile); err == nil {
+		t.Error("Expected an error for a badly signed include")
+	}
+	if _, err := migrations[0].DownSQL(); err == nil {
+		t.Error("Expected an error for an edited file")
+	}
+	if err := os.Remove(migrateDir + "/001-foo.down.sql.rev"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := migrations[0].DownSQL(); err == nil {
+		t.Error("Expected an error for a missing signature")
+	}
+}
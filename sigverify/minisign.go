@@ -0,0 +1,105 @@
+// Package sigverify checks the detached signatures of pgmigrate migration
+// files, made with minisign or OpenPGP tools such as gpg, for
+// pgmigrate.WithSignatures.
+package sigverify
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// MinisignVerifier checks minisign signatures, kept next to each file as
+// file.minisig, as written by minisign -Sm file
+type MinisignVerifier struct {
+	keyID     []byte
+	publicKey ed25519.PublicKey
+}
+
+// Minisign creates a verifier trusting publicKey, either the base64 key on
+// its own or the whole minisign.pub file
+func Minisign(publicKey string) (*MinisignVerifier, error) {
+	lines := nonCommentLines(publicKey)
+	if len(lines) != 1 {
+		return nil, fmt.Errorf("expected a single minisign public key")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil || len(decoded) != 2+8+ed25519.PublicKeySize || string(decoded[:2]) != "Ed" {
+		return nil, fmt.Errorf("not a minisign public key")
+	}
+	return &MinisignVerifier{
+		keyID:     decoded[2:10],
+		publicKey: ed25519.PublicKey(decoded[10:]),
+	}, nil
+}
+
+// SignatureName is filename.minisig
+func (v *MinisignVerifier) SignatureName(filename string) string {
+	return filename + ".minisig"
+}
+
+// Verify checks both the signature of content and the global signature of
+// its trusted comment
+func (v *MinisignVerifier) Verify(content []byte, signature []byte) error {
+	lines := strings.Split(strings.TrimSpace(string(signature)), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "untrusted comment:") || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return fmt.Errorf("not a minisign signature")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(decoded) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("not a minisign signature")
+	}
+	algorithm, keyID, sig := string(decoded[:2]), decoded[2:10], decoded[10:]
+	if !bytes.Equal(keyID, v.keyID) {
+		return fmt.Errorf("signed by key %X, not the trusted key %X", reverse(keyID), reverse(v.keyID))
+	}
+
+	// ED signatures, the default since minisign 0.10, are of the BLAKE2b
+	// hash of the file, Ed signatures of the file itself
+	message := content
+	switch algorithm {
+	case "ED":
+		hash := blake2b.Sum512(content)
+		message = hash[:]
+	case "Ed":
+	default:
+		return fmt.Errorf("unknown minisign algorithm %q", algorithm)
+	}
+	if !ed25519.Verify(v.publicKey, message, sig) {
+		return fmt.Errorf("bad signature")
+	}
+
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return fmt.Errorf("bad trusted comment signature")
+	}
+	trustedComment := strings.TrimPrefix(strings.TrimRight(lines[2], "\r"), "trusted comment: ")
+	if !ed25519.Verify(v.publicKey, append(append([]byte{}, sig...), trustedComment...), globalSig) {
+		return fmt.Errorf("bad trusted comment signature")
+	}
+	return nil
+}
+
+func nonCommentLines(content string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "untrusted comment:") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// reverse formats key IDs the way minisign prints them, little endian
+func reverse(keyID []byte) []byte {
+	reversed := make([]byte, len(keyID))
+	for idx, b := range keyID {
+		reversed[len(keyID)-1-idx] = b
+	}
+	return reversed
+}
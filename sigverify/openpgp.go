@@ -0,0 +1,52 @@
+package sigverify
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// OpenPGPVerifier checks OpenPGP signatures, kept next to each file as
+// file.sig, as written by gpg --detach-sign, with or without --armor
+type OpenPGPVerifier struct {
+	keyRing openpgp.EntityList
+}
+
+// OpenPGP creates a verifier trusting the public keys in keyRing, which can
+// be armored, as exported by gpg --export --armor, or binary
+func OpenPGP(keyRing []byte) (*OpenPGPVerifier, error) {
+	var entities openpgp.EntityList
+	var err error
+	if isArmored(keyRing) {
+		entities, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(keyRing))
+	} else {
+		entities, err = openpgp.ReadKeyRing(bytes.NewReader(keyRing))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading OpenPGP keys: %w", err)
+	}
+	if len(entities) == 0 {
+		return nil, fmt.Errorf("no OpenPGP keys found")
+	}
+	return &OpenPGPVerifier{keyRing: entities}, nil
+}
+
+// SignatureName is filename.sig
+func (v *OpenPGPVerifier) SignatureName(filename string) string {
+	return filename + ".sig"
+}
+
+// Verify checks signature is by one of the trusted keys
+func (v *OpenPGPVerifier) Verify(content []byte, signature []byte) error {
+	check := openpgp.CheckDetachedSignature
+	if isArmored(signature) {
+		check = openpgp.CheckArmoredDetachedSignature
+	}
+	_, err := check(v.keyRing, bytes.NewReader(content), bytes.NewReader(signature), nil)
+	return err
+}
+
+func isArmored(content []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(content), []byte("-----BEGIN PGP"))
+}
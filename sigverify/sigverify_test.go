@@ -0,0 +1,116 @@
+package sigverify
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisignKey makes a key pair, returning the public key file and a function
+// to sign content like minisign -Sm
+func minisignKey(t *testing.T) (string, func(content []byte) []byte) {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	publicFile := "untrusted comment: minisign public key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), publicKey...)) + "\n"
+
+	sign := func(content []byte) []byte {
+		hash := blake2b.Sum512(content)
+		sig := ed25519.Sign(privateKey, hash[:])
+		trustedComment := "timestamp:1700000000\tfile:test.sql\thashed"
+		globalSig := ed25519.Sign(privateKey, append(append([]byte{}, sig...), trustedComment...))
+		return []byte(fmt.Sprintf("untrusted comment: signature from minisign secret key\n%s\ntrusted comment: %s\n%s\n",
+			base64.StdEncoding.EncodeToString(append(append([]byte("ED"), keyID...), sig...)),
+			trustedComment,
+			base64.StdEncoding.EncodeToString(globalSig),
+		))
+	}
+	return publicFile, sign
+}
+
+func TestMinisign(t *testing.T) {
+	publicFile, sign := minisignKey(t)
+	verifier, err := Minisign(publicFile)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if name := verifier.SignatureName("001-foo.up.sql"); name != "001-foo.up.sql.minisig" {
+		t.Errorf("Unexpected signature name %s", name)
+	}
+
+	content := []byte("CREATE TABLE foo (id int);")
+	signature := sign(content)
+	if err := verifier.Verify(content, signature); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := verifier.Verify([]byte("DROP TABLE foo;"), signature); err == nil {
+		t.Error("Expected an error for edited content")
+	}
+
+	otherPublic, otherSign := minisignKey(t)
+	if _, err := Minisign(otherPublic); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := verifier.Verify(content, otherSign(content)); err == nil {
+		t.Error("Expected an error for a signature by another key")
+	}
+
+	if _, err := Minisign("not a key"); err == nil {
+		t.Error("Expected an error for a bad public key")
+	}
+}
+
+func TestOpenPGP(t *testing.T) {
+	entity, err := openpgp.NewEntity("Release", "", "release@example.com", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	keyRing := &bytes.Buffer{}
+	armored, err := armor.Encode(keyRing, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := entity.Serialize(armored); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := armored.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	verifier, err := OpenPGP(keyRing.Bytes())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	content := []byte("CREATE TABLE foo (id int);")
+	binary := &bytes.Buffer{}
+	if err := openpgp.DetachSign(binary, entity, bytes.NewReader(content), nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := verifier.Verify(content, binary.Bytes()); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	armoredSig := &bytes.Buffer{}
+	if err := openpgp.ArmoredDetachSign(armoredSig, entity, bytes.NewReader(content), nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := verifier.Verify(content, armoredSig.Bytes()); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := verifier.Verify([]byte("DROP TABLE foo;"), binary.Bytes()); err == nil {
+		t.Error("Expected an error for edited content")
+	}
+}
@@ -0,0 +1,94 @@
+package pgmigrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// WithSingleTransaction runs every pending migration of a run in one
+// transaction, so a failure anywhere rolls back the whole run, for sets of
+// migrations which only make sense together. Files marked no-transaction,
+// or with their own isolation level, can't be part of such a run, which is
+// refused before anything starts. Repeatable migrations and post scripts run
+// after the transaction commits.
+func WithSingleTransaction(single bool) Option {
+	return func(cfg *config) {
+		cfg.singleTransaction = single
+	}
+}
+
+// checkSingleTransaction refuses steps which can't run inside another
+// transaction
+func checkSingleTransaction(cfg *config, source MigrationSource, steps []step) error {
+	for _, step := range steps {
+		if step.Func() != nil {
+			continue
+		}
+		rendered, err := renderFile(cfg, source, step.Filename())
+		if err != nil {
+			return err
+		}
+		fileDirectives, err := parseDirectives(rendered.content)
+		if err != nil {
+			return fmt.Errorf("reading directives in %s: %w", step.Filename(), err)
+		}
+		if fileDirectives.noTransaction {
+			return fmt.Errorf("%s is marked no-transaction, so can't run in a single transaction with the others", step.Filename())
+		}
+		if fileDirectives.isolation != sql.LevelDefault {
+			return fmt.Errorf("%s sets its own isolation level, so can't run in a single transaction with the others", step.Filename())
+		}
+	}
+	return nil
+}
+
+// runSingleTransaction runs steps in one transaction, committing only if
+// they all succeed
+func runSingleTransaction(ctx context.Context, conn Queryer, source MigrationSource, steps []step, currentVersion int, targetVersion int, cfg *config) error {
+	if err := checkSingleTransaction(cfg, source, steps); err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	runCfg := *cfg
+	runCfg.runTx = tx
+
+	for _, step := range steps {
+		if err := runFile(ctx, conn, source, step, &runCfg); err != nil {
+			tx.Rollback() //nolint: errcheck
+			cfg.table.recordFailure(conn, targetVersion, step, err)
+			if ctx.Err() != nil {
+				return &InterruptedError{Version: currentVersion, Err: err}
+			}
+			return fmt.Errorf("rolled back every migration in the run, the database is still at version %d: %w", currentVersion, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing the run: %w", err)
+	}
+	if len(steps) > 0 {
+		cfg.reportVersion(steps[len(steps)-1].ResultVersion())
+	}
+	return nil
+}
+
+// applyInRunTransaction applies a step in the transaction of a single
+// transaction run. A failure rolls back the whole run, so the database is
+// never marked dirty. Timeouts set for the file are put back after it.
+func applyInRunTransaction(ctx context.Context, tx *sql.Tx, cfg *config, step step, fileDirectives directives, rendered renderedFile, started time.Time) (int64, error) {
+	rowsAffected, err := applyInTx(ctx, tx, cfg, step, fileDirectives, rendered, started)
+	if err != nil {
+		return 0, err
+	}
+	for _, statement := range fileDirectives.resetStatements() {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return 0, err
+		}
+	}
+	return rowsAffected, nil
+}
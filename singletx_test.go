@@ -0,0 +1,82 @@
+package pgmigrate
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCheckSingleTransaction(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":     s1u,
+		"001-foo.down.sql":   s1d,
+		"002-index.up.sql":   "-- pgmigrate: no-transaction\nCREATE INDEX CONCURRENTLY foo_id ON foo (id);",
+		"002-index.down.sql": "DROP INDEX foo_id;",
+	})
+	defer os.RemoveAll(migrateDir)
+
+	cfg := newConfig([]Option{WithSource(DirSource(migrateDir))})
+	byVersion, _, err := loadMigrations(cfg)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	steps, err := planSteps(byVersion, 0, 1)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := checkSingleTransaction(cfg, cfg.source, steps); err != nil {
+		t.Errorf("Expected 001 to be allowed, got %s", err)
+	}
+
+	steps, err = planSteps(byVersion, 0, 2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := checkSingleTransaction(cfg, cfg.source, steps); err == nil || !strings.Contains(err.Error(), "002-index.up.sql is marked no-transaction") {
+		t.Errorf("Expected 002 to be refused, got %v", err)
+	}
+}
+
+func TestSingleTransaction(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"002-bar.up.sql":   s2u,
+		"002-bar.down.sql": s2d,
+		"003-bad.up.sql":   "CREATE TABLE baz (id int REFERENCES missing (id));",
+		"003-bad.down.sql": s3d,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_single_transaction")
+	defer conn.Close()
+
+	ctx := context.Background()
+	migrator := New(conn, WithSource(DirSource(migrateDir)), WithSingleTransaction(true))
+	if err := migrator.Up(ctx); err == nil {
+		t.Fatal("Expected 003 to fail")
+	}
+	if v, err := migrator.ReadVersion(ctx); err != nil {
+		t.Fatal(err.Error())
+	} else if v != 0 {
+		t.Errorf("Expected the whole run to be rolled back, got version %d", v)
+	}
+	var tables int
+	if err := conn.QueryRowContext(ctx, `SELECT count(*) FROM pg_tables WHERE schemaname = current_schema() AND tablename IN ('foo', 'bar')`).Scan(&tables); err != nil {
+		t.Fatal(err.Error())
+	}
+	if tables != 0 {
+		t.Errorf("Expected foo and bar to be rolled back, found %d", tables)
+	}
+
+	if err := migrator.To(ctx, 2); err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, err := migrator.ReadVersion(ctx); err != nil {
+		t.Fatal(err.Error())
+	} else if v != 2 {
+		t.Errorf("Expected version 2, got %d", v)
+	}
+}
@@ -0,0 +1,374 @@
+package pgmigrate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration is a single numbered step, made up of an up file and a down file.
+// Either direction can instead be a Go function, see WithGoMigrations.
+type Migration struct {
+	Version  int             `json:"version"`
+	Name     string          `json:"name"`
+	UpFile   string          `json:"upFile,omitempty"`
+	DownFile string          `json:"downFile,omitempty"`
+	UpFunc   GoMigrationFunc `json:"-"`
+	DownFunc GoMigrationFunc `json:"-"`
+
+	// source is where the migration was listed from, for reading its files
+	source MigrationSource
+
+	// squashed is set when the up file holds every migration up to its
+	// version, see Squash
+	squashed bool
+}
+
+// UpSQL reads the content of the up file, which is empty if there isn't one
+func (m Migration) UpSQL() (string, error) {
+	return m.readFile(m.UpFile)
+}
+
+// DownSQL reads the content of the down file, which is empty if there isn't
+// one
+func (m Migration) DownSQL() (string, error) {
+	return m.readFile(m.DownFile)
+}
+
+func (m Migration) readFile(name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	if m.source == nil {
+		return "", fmt.Errorf("migration %d was not listed from a source, so %s can't be read", m.Version, name)
+	}
+	content, err := readMigrationFile(m.source, name)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func (m Migration) hasUp() bool {
+	return m.UpFile != "" || m.UpFunc != nil
+}
+
+func (m Migration) hasDown() bool {
+	return m.DownFile != "" || m.DownFunc != nil
+}
+
+// Migrations is a list of migrations in version order
+type Migrations []Migration
+
+// ParseDir reads and validates the migrations in a directory with exactly the
+// rules used when migrating, so tooling can check files before they are run.
+// Options such as WithVersionScheme and WithForwardOnly change the rules as
+// they would for a run.
+func ParseDir(path string, opts ...Option) (Migrations, error) {
+	cfg := newConfig(append(opts, WithSource(DirSource(path))))
+	byVersion, _, err := loadMigrations(cfg)
+	if err != nil {
+		return nil, err
+	}
+	migrations := make(Migrations, 0, len(byVersion))
+	for _, migration := range byVersion {
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// listMigrations lists the migrations in source, recording the source on each
+// so that their content can be read
+func listMigrations(source MigrationSource) ([]Migration, error) {
+	migrations, err := source.List()
+	if err != nil {
+		return nil, err
+	}
+	for idx := range migrations {
+		if migrations[idx].source == nil {
+			migrations[idx].source = source
+		}
+	}
+	return migrations, nil
+}
+
+// readMigrationFile reads name from source, decompressing .gz files, and
+// picking out one direction's section of goose files
+func readMigrationFile(source MigrationSource, name string) ([]byte, error) {
+	name, gooseDirection := gooseSectionFile(name)
+	content, err := source.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(name, ".gz") {
+		reader, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("decompressing %s: %w", name, err)
+		}
+		defer reader.Close()
+		content, err = ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing %s: %w", name, err)
+		}
+	}
+	if gooseDirection != "" {
+		return gooseSection(name, content, gooseDirection)
+	}
+	return content, nil
+}
+
+// MigrationSource supplies the set of migrations to run. The file names in
+// each Migration are passed back to ReadFile to fetch the SQL, which is
+// decompressed for names ending in .gz.
+type MigrationSource interface {
+	List() ([]Migration, error)
+	ReadFile(name string) ([]byte, error)
+}
+
+// DirSource reads migrations from a directory of files named like
+// 001-description.up.sql and 001-description.down.sql, either of which can
+// be gzipped as 001-description.up.sql.gz, or a template, as
+// 001-description.up.sql.tmpl
+type DirSource string
+
+func (dir DirSource) List() ([]Migration, error) {
+	names, err := dir.names()
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := ParseFilenames(names)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range migrations {
+		migrations[idx].source = dir
+	}
+	return migrations, nil
+}
+
+// flywayFilename matches Flyway's versioned and undo migrations, and
+// flywayLike the names Flyway would take as one, with any version
+var (
+	flywayFilename = regexp.MustCompile(`^([VU])([0-9]+)__(.+)\.sql$`)
+	flywayLike     = regexp.MustCompile(`^[VU][0-9][0-9._]*__.+\.sql$`)
+)
+
+// ParseFilenames groups file names like 001-description.up.sql into
+// migrations, in version order, with the same rules as DirSource. The
+// direction and extension are read from the end, so descriptions can hold
+// dots, as in 012-add-v1.2-endpoints.up.sql. Names which aren't .sql,
+// .sql.tmpl or gzipped .sql.gz files are ignored. Flyway's
+// V1__description.sql and U1__description.sql are read as the up and down
+// files of version 1. Only whole number Flyway versions are supported, so
+// V1.1__description.sql and V1_1__description.sql are refused, and like any
+// sequential versions they must run 1, 2, 3 without gaps. goose's
+// 00001_description.sql is read as both, split at its "-- +goose Up" and
+// "-- +goose Down" annotations. Any name of that form is taken for a goose
+// file, so one without the Up annotation fails when it is read, rather than
+// being ignored. Names can be slash separated paths, in which case only the
+// last element is parsed. It is for sources which list files from somewhere
+// other than a directory.
+func ParseFilenames(names []string) ([]Migration, error) {
+	byVersion := map[int]*Migration{}
+
+	for _, name := range names {
+		base := strings.TrimSuffix(strings.TrimSuffix(path.Base(name), ".gz"), ".tmpl")
+		parts := splitFilename(base)
+		if match := flywayFilename.FindStringSubmatch(base); match != nil {
+			// Flyway's V1__description.sql, with U for undo, is read as
+			// 1-description.up.sql
+			direction := directionUp
+			if match[1] == "U" {
+				direction = directionDown
+			}
+			parts = []string{match[2] + "-" + match[3], direction, "sql"}
+		} else if flywayLike.MatchString(base) {
+			return nil, fmt.Errorf("%w %s: Flyway versions must be whole numbers, as in V2__description.sql", ErrBadFilename, name)
+		}
+		if gooseFilename.MatchString(path.Base(name)) {
+			// goose keeps both directions in one file, read a section at a
+			// time
+			parts = []string{parts[0], gooseDirections, "sql"}
+		}
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[2] != "sql" {
+			continue
+		}
+
+		// The version ends at the first - or, as golang-migrate names files,
+		// the first _
+		numberStr := parts[0]
+		if idx := strings.IndexAny(numberStr, "-_"); idx != -1 {
+			numberStr = numberStr[:idx]
+		}
+		numberUI64, err := strconv.ParseUint(numberStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: the version isn't a number", ErrBadFilename, name)
+		}
+		number := int(numberUI64)
+
+		migrationName := strings.TrimPrefix(parts[0], numberStr)
+		if migrationName != "" {
+			migrationName = migrationName[1:]
+		}
+
+		migration, ok := byVersion[number]
+		if !ok {
+			migration = &Migration{
+				Version: number,
+				Name:    migrationName,
+			}
+			byVersion[number] = migration
+		}
+
+		switch parts[1] {
+		case "up":
+			err = setMigrationFile(&migration.UpFile, name, number, directionUp)
+		case "down":
+			err = setMigrationFile(&migration.DownFile, name, number, directionDown)
+		case gooseDirections:
+			err = setMigrationFile(&migration.UpFile, gooseSectionName(name, directionUp), number, directionUp)
+			if err == nil {
+				err = setMigrationFile(&migration.DownFile, gooseSectionName(name, directionDown), number, directionDown)
+			}
+		default:
+			return nil, fmt.Errorf("%w %s: expected up or down before .sql", ErrBadFilename, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// setMigrationFile records name as the file for one direction of a version,
+// refusing a second, which would otherwise silently replace the first
+func setMigrationFile(file *string, name string, version int, direction string) error {
+	if *file != "" {
+		existing, _ := gooseSectionFile(*file)
+		duplicate, _ := gooseSectionFile(name)
+		return fmt.Errorf("%w %d: %s and %s are both %s migrations", ErrDuplicateVersion, version, existing, duplicate, direction)
+	}
+	*file = name
+	return nil
+}
+
+// splitFilename splits a name like 012-add-v1.2-endpoints.up.sql into the
+// version and description, the direction and the extension, from the end, so
+// descriptions can hold dots. Names without a direction are a single part.
+func splitFilename(base string) []string {
+	extension := strings.LastIndex(base, ".")
+	if extension == -1 {
+		return []string{base}
+	}
+	direction := strings.LastIndex(base[:extension], ".")
+	if direction == -1 {
+		return []string{base[:extension], base[extension+1:]}
+	}
+	return []string{base[:direction], base[direction+1 : extension], base[extension+1:]}
+}
+
+// ListRepeatable lists the R-description.sql files in the directory
+func (dir DirSource) ListRepeatable() ([]Repeatable, error) {
+	names, err := dir.names()
+	if err != nil {
+		return nil, err
+	}
+	return ParseRepeatableFilenames(names), nil
+}
+
+func (dir DirSource) names() ([]string, error) {
+	migrateFiles, err := ioutil.ReadDir(string(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(migrateFiles))
+	for _, file := range migrateFiles {
+		names = append(names, file.Name())
+	}
+	return names, nil
+}
+
+func (dir DirSource) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(string(dir), name))
+}
+
+// RecursiveDirSource reads migrations from a directory and every directory
+// below it, so they can be grouped into folders such as 2024-Q1. Versions are
+// still ordered across every folder. File names are slash separated paths
+// relative to the top directory.
+type RecursiveDirSource string
+
+func (dir RecursiveDirSource) List() ([]Migration, error) {
+	names, err := dir.names()
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := ParseFilenames(names)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range migrations {
+		migrations[idx].source = dir
+	}
+	return migrations, nil
+}
+
+// ListRepeatable lists the R-description.sql files in the directory and every
+// directory below it
+func (dir RecursiveDirSource) ListRepeatable() ([]Repeatable, error) {
+	names, err := dir.names()
+	if err != nil {
+		return nil, err
+	}
+	return ParseRepeatableFilenames(names), nil
+}
+
+func (dir RecursiveDirSource) names() ([]string, error) {
+	names := []string{}
+	if err := filepath.Walk(string(dir), func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		relative, err := filepath.Rel(string(dir), filename)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(relative))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func (dir RecursiveDirSource) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(string(dir), filepath.FromSlash(name)))
+}
@@ -0,0 +1,260 @@
+package pgmigrate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeMigrationFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	migrateDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for key, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(migrateDir, key), []byte(content), 0660); err != nil {
+			os.RemoveAll(migrateDir)
+			t.Fatal(err.Error())
+		}
+	}
+	return migrateDir
+}
+
+func TestDirSource(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"002-bar.up.sql":   s2u,
+		"002-bar.down.sql": s2d,
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"README.md":        "ignored",
+	})
+	defer os.RemoveAll(migrateDir)
+
+	source := DirSource(migrateDir)
+	migrations, err := source.List()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(migrations) != 2 {
+		t.Fatalf("Expected 2 migrations, got %d", len(migrations))
+	}
+
+	if migrations[0].Version != 1 || migrations[0].Name != "foo" {
+		t.Errorf("Bad first migration: %+v", migrations[0])
+	}
+	if migrations[1].Version != 2 || migrations[1].UpFile != "002-bar.up.sql" || migrations[1].DownFile != "002-bar.down.sql" {
+		t.Errorf("Bad second migration: %+v", migrations[1])
+	}
+
+	content, err := source.ReadFile(migrations[0].UpFile)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(content) != s1u {
+		t.Errorf("Wrong content %q", string(content))
+	}
+}
+
+func TestDirSourceBadFilename(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.sideways.sql": s1u,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	if _, err := DirSource(migrateDir).List(); err == nil {
+		t.Fatal("Expected an error for a bad direction")
+	}
+}
+
+func TestDottedFilenames(t *testing.T) {
+	migrations, err := ParseFilenames([]string{
+		"012-add-v1.2-endpoints.up.sql",
+		"012-add-v1.2-endpoints.down.sql.gz",
+		"V13__release.2024.sql",
+		"014-notes.txt",
+		"015-plain.sql",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("Expected 2 migrations, got %+v", migrations)
+	}
+	if migrations[0].Version != 12 || migrations[0].Name != "add-v1.2-endpoints" || migrations[0].UpFile != "012-add-v1.2-endpoints.up.sql" || migrations[0].DownFile != "012-add-v1.2-endpoints.down.sql.gz" {
+		t.Errorf("Wrong migration %+v", migrations[0])
+	}
+	if migrations[1].Version != 13 || migrations[1].Name != "release.2024" {
+		t.Errorf("Wrong Flyway migration %+v", migrations[1])
+	}
+}
+
+func TestFlywayDottedVersion(t *testing.T) {
+	for _, name := range []string{"V1.1__split.sql", "V1_1__split.sql", "U2.0__undo.sql"} {
+		if _, err := ParseFilenames([]string{name}); !errors.Is(err, ErrBadFilename) {
+			t.Errorf("%s: expected ErrBadFilename, got %v", name, err)
+		}
+	}
+}
+
+func TestDuplicateVersion(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"007-a.up.sql":   s1u,
+		"007-a.down.sql": s1d,
+		"007-b.up.sql":   s2u,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	_, err := DirSource(migrateDir).List()
+	if !errors.Is(err, ErrDuplicateVersion) {
+		t.Fatalf("Expected ErrDuplicateVersion, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "007-a.up.sql and 007-b.up.sql are both up migrations") {
+		t.Errorf("Expected both names in %q", err.Error())
+	}
+
+	if _, err := ParseFilenames([]string{"001-foo.up.sql", "a/001-foo.up.sql.gz"}); !errors.Is(err, ErrDuplicateVersion) {
+		t.Errorf("Expected a gzipped copy to be a duplicate, got %v", err)
+	}
+}
+
+func TestParseDir(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	migrations, err := ParseDir(migrateDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 3 || migrations[0].Version != 1 || migrations[2].Name != "baz" {
+		t.Errorf("Unexpected migrations %+v", migrations)
+	}
+
+	gapDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql": s1u,
+		"003-baz.up.sql": s3u,
+	})
+	defer os.RemoveAll(gapDir)
+
+	if _, err := ParseDir(gapDir); err == nil {
+		t.Error("Expected an error for the missing migration 2")
+	}
+	if _, err := ParseDir(gapDir, WithForwardOnly(true)); err == nil {
+		t.Error("Expected forward only to still require migration 2")
+	}
+}
+
+func TestMigrationContent(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql": s1u,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	migrations, err := ParseDir(migrateDir, WithForwardOnly(true))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	up, err := migrations[0].UpSQL()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if up != s1u {
+		t.Errorf("Wrong up content %q", up)
+	}
+	if down, err := migrations[0].DownSQL(); err != nil || down != "" {
+		t.Errorf("Expected no down content, got %q, %v", down, err)
+	}
+
+	if _, err := (Migration{Version: 1, UpFile: "001-foo.up.sql"}).UpSQL(); err == nil {
+		t.Error("Expected an error reading a migration without a source")
+	}
+}
+
+func TestGzipFiles(t *testing.T) {
+	compressed := &bytes.Buffer{}
+	writer := gzip.NewWriter(compressed)
+	if _, err := writer.Write([]byte(s1u)); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql.gz": compressed.String(),
+		"001-foo.down.sql":  s1d,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	migrations, err := ParseDir(migrateDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 1 || migrations[0].UpFile != "001-foo.up.sql.gz" || migrations[0].Name != "foo" {
+		t.Fatalf("Unexpected migrations %+v", migrations)
+	}
+	up, err := migrations[0].UpSQL()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if up != s1u {
+		t.Errorf("Expected decompressed content, got %q", up)
+	}
+}
+
+func TestRecursiveDirSource(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	for name, content := range map[string]string{
+		"2024-Q1/002-bar.up.sql":        s2u,
+		"2024-Q1/002-bar.down.sql":      s2d,
+		"2024-Q2/late/003-baz.up.sql":   s3u,
+		"2024-Q2/late/003-baz.down.sql": s3d,
+	} {
+		filename := filepath.Join(migrateDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0660); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	source := RecursiveDirSource(migrateDir)
+	migrations, err := source.List()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 3 {
+		t.Fatalf("Expected 3 migrations, got %+v", migrations)
+	}
+	if migrations[2].UpFile != "2024-Q2/late/003-baz.up.sql" || migrations[2].Name != "baz" {
+		t.Errorf("Unexpected nested migration %+v", migrations[2])
+	}
+	content, err := source.ReadFile(migrations[1].DownFile)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(content) != s2d {
+		t.Errorf("Wrong content %q", string(content))
+	}
+
+	flat, err := DirSource(migrateDir).List()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(flat) != 1 {
+		t.Errorf("Expected DirSource to ignore subdirectories, got %+v", flat)
+	}
+}
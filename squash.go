@@ -0,0 +1,154 @@
+package pgmigrate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// squashedDown is the down file of a squashed migration, which would drop
+// the whole schema
+const squashedDown = `DO $$ BEGIN RAISE EXCEPTION 'a squashed migration can''t be reverted'; END $$;
+`
+
+// Squash replaces the migrations in dir up to and including version through
+// with a single migration at that version, holding their up files one after
+// another, so fresh databases are built in one step. The original files are
+// moved into squashed-through-N.tar.gz in dir. The new file is marked with the
+// squashed directive, by which databases already past the squash, which ran
+// the originals, accept it in their place. Its down file refuses to run.
+//
+// Files which run outside a transaction, or set their own timeouts or
+// isolation, and templates, can't be squashed. It returns the names of the
+// new files.
+func Squash(dir string, through int, opts ...Option) ([]string, error) {
+	cfg := newConfig(append(opts, WithSource(DirSource(dir)), WithForwardOnly(true)))
+	byVersion, _, err := loadMigrations(cfg)
+	if err != nil {
+		return nil, err
+	}
+	last, ok := byVersion[through]
+	if !ok {
+		return nil, fmt.Errorf("there is no migration %d to squash through", through)
+	}
+
+	squashing := Migrations{}
+	for _, migration := range byVersion {
+		if migration.Version <= through {
+			squashing = append(squashing, migration)
+		}
+	}
+	sort.Slice(squashing, func(i, j int) bool {
+		return squashing[i].Version < squashing[j].Version
+	})
+	if len(squashing) < 2 {
+		return nil, fmt.Errorf("there is nothing before migration %d to squash", through)
+	}
+
+	archiveName := fmt.Sprintf("squashed-through-%d.tar.gz", through)
+	content := &bytes.Buffer{}
+	fmt.Fprintf(content, "%s squashed\n-- Migrations %d to %d, the originals are in %s\n", directivePrefix, squashing[0].Version, through, archiveName)
+	originals := []string{}
+	for _, migration := range squashing {
+		if migration.UpFile == "" {
+			return nil, fmt.Errorf("migration %d has no up file to squash", migration.Version)
+		}
+		if isTemplate(migration.UpFile) {
+			return nil, fmt.Errorf("migration %d is a template, which can't be squashed", migration.Version)
+		}
+		up, err := readExpanded(cfg.source, migration.UpFile)
+		if err != nil {
+			return nil, err
+		}
+		fileDirectives, err := parseDirectives(up)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", migration.UpFile, err)
+		}
+		if fileDirectives.noTransaction {
+			return nil, fmt.Errorf("%s runs outside a transaction, so can't be squashed", migration.UpFile)
+		}
+		// Directives only apply to a whole file, so the squashed file would
+		// take the first one's and lose the rest
+		if fileDirectives.statementTimeout != 0 || fileDirectives.lockTimeout != 0 || fileDirectives.isolation != sql.LevelDefault {
+			return nil, fmt.Errorf("%s sets directives for its own transaction, so can't be squashed", migration.UpFile)
+		}
+		fmt.Fprintf(content, "\n-- %s\n", migration.UpFile)
+		content.Write(up)
+		if !bytes.HasSuffix(up, []byte("\n")) {
+			content.WriteString("\n")
+		}
+
+		for _, name := range []string{migration.UpFile, migration.DownFile} {
+			if name == "" {
+				continue
+			}
+			name, _ = gooseSectionFile(name)
+			if len(originals) == 0 || originals[len(originals)-1] != name {
+				originals = append(originals, name)
+			}
+		}
+	}
+
+	if err := writeSquashArchive(filepath.Join(dir, archiveName), dir, originals); err != nil {
+		return nil, err
+	}
+
+	// The originals are archived, so the new files can take their place
+	for _, name := range originals {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return nil, err
+		}
+	}
+
+	name := fmt.Sprintf("%0*d-squashed", versionWidth(last), through)
+	filenames := []string{
+		filepath.Join(dir, name+"."+directionUp+".sql"),
+		filepath.Join(dir, name+"."+directionDown+".sql"),
+	}
+	if err := writeNewFile(filenames[0], content.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := writeNewFile(filenames[1], []byte(squashedDown)); err != nil {
+		return nil, err
+	}
+	return filenames, nil
+}
+
+// writeSquashArchive writes the named files from dir into a new .tar.gz at
+// filename
+func writeSquashArchive(filename string, dir string, names []string) error {
+	buffer := &bytes.Buffer{}
+	gz := gzip.NewWriter(buffer)
+	archive := tar.NewWriter(gz)
+	for _, name := range names {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		if err := archive.WriteHeader(&tar.Header{
+			Name:    filepath.ToSlash(name),
+			Mode:    0644,
+			Size:    int64(len(content)),
+			ModTime: time.Now(),
+		}); err != nil {
+			return err
+		}
+		if _, err := archive.Write(content); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return writeNewFile(filename, buffer.Bytes())
+}
@@ -0,0 +1,95 @@
+package pgmigrate
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSquash(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	filenames, err := Squash(migrateDir, 2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(filenames) != 2 {
+		t.Fatalf("Expected an up and down file, got %v", filenames)
+	}
+
+	migrations, err := ParseDir(migrateDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 2 || migrations[0].Version != 2 || migrations[0].Name != "squashed" || migrations[1].Version != 3 {
+		t.Fatalf("Unexpected migrations %+v", migrations)
+	}
+	up, err := migrations[0].UpSQL()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(up, s1u) || !strings.Contains(up, s2u) || strings.Index(up, s1u) > strings.Index(up, s2u) {
+		t.Errorf("Expected migrations 1 and 2 in order, got %q", up)
+	}
+
+	archive, err := OpenArchive(migrateDir+"/squashed-through-2.tar.gz", "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	archived, err := archive.List()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(archived) != 2 || archived[1].DownFile == "" {
+		t.Errorf("Expected the originals in the archive, got %+v", archived)
+	}
+
+	if _, err := Squash(migrateDir, 2); err == nil {
+		t.Error("Expected an error squashing a single migration")
+	}
+	if _, err := Squash(migrateDir, 4); err == nil {
+		t.Error("Expected an error squashing through an unknown version")
+	}
+}
+
+func TestSquashDirectives(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   "-- pgmigrate: statement_timeout=5m\n" + s1u,
+		"001-foo.down.sql": s1d,
+		"002-bar.up.sql":   s2u,
+		"002-bar.down.sql": s2d,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	if _, err := Squash(migrateDir, 2); err == nil || !strings.Contains(err.Error(), "001-foo.up.sql") {
+		t.Fatalf("Expected the statement_timeout of the first file to stop the squash, got %v", err)
+	}
+	migrations, err := ParseDir(migrateDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 2 {
+		t.Errorf("Expected the originals to be left alone, got %+v", migrations)
+	}
+}
+
+func TestSquashAppliedDatabase(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_squash")
+	defer conn.Close()
+
+	ctx := context.Background()
+	if err := MigrateDatabase(ctx, conn, migrateDir, 2); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := Squash(migrateDir, 2); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := MigrateDatabase(ctx, conn, migrateDir, -1); err != nil {
+		t.Fatal(err.Error())
+	}
+}
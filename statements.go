@@ -0,0 +1,122 @@
+package pgmigrate
+
+import (
+	"strings"
+)
+
+// WithSplitStatements runs each file a statement at a time, split by
+// SplitStatements, rather than sending it as one query, for drivers using the
+// simple protocol one statement at a time, and poolers such as pgbouncer.
+// Each statement logs statement_done, with statement, of and rows_affected
+// fields. Files marked no-transaction can then hold several statements, such
+// as more than one CREATE INDEX CONCURRENTLY, which can't be sent together.
+func WithSplitStatements(split bool) Option {
+	return func(cfg *config) {
+		cfg.splitStatements = split
+	}
+}
+
+// SplitStatements splits SQL at the semicolons between statements, skipping
+// those inside string literals, including E'...' strings with backslash
+// escapes, quoted identifiers, dollar quoted bodies and comments. Statements
+// keep their comments, and are trimmed, with empty ones left out. It is for
+// running a file a statement at a time, where a driver or pooler can't take
+// several in one query, see WithSplitStatements.
+func SplitStatements(content string) []string {
+	statements := []string{}
+	start := 0
+	add := func(end int) {
+		if statement := strings.TrimSpace(content[start:end]); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+
+	for idx := 0; idx < len(content); idx++ {
+		switch c := content[idx]; {
+		case c == ';':
+			add(idx + 1)
+			start = idx + 1
+		case c == '\'' && isEscapeString(content, idx):
+			for idx++; idx < len(content) && content[idx] != '\''; idx++ {
+				if content[idx] == '\\' {
+					idx++
+				}
+			}
+		case c == '\'' || c == '"':
+			// a doubled quote closes and reopens, so needs no special case
+			end := strings.IndexByte(content[idx+1:], c)
+			if end == -1 {
+				idx = len(content)
+			} else {
+				idx += end + 1
+			}
+		case strings.HasPrefix(content[idx:], "--"):
+			end := strings.IndexByte(content[idx:], '\n')
+			if end == -1 {
+				idx = len(content)
+			} else {
+				idx += end
+			}
+		case strings.HasPrefix(content[idx:], "/*"):
+			// block comments nest
+			depth := 0
+			for ; idx < len(content); idx++ {
+				if strings.HasPrefix(content[idx:], "/*") {
+					depth++
+					idx++
+				} else if strings.HasPrefix(content[idx:], "*/") {
+					depth--
+					idx++
+					if depth == 0 {
+						break
+					}
+				}
+			}
+		case c == '$':
+			tag, ok := dollarQuoteTag(content[idx:])
+			if !ok {
+				continue
+			}
+			end := strings.Index(content[idx+len(tag):], tag)
+			if end == -1 {
+				idx = len(content)
+			} else {
+				idx += len(tag) + end + len(tag) - 1
+			}
+		}
+	}
+	if start < len(content) {
+		add(len(content))
+	}
+	return statements
+}
+
+// dollarQuoteTag returns the $tag$ opening a dollar quoted string at the start
+// of content, as in $$ or $body$
+func dollarQuoteTag(content string) (string, bool) {
+	for idx := 1; idx < len(content); idx++ {
+		c := content[idx]
+		if c == '$' {
+			return content[:idx+1], true
+		}
+		isLetter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+		if !isLetter && !(idx > 1 && c >= '0' && c <= '9') {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// isEscapeString reports whether the quote at idx opens an E'...' string, where
+// a backslash escapes the next character
+func isEscapeString(content string, idx int) bool {
+	if idx == 0 || (content[idx-1] != 'E' && content[idx-1] != 'e') {
+		return false
+	}
+	if idx == 1 {
+		return true
+	}
+	// The E mustn't be the end of a longer word, as in "SOME'x'"
+	c := content[idx-2]
+	return !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80)
+}
@@ -0,0 +1,63 @@
+package pgmigrate
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	statements := SplitStatements(`
+		CREATE TABLE "semi;colon" (note text DEFAULT 'a;b', other text DEFAULT 'it''s; fine');
+		-- a comment; with a semicolon
+		CREATE FUNCTION f() RETURNS int AS $body$ BEGIN RETURN 1; END $body$ LANGUAGE plpgsql;
+		/* outer /* nested; */ still; comment */ SELECT $1;
+		DO $$ BEGIN PERFORM 1; END $$
+	`)
+	expected := []string{
+		`CREATE TABLE "semi;colon" (note text DEFAULT 'a;b', other text DEFAULT 'it''s; fine');`,
+		"-- a comment; with a semicolon\n\t\tCREATE FUNCTION f() RETURNS int AS $body$ BEGIN RETURN 1; END $body$ LANGUAGE plpgsql;",
+		"/* outer /* nested; */ still; comment */ SELECT $1;",
+		"DO $$ BEGIN PERFORM 1; END $$",
+	}
+	if !reflect.DeepEqual(statements, expected) {
+		t.Errorf("Unexpected statements %q", statements)
+	}
+}
+
+func TestSplitEscapeStrings(t *testing.T) {
+	statements := SplitStatements(`INSERT INTO notes VALUES (E'it\'s; escaped', e'\\', 'plain\', 'x');SELECT 'SOME'';still quoted';`)
+	expected := []string{
+		`INSERT INTO notes VALUES (E'it\'s; escaped', e'\\', 'plain\', 'x');`,
+		`SELECT 'SOME'';still quoted';`,
+	}
+	if !reflect.DeepEqual(statements, expected) {
+		t.Errorf("Unexpected statements %q", statements)
+	}
+}
+
+func TestSplitStatementsMigration(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":       "CREATE TABLE foo (id int, note text); INSERT INTO foo VALUES (1, E'a;b'), (2, 'c');",
+		"001-foo.down.sql":     s1d,
+		"002-indexes.up.sql":   "-- pgmigrate: no-transaction\nCREATE INDEX CONCURRENTLY foo_id ON foo (id);\nCREATE INDEX CONCURRENTLY foo_note ON foo (note);",
+		"002-indexes.down.sql": "DROP INDEX foo_id; DROP INDEX foo_note;",
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_split_statements")
+	defer conn.Close()
+
+	ctx := context.Background()
+	if err := New(conn, WithSource(DirSource(migrateDir)), WithSplitStatements(true)).Up(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	var indexes int
+	if err := conn.QueryRowContext(ctx, `SELECT count(*) FROM pg_indexes WHERE schemaname = current_schema() AND tablename = 'foo'`).Scan(&indexes); err != nil {
+		t.Fatal(err.Error())
+	}
+	if indexes != 2 {
+		t.Errorf("Expected both indexes, got %d", indexes)
+	}
+}
@@ -0,0 +1,146 @@
+package pgmigrate
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// MigrationStatus is a Migration along with whether it is currently applied.
+type MigrationStatus struct {
+	Migration
+	Applied bool `json:"applied"`
+
+	// AppliedAt is the time the migration was last applied, if it is applied
+	// and the run was recorded in the history.
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+
+	// Duration is how long that run took, to the millisecond, which is
+	// nanoseconds in JSON
+	Duration *time.Duration `json:"duration,omitempty"`
+}
+
+// Status lists every migration in source, in version order, with its state
+// in the database.
+func Status(ctx context.Context, conn Queryer, source MigrationSource) ([]MigrationStatus, error) {
+	return New(conn, WithSource(source)).Status(ctx)
+}
+
+// Status lists every migration in the source, in version order, with its
+// state in the database, without modifying it.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withReadSession(ctx, func(conn Queryer) error {
+		var err error
+		statuses, err = m.status(ctx, conn)
+		return err
+	})
+	return statuses, err
+}
+
+// status reads the state of each migration without creating the tracking or
+// history tables, which a database never migrated doesn't have
+func (m *Migrator) status(ctx context.Context, conn Queryer) ([]MigrationStatus, error) {
+	table := m.cfg.table
+	currentVersion, err := table.peekVersion(ctx, conn)
+	if err != nil && err != errNoTable {
+		return nil, err
+	}
+
+	migrations, err := listMigrations(m.cfg.source)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := table.history(ctx, conn)
+	if err != nil && sqlState(err) != sqlStateUndefinedTable {
+		return nil, err
+	}
+
+	lastApplied := map[int]HistoryEntry{}
+	for _, entry := range history {
+		if entry.Direction == directionUp {
+			lastApplied[entry.Version] = entry
+		}
+	}
+
+	// Older migrations which were never applied, as Pending lists them
+	byVersion := map[int]Migration{}
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+	unapplied := map[int]bool{}
+	for _, version := range unappliedVersions(history, byVersion, currentVersion) {
+		unapplied[version] = true
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{
+			Migration: migration,
+			Applied:   migration.Version <= currentVersion && !unapplied[migration.Version],
+		}
+		if entry, ok := lastApplied[migration.Version]; ok && status.Applied {
+			status.AppliedAt = &entry.AppliedAt
+			status.Duration = &entry.Duration
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending lists the migrations in source which have not been applied, in
+// version order, without modifying the database. This includes any older
+// than the current version which were never applied.
+func Pending(ctx context.Context, conn Queryer, source MigrationSource) ([]Migration, error) {
+	return New(conn, WithSource(source)).Pending(ctx)
+}
+
+// IsUpToDate reports whether every migration in source has been applied, for
+// checking at startup that the schema matches the code.
+func IsUpToDate(ctx context.Context, conn Queryer, source MigrationSource) (bool, error) {
+	return New(conn, WithSource(source)).IsUpToDate(ctx)
+}
+
+// Pending lists the migrations in the source which have not been applied, in
+// version order, without modifying the database.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	table := m.cfg.table
+	currentVersion, err := table.peekVersion(ctx, m.conn)
+	if err != nil && err != errNoTable {
+		return nil, err
+	}
+
+	byVersion, _, err := loadMigrations(m.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := table.history(ctx, m.conn)
+	if err != nil && sqlState(err) != sqlStateUndefinedTable {
+		return nil, err
+	}
+
+	pending := []Migration{}
+	for _, version := range unappliedVersions(history, byVersion, currentVersion) {
+		pending = append(pending, byVersion[version])
+	}
+	for _, migration := range byVersion {
+		if migration.Version > currentVersion {
+			pending = append(pending, migration)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Version < pending[j].Version
+	})
+	return pending, nil
+}
+
+// IsUpToDate reports whether every migration in the source has been applied
+func (m *Migrator) IsUpToDate(ctx context.Context) (bool, error) {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return false, err
+	}
+	return len(pending) == 0, nil
+}
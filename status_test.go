@@ -0,0 +1,108 @@
+package pgmigrate
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_status")
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	// A database never migrated has everything pending, and is left alone
+	statuses, err := Status(ctx, conn, DirSource(migrateDir))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, status := range statuses {
+		if status.Applied {
+			t.Errorf("Migration %d: expected nothing to be applied yet", status.Version)
+		}
+	}
+	table := newConfig(nil).table
+	for _, name := range []string{table.versionTable(), table.historyTable()} {
+		var exists bool
+		if err := conn.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, name).Scan(&exists); err != nil {
+			t.Fatal(err.Error())
+		}
+		if exists {
+			t.Errorf("Expected Status not to create %s", name)
+		}
+	}
+
+	if err := MigrateDatabase(ctx, conn, migrateDir, 2); err != nil {
+		t.Fatalf("Unable to migrate: %s", err.Error())
+	}
+
+	statuses, err = Status(ctx, conn, DirSource(migrateDir))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(statuses) != 3 {
+		t.Fatalf("Expected 3 migrations, got %d", len(statuses))
+	}
+
+	for idx, expectApplied := range []bool{true, true, false} {
+		status := statuses[idx]
+		if status.Applied != expectApplied {
+			t.Errorf("Migration %d: expected applied=%v", status.Version, expectApplied)
+		}
+		if expectApplied && status.AppliedAt == nil {
+			t.Errorf("Migration %d: expected an applied time", status.Version)
+		}
+		if expectApplied && status.Duration == nil {
+			t.Errorf("Migration %d: expected a duration", status.Version)
+		}
+		if !expectApplied && status.Duration != nil {
+			t.Errorf("Migration %d: expected no duration for a pending migration", status.Version)
+		}
+	}
+}
+
+func TestPending(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_pending")
+	defer conn.Close()
+
+	ctx := context.Background()
+	source := DirSource(migrateDir)
+
+	pending, err := Pending(ctx, conn, source)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(pending) != 3 {
+		t.Errorf("Expected 3 pending migrations before migrating, got %d", len(pending))
+	}
+
+	if err := MigrateDatabase(ctx, conn, migrateDir, 2); err != nil {
+		t.Fatalf("Unable to migrate: %s", err.Error())
+	}
+
+	pending, err = Pending(ctx, conn, source)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(pending) != 1 || pending[0].Version != 3 {
+		t.Errorf("Expected only 3 to be pending, got %+v", pending)
+	}
+	if upToDate, err := IsUpToDate(ctx, conn, source); err != nil || upToDate {
+		t.Errorf("Expected not to be up to date, got %v, %v", upToDate, err)
+	}
+
+	if err := MigrateDatabase(ctx, conn, migrateDir, -1); err != nil {
+		t.Fatalf("Unable to migrate: %s", err.Error())
+	}
+	if upToDate, err := IsUpToDate(ctx, conn, source); err != nil || !upToDate {
+		t.Errorf("Expected to be up to date, got %v, %v", upToDate, err)
+	}
+}
@@ -0,0 +1,107 @@
+package pgmigrate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// FileLister is implemented by sources which can list every file they hold,
+// not just the migrations, for WithStrict. DirSource, RecursiveDirSource and
+// ArchiveSource all do.
+type FileLister interface {
+	ListFiles() ([]string, error)
+}
+
+func (dir DirSource) ListFiles() ([]string, error) {
+	entries, err := ioutil.ReadDir(string(dir))
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func (dir RecursiveDirSource) ListFiles() ([]string, error) {
+	return dir.names()
+}
+
+func (source *ArchiveSource) ListFiles() ([]string, error) {
+	return source.names(), nil
+}
+
+func (s signedSource) ListFiles() ([]string, error) {
+	return listFiles(s.MigrationSource)
+}
+
+// WithStrict fails runs when the source holds a file which looks like a
+// migration, by starting with a version, but isn't read as one, such as
+// 010-foo.up.sql.bak or 010-foo-up.sql. Otherwise such files are skipped,
+// and a skipped migration is worse than a failed run. The source must be a
+// FileLister.
+func WithStrict(strict bool) Option {
+	return func(cfg *config) {
+		cfg.strict = strict
+	}
+}
+
+// versionedFilename matches names starting with a version, including
+// Flyway's V1__ and U1__
+var versionedFilename = regexp.MustCompile(`^([VU]?[0-9])`)
+
+// signatureSuffixes are added to file names by the sigverify verifiers
+var signatureSuffixes = []string{".minisig", ".sig"}
+
+// listFiles lists every file in source, or fails if it can't
+func listFiles(source MigrationSource) ([]string, error) {
+	if wrapped, ok := source.(goSource); ok {
+		source = wrapped.MigrationSource
+	}
+	lister, ok := source.(FileLister)
+	if !ok {
+		return nil, fmt.Errorf("strict mode needs a source which can list its files, %T can't", source)
+	}
+	return lister.ListFiles()
+}
+
+// checkStrict refuses files in source which start with a version but aren't
+// any of migrations' files, or the signature of one
+func checkStrict(source MigrationSource, migrations []Migration) error {
+	names, err := listFiles(source)
+	if err != nil {
+		return err
+	}
+
+	known := map[string]bool{}
+	for _, migration := range migrations {
+		for _, name := range []string{migration.UpFile, migration.DownFile} {
+			name, _ = gooseSectionFile(name)
+			known[name] = true
+		}
+	}
+
+	unknown := []string{}
+	for _, name := range names {
+		if !versionedFilename.MatchString(path.Base(name)) {
+			continue
+		}
+		signed := name
+		for _, suffix := range signatureSuffixes {
+			signed = strings.TrimSuffix(signed, suffix)
+		}
+		if !known[name] && !known[signed] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w, not read as a migration, name migrations like 001-description.up.sql: %s", ErrBadFilename, strings.Join(unknown, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package pgmigrate
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStrict(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":         s1u,
+		"001-foo.down.sql":       s1d,
+		"001-foo.up.sql.minisig": "signature",
+		"R-views.sql":            "SELECT 1",
+		"README.md":              "ignored",
+	})
+	defer os.RemoveAll(migrateDir)
+	if err := os.Mkdir(filepath.Join(migrateDir, "2024-Q1"), 0770); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err := ParseDir(migrateDir, WithStrict(true)); err != nil {
+		t.Fatalf("Expected the directory to pass, got %s", err)
+	}
+
+	for _, name := range []string{"002-bar.up.sql.bak", "002-bar-up.sql", "V2_bar.sql"} {
+		path := filepath.Join(migrateDir, name)
+		if err := ioutil.WriteFile(path, []byte(s2u), 0660); err != nil {
+			t.Fatal(err.Error())
+		}
+		if _, err := ParseDir(migrateDir); err != nil {
+			t.Errorf("%s: expected to be skipped without strict, got %s", name, err)
+		}
+		_, err := ParseDir(migrateDir, WithStrict(true))
+		if !errors.Is(err, ErrBadFilename) || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: expected to be refused, got %v", name, err)
+		}
+		os.Remove(path)
+	}
+}
+
+func TestStrictNeedsFileLister(t *testing.T) {
+	cfg := newConfig([]Option{WithSource(failingSource{}), WithStrict(true)})
+	if _, _, err := loadMigrations(cfg); err == nil || !strings.Contains(err.Error(), "can list its files") {
+		t.Errorf("Expected strict mode to need a FileLister, got %v", err)
+	}
+}
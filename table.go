@@ -0,0 +1,104 @@
+package pgmigrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// trackingTable names the table which records the current version. The
+// history table sits alongside it, in the same schema.
+type trackingTable struct {
+	schema string
+	name   string
+}
+
+var defaultTable = trackingTable{name: "_migrate_"}
+
+// parseTableName accepts either table or schema.table
+func parseTableName(name string) trackingTable {
+	if idx := strings.LastIndex(name, "."); idx != -1 {
+		return trackingTable{schema: name[:idx], name: name[idx+1:]}
+	}
+	return trackingTable{name: name}
+}
+
+func (t trackingTable) String() string {
+	if t.schema == "" {
+		return t.name
+	}
+	return t.schema + "." + t.name
+}
+
+func (t trackingTable) qualified(name string) string {
+	if t.schema == "" {
+		return quoteIdentifier(name)
+	}
+	return quoteIdentifier(t.schema) + "." + quoteIdentifier(name)
+}
+
+// versionTable is the quoted name of the table holding the current version
+func (t trackingTable) versionTable() string {
+	return t.qualified(t.name)
+}
+
+// historyTable is the quoted name of the history table, which is the version
+// table's name with history appended, e.g. _migrate_history_ or
+// schema_version_history
+func (t trackingTable) historyTable() string {
+	if strings.HasSuffix(t.name, "_") {
+		return t.qualified(t.name + "history_")
+	}
+	return t.qualified(t.name + "_history")
+}
+
+func (t trackingTable) lockKey() int64 {
+	return lockKey(t.String())
+}
+
+func getVersion(ctx context.Context, conn Queryer) (int, error) {
+	return defaultTable.getVersion(ctx, conn)
+}
+
+// getVersion reads the current version, creating the table at version 0 if
+// it doesn't exist yet
+func (t trackingTable) getVersion(ctx context.Context, conn Queryer) (int, error) {
+	currentVersion, err := t.peekVersion(ctx, conn)
+	if err != errNoTable {
+		return currentVersion, err
+	}
+	if t.schema != "" {
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS %s`, quoteIdentifier(t.schema))); err != nil {
+			return 0, err
+		}
+	}
+	if _, err = conn.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE %s (version bigint primary key, dirty_version bigint);
+		INSERT INTO %s (version) VALUES (0);
+		`, t.versionTable(), t.versionTable())); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+var errNoTable = errors.New("migration table does not exist")
+
+// peekVersion reads the current version without creating the migration table,
+// returning errNoTable if it doesn't exist yet.
+func (t trackingTable) peekVersion(ctx context.Context, conn Queryer) (int, error) {
+	currentVersionRow := conn.QueryRowContext(ctx, fmt.Sprintf(`SELECT version FROM %s`, t.versionTable()))
+	currentVersion := 0
+	if err := currentVersionRow.Scan(&currentVersion); err != nil {
+		switch sqlState(err) {
+		case sqlStateUndefinedTable, sqlStateInvalidSchemaName:
+			return 0, errNoTable
+		}
+		return 0, err
+	}
+	return currentVersion, nil
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
@@ -0,0 +1,71 @@
+package pgmigrate
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestParseTableName(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		expectVersion string
+		expectHistory string
+	}{
+		{"_migrate_", `"_migrate_"`, `"_migrate_history_"`},
+		{"myapp.schema_version", `"myapp"."schema_version"`, `"myapp"."schema_version_history"`},
+		{`odd"name`, `"odd""name"`, `"odd""name_history"`},
+	} {
+		table := parseTableName(tc.name)
+		if got := table.versionTable(); got != tc.expectVersion {
+			t.Errorf("%s: expected version table %s, got %s", tc.name, tc.expectVersion, got)
+		}
+		if got := table.historyTable(); got != tc.expectHistory {
+			t.Errorf("%s: expected history table %s, got %s", tc.name, tc.expectHistory, got)
+		}
+		if table.String() != tc.name {
+			t.Errorf("%s: round tripped to %s", tc.name, table.String())
+		}
+	}
+}
+
+func TestTableName(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, testMigrations)
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_table")
+	defer conn.Close()
+
+	ctx := context.Background()
+	source := WithSource(DirSource(migrateDir))
+
+	if _, err := conn.ExecContext(ctx, `DROP SCHEMA IF EXISTS test_table_tracker CASCADE`); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tracked := New(conn, source, WithTableName("test_table_tracker.schema_version"))
+	if err := tracked.To(ctx, 1); err != nil {
+		t.Fatalf("Unable to migrate: %s", err.Error())
+	}
+
+	// The default table is tracked independently, so is still at 0
+	if v, err := New(conn, source).Version(ctx); err != nil {
+		t.Fatal(err.Error())
+	} else if v != 0 {
+		t.Fatalf("Expected the default table to be at 0, got %d", v)
+	}
+
+	if v, err := tracked.Version(ctx); err != nil {
+		t.Fatal(err.Error())
+	} else if v != 1 {
+		t.Fatalf("Expected the named table to be at 1, got %d", v)
+	}
+
+	entries, err := tracked.History(ctx)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 history entry, got %d", len(entries))
+	}
+}
@@ -0,0 +1,147 @@
+package pgmigrate
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+)
+
+// GetTestDatabase drops and recreates the database name on the server at
+// adminURL, a postgres:// URL for a user allowed to create databases,
+// returning a pool connected to it. It is for tests of extensions or of more
+// than one schema, which GetTestSchema can't isolate. Close the pool before
+// dropping the database with DropTestDatabase.
+func GetTestDatabase(ctx context.Context, adminURL string, name string) (*sql.DB, error) {
+	if err := recreateDatabase(ctx, adminURL, name, ""); err != nil {
+		return nil, err
+	}
+	return openDatabase(adminURL, name)
+}
+
+// DropTestDatabase drops the database name if it exists
+func DropTestDatabase(ctx context.Context, adminURL string, name string) error {
+	return dropDatabase(ctx, adminURL, name)
+}
+
+// TestTemplate is a database migrated once, then copied for each test with
+// CREATE DATABASE ... TEMPLATE, which is much faster than running every
+// migration per test.
+type TestTemplate struct {
+	adminURL string
+	name     string
+}
+
+// NewTestTemplate drops and recreates the database name on the server at
+// adminURL, a postgres:// URL for a user allowed to create databases, and
+// migrates it up with the migrations in dir.
+func NewTestTemplate(ctx context.Context, adminURL string, name string, dir string, opts ...Option) (*TestTemplate, error) {
+	if err := recreateDatabase(ctx, adminURL, name, ""); err != nil {
+		return nil, err
+	}
+	db, err := openDatabase(adminURL, name)
+	if err != nil {
+		return nil, err
+	}
+	// Databases can't be copied while anything is connected to them
+	defer db.Close()
+
+	if err := New(db, append(opts, WithSource(DirSource(dir)))...).Up(ctx); err != nil {
+		return nil, fmt.Errorf("migrating template database %s: %w", name, err)
+	}
+	return &TestTemplate{adminURL: adminURL, name: name}, nil
+}
+
+// Database drops and recreates the database name as a copy of the template,
+// returning a pool connected to it
+func (tt *TestTemplate) Database(ctx context.Context, name string) (*sql.DB, error) {
+	if err := recreateDatabase(ctx, tt.adminURL, name, tt.name); err != nil {
+		return nil, err
+	}
+	return openDatabase(tt.adminURL, name)
+}
+
+// NewDatabase copies the template to a database with a random name, which is
+// dropped when the test finishes. It fails t if the database can't be
+// created.
+func (tt *TestTemplate) NewDatabase(t TestingTB) *sql.DB {
+	t.Helper()
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatalf("naming test database: %s", err)
+		return nil
+	}
+	name := tt.name + "_" + hex.EncodeToString(suffix)
+
+	ctx := context.Background()
+	db, err := tt.Database(ctx, name)
+	if err != nil {
+		t.Fatalf("creating test database: %s", err)
+		return nil
+	}
+	t.Cleanup(func() {
+		db.Close()
+		if err := dropDatabase(ctx, tt.adminURL, name); err != nil {
+			t.Errorf("dropping test database %s: %s", name, err)
+		}
+	})
+	return db
+}
+
+// databaseURL replaces the database in a postgres:// URL
+func databaseURL(serverURL string, name string) (string, error) {
+	parsed, err := url.Parse(serverURL)
+	if err != nil || parsed.Scheme == "" {
+		return "", fmt.Errorf("test databases need a postgres:// URL")
+	}
+	parsed.Path = "/" + name
+	parsed.RawPath = ""
+	return parsed.String(), nil
+}
+
+// openDatabase opens a pool to the database name on the server at serverURL
+func openDatabase(serverURL string, name string) (*sql.DB, error) {
+	databaseURL, err := databaseURL(serverURL, name)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := openTestConnector(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(connector), nil
+}
+
+// recreateDatabase drops the database name if it exists and creates it,
+// copied from template unless that is empty
+func recreateDatabase(ctx context.Context, adminURL string, name string, template string) error {
+	if err := dropDatabase(ctx, adminURL, name); err != nil {
+		return err
+	}
+	create := fmt.Sprintf(`CREATE DATABASE %s`, quoteIdentifier(name))
+	if template != "" {
+		create += fmt.Sprintf(` TEMPLATE %s`, quoteIdentifier(template))
+	}
+	return adminExec(ctx, adminURL, create)
+}
+
+func dropDatabase(ctx context.Context, adminURL string, name string) error {
+	return adminExec(ctx, adminURL, fmt.Sprintf(`DROP DATABASE IF EXISTS %s`, quoteIdentifier(name)))
+}
+
+// adminExec runs a statement, such as CREATE DATABASE, which can't run in a
+// transaction, on a connection of its own
+func adminExec(ctx context.Context, adminURL string, statement string) error {
+	connector, err := openTestConnector(adminURL)
+	if err != nil {
+		return err
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, statement); err != nil {
+		return fmt.Errorf("%s: %w", statement, err)
+	}
+	return nil
+}
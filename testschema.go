@@ -0,0 +1,210 @@
+package pgmigrate
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// CallbackConnector runs Callback on each new connection made by Connector
+type CallbackConnector struct {
+	driver.Connector
+	Callback func(context.Context, driver.Conn) error
+}
+
+func (tc *CallbackConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := tc.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := tc.Callback(ctx, conn); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// testDriverNames are tried in order to find a registered Postgres driver:
+// lib/pq registers postgres, and pgx's stdlib registers pgx.
+var testDriverNames = []string{"postgres", "pgx"}
+
+func openTestConnector(testURL string) (driver.Connector, error) {
+	registered := map[string]bool{}
+	for _, name := range sql.Drivers() {
+		registered[name] = true
+	}
+
+	for _, name := range testDriverNames {
+		if !registered[name] {
+			continue
+		}
+		db, err := sql.Open(name, testURL)
+		if err != nil {
+			return nil, err
+		}
+		testDriver := db.Driver()
+		db.Close()
+		if driverContext, ok := testDriver.(driver.DriverContext); ok {
+			return driverContext.OpenConnector(testURL)
+		}
+		// lib/pq's driver only opens connections by name
+		return dsnConnector{dsn: testURL, driver: testDriver}, nil
+	}
+	return nil, fmt.Errorf("no Postgres driver registered, import one of %v", testDriverNames)
+}
+
+// dsnConnector connects with a driver which doesn't implement
+// driver.DriverContext
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// GetTestSchema drops and recreates the schema name, returning a pool whose
+// connections use it as their search_path. The name is quoted, so it is used
+// exactly as given, see TestSchemaName for deriving one from a test. A
+// Postgres driver, such as github.com/lib/pq or
+// github.com/jackc/pgx/v5/stdlib, must be imported.
+func GetTestSchema(testURL string, name string) (*sql.DB, error) {
+	return GetTestSchemaContext(context.Background(), testURL, name)
+}
+
+// GetTestSchemaContext is GetTestSchema, giving up waiting for the database
+// to accept connections when ctx is done rather than after 30 tries a second
+// apart.
+func GetTestSchemaContext(ctx context.Context, testURL string, name string) (*sql.DB, error) {
+	if err := validateSchemaName(name); err != nil {
+		return nil, err
+	}
+
+	connector, err := openTestConnector(testURL)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := sql.OpenDB(connector)
+	defer conn.Close()
+
+	for tries := 0; tries < 30; tries++ {
+		err = conn.PingContext(ctx)
+		if err == nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for the database: %w (last error: %s)", ctx.Err(), err)
+		case <-time.After(time.Second):
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`
+		DROP SCHEMA IF EXISTS %s CASCADE;
+		CREATE SCHEMA %s;
+	`, quoteIdentifier(name), quoteIdentifier(name))); err != nil {
+		return nil, err
+	}
+
+	testConnector := &CallbackConnector{
+		Connector: connector,
+		Callback: func(ctx context.Context, conn driver.Conn) error {
+			execerCtx := conn.(driver.ExecerContext)
+			_, err := execerCtx.ExecContext(ctx, fmt.Sprintf("SET search_path TO %s", quoteIdentifier(name)), []driver.NamedValue{})
+			if err != nil {
+				return fmt.Errorf("preparing connection to search_path: %w", err)
+			}
+			return nil
+		},
+	}
+
+	return sql.OpenDB(testConnector), nil
+}
+
+// TestingTB is the part of testing.TB used by NewTestSchema
+type TestingTB interface {
+	TestingT
+	Cleanup(func())
+	Name() string
+}
+
+// NewTestSchema creates a schema named after the test with a random suffix,
+// so packages tested in parallel with go test -p don't share one, and returns
+// a pool using it as with GetTestSchema. The schema is dropped and the pool
+// closed when the test finishes. It fails t if the schema can't be created.
+func NewTestSchema(t TestingTB, testURL string) *sql.DB {
+	t.Helper()
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatalf("naming test schema: %s", err)
+		return nil
+	}
+	name := TestSchemaName(t.Name() + "_" + hex.EncodeToString(suffix))
+
+	db, err := GetTestSchema(testURL, name)
+	if err != nil {
+		t.Fatalf("creating test schema: %s", err)
+		return nil
+	}
+	t.Cleanup(func() {
+		defer db.Close()
+		if _, err := db.ExecContext(context.Background(), fmt.Sprintf(`DROP SCHEMA IF EXISTS %s CASCADE`, quoteIdentifier(name))); err != nil {
+			t.Errorf("dropping test schema %s: %s", name, err)
+		}
+	})
+	return db
+}
+
+// maxIdentifierLength is the longest name Postgres keeps, in bytes, longer
+// names being silently truncated
+const maxIdentifierLength = 63
+
+func validateSchemaName(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty schema name")
+	}
+	if len(name) > maxIdentifierLength {
+		return fmt.Errorf("schema name %q is longer than %d bytes", name, maxIdentifierLength)
+	}
+	if strings.ContainsRune(name, 0) {
+		return fmt.Errorf("schema name %q contains a NUL byte", name)
+	}
+	return nil
+}
+
+// TestSchemaName turns a test name, such as TestUsers/create_admin, into a
+// schema name made only of lower case letters, digits and underscores,
+// starting with test_. Names too long for Postgres are shortened, keeping a
+// hash of the whole name so they stay distinct.
+func TestSchemaName(testName string) string {
+	name := &strings.Builder{}
+	name.WriteString("test_")
+	for _, r := range strings.ToLower(testName) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			name.WriteRune(r)
+		} else {
+			name.WriteRune('_')
+		}
+	}
+	if name.Len() <= maxIdentifierLength {
+		return name.String()
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(testName)) //nolint: errcheck
+	suffix := fmt.Sprintf("_%08x", hash.Sum32())
+	return name.String()[:maxIdentifierLength-len(suffix)] + suffix
+}
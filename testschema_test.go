@@ -0,0 +1,94 @@
+package pgmigrate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewTestSchema(t *testing.T) {
+	testURL := os.Getenv("TEST_DB")
+	if !strings.Contains(testURL, "test") {
+		t.Fatalf("Not a test URL: %s", testURL)
+	}
+	ctx := context.Background()
+
+	names := []string{}
+	for idx := 0; idx < 2; idx++ {
+		t.Run("schema", func(t *testing.T) {
+			db := NewTestSchema(t, testURL)
+			var name string
+			if err := db.QueryRowContext(ctx, `SELECT current_schema()`).Scan(&name); err != nil {
+				t.Fatal(err.Error())
+			}
+			names = append(names, name)
+		})
+	}
+	if len(names) != 2 || names[0] == names[1] {
+		t.Fatalf("Expected two different schemas, got %v", names)
+	}
+
+	conn := getTestConn(t, "test_new_schema")
+	defer conn.Close()
+	var remaining int
+	if err := conn.QueryRowContext(ctx, `SELECT count(*) FROM pg_namespace WHERE nspname = ANY($1)`, "{"+strings.Join(names, ",")+"}").Scan(&remaining); err != nil {
+		t.Fatal(err.Error())
+	}
+	if remaining != 0 {
+		t.Errorf("Expected the test schemas to be dropped, %d remain", remaining)
+	}
+}
+
+func TestGetTestSchemaContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err := GetTestSchemaContext(ctx, "postgres://localhost:1/test?sslmode=disable&connect_timeout=1", "test_cancelled")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the deadline to stop the wait, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("Expected to give up straight away, took %s", elapsed)
+	}
+}
+
+func TestGetTestSchemaBadName(t *testing.T) {
+	started := time.Now()
+	if _, err := GetTestSchema("postgres://localhost:1/test?sslmode=disable&connect_timeout=1", ""); err == nil {
+		t.Fatal("Expected an empty schema name to be refused")
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Expected the name to be refused before waiting for the database, took %s", elapsed)
+	}
+}
+
+func TestTestSchemaName(t *testing.T) {
+	for _, tc := range []struct {
+		testName string
+		want     string
+	}{
+		{"TestUsers", "test_testusers"},
+		{"TestUsers/create_admin", "test_testusers_create_admin"},
+		{`TestQuote/"; DROP SCHEMA public --`, "test_testquote____drop_schema_public___"},
+	} {
+		if got := TestSchemaName(tc.testName); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.testName, tc.want, got)
+		}
+	}
+
+	long := TestSchemaName(strings.Repeat("TestLong/", 10) + "a")
+	other := TestSchemaName(strings.Repeat("TestLong/", 10) + "b")
+	if len(long) != maxIdentifierLength || long == other {
+		t.Errorf("Expected distinct names of %d bytes, got %s and %s", maxIdentifierLength, long, other)
+	}
+
+	for _, name := range []string{"", strings.Repeat("a", 64), "a\x00b"} {
+		if err := validateSchemaName(name); err == nil {
+			t.Errorf("Expected %q to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package pgmigrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WithMigrationTimeout bounds how long each migration may run. Files run
+// with it as their statement_timeout unless they set their own with a
+// directive, and Go migrations, like files, are cancelled when it has passed.
+// A migration running too long fails with a MigrationTimeoutError and is
+// rolled back.
+func WithMigrationTimeout(timeout time.Duration) Option {
+	return func(cfg *config) {
+		cfg.migrationTimeout = timeout
+	}
+}
+
+// MigrationTimeoutError is returned when a migration runs for longer than
+// WithMigrationTimeout allows
+type MigrationTimeoutError struct {
+	Filename string
+	Timeout  time.Duration
+	Err      error
+}
+
+func (err *MigrationTimeoutError) Error() string {
+	return fmt.Sprintf("%s ran for longer than the %s migration timeout: %s", err.Filename, err.Timeout, err.Err)
+}
+
+func (err *MigrationTimeoutError) Unwrap() error {
+	return err.Err
+}
+
+// applyWithTimeout runs applyFile bounded by the migration timeout
+func applyWithTimeout(ctx context.Context, conn Queryer, cfg *config, source MigrationSource, step step, started time.Time) (int64, error) {
+	if cfg.migrationTimeout <= 0 {
+		return applyFile(ctx, conn, cfg, source, step, started)
+	}
+	attemptStarted := time.Now()
+	fileCtx, cancel := context.WithTimeout(ctx, cfg.migrationTimeout)
+	defer cancel()
+	rowsAffected, err := applyFile(fileCtx, conn, cfg, source, step, started)
+	if err != nil {
+		return 0, cfg.timeoutError(ctx, fileCtx, step, attemptStarted, err)
+	}
+	return rowsAffected, nil
+}
+
+// timeoutError reports err as a MigrationTimeoutError if the migration
+// timeout stopped the step, either by its deadline on fileCtx or through the
+// server's statement_timeout. A file's own shorter statement_timeout isn't
+// the migration timeout, so the cancellation has to come after it.
+func (cfg *config) timeoutError(ctx context.Context, fileCtx context.Context, step step, started time.Time, err error) error {
+	if cfg.migrationTimeout <= 0 || ctx.Err() != nil {
+		return err
+	}
+	if errors.Is(fileCtx.Err(), context.DeadlineExceeded) ||
+		(sqlState(err) == sqlStateQueryCanceled && time.Since(started) >= cfg.migrationTimeout) {
+		return &MigrationTimeoutError{Filename: step.Filename(), Timeout: cfg.migrationTimeout, Err: err}
+	}
+	return err
+}
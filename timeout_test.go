@@ -0,0 +1,44 @@
+package pgmigrate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMigrationTimeout(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":    s1u,
+		"001-foo.down.sql":  s1d,
+		"002-slow.up.sql":   "CREATE TABLE bar (id int); SELECT pg_sleep(30);",
+		"002-slow.down.sql": "DROP TABLE bar;",
+	})
+	defer os.RemoveAll(migrateDir)
+
+	conn := getTestConn(t, "test_migration_timeout")
+	defer conn.Close()
+
+	ctx := context.Background()
+	migrator := New(conn, WithSource(DirSource(migrateDir)), WithMigrationTimeout(500*time.Millisecond))
+	err := migrator.Up(ctx)
+	timeoutErr := &MigrationTimeoutError{}
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("Expected a MigrationTimeoutError, got %v", err)
+	}
+	if timeoutErr.Filename != "002-slow.up.sql" {
+		t.Errorf("Expected the slow file to be named, got %s", timeoutErr.Filename)
+	}
+
+	// The slow migration was rolled back, leaving version 1 clean
+	if err := migrator.To(ctx, 1); err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, err := migrator.ReadVersion(ctx); err != nil {
+		t.Fatal(err.Error())
+	} else if v != 1 {
+		t.Errorf("Expected version 1, got %d", v)
+	}
+
+}
@@ -0,0 +1,40 @@
+package pgmigrate
+
+import (
+	"context"
+)
+
+// Tracer starts spans around a run and each of its migration files, for
+// tracing systems. The otelmigrate package has an OpenTelemetry Tracer. The
+// context returned is used for what follows, so driver instrumentation nests
+// its spans inside.
+type Tracer interface {
+	// StartRun is called once the steps of a run from one version to another
+	// are planned, and the function returned when they are done
+	StartRun(ctx context.Context, from int, to int) (context.Context, func(err error))
+
+	// StartMigration is called as each file or Go migration starts, and the
+	// function returned when it is done
+	StartMigration(ctx context.Context, migration Migration, filename string, direction string) (context.Context, func(rowsAffected int64, err error))
+}
+
+// WithTracer reports runs to tracer
+func WithTracer(tracer Tracer) Option {
+	return func(cfg *config) {
+		cfg.tracer = tracer
+	}
+}
+
+func (cfg *config) startRun(ctx context.Context, from int, to int) (context.Context, func(error)) {
+	if cfg.tracer == nil {
+		return ctx, func(error) {}
+	}
+	return cfg.tracer.StartRun(ctx, from, to)
+}
+
+func (cfg *config) startMigration(ctx context.Context, step step) (context.Context, func(int64, error)) {
+	if cfg.tracer == nil {
+		return ctx, func(int64, error) {}
+	}
+	return cfg.tracer.StartMigration(ctx, step.Migration, step.Filename(), step.Direction)
+}
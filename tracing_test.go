@@ -0,0 +1,37 @@
+package pgmigrate
+
+import (
+	"context"
+	"testing"
+)
+
+type recordingTracer struct {
+	started []string
+	errors  []error
+}
+
+func (t *recordingTracer) StartRun(ctx context.Context, from int, to int) (context.Context, func(error)) {
+	return ctx, func(err error) {}
+}
+
+func (t *recordingTracer) StartMigration(ctx context.Context, migration Migration, filename string, direction string) (context.Context, func(int64, error)) {
+	t.started = append(t.started, filename)
+	return ctx, func(rowsAffected int64, err error) {
+		t.errors = append(t.errors, err)
+	}
+}
+
+func TestTracerMigrationSpan(t *testing.T) {
+	tracer := &recordingTracer{}
+	migration := Migration{Version: 4, Name: "foo", UpFile: "004-foo.up.sql"}
+	cfg := newConfig([]Option{WithTracer(tracer)})
+	if err := runFile(context.Background(), nil, failingSource{}, step{Migration: migration, Direction: directionUp}, cfg); err == nil {
+		t.Fatal("Expected the read to fail")
+	}
+	if len(tracer.started) != 1 || tracer.started[0] != "004-foo.up.sql" {
+		t.Fatalf("Expected a span for 004-foo.up.sql, got %v", tracer.started)
+	}
+	if len(tracer.errors) != 1 || tracer.errors[0] == nil {
+		t.Errorf("Expected the span to end with the error, got %v", tracer.errors)
+	}
+}
@@ -0,0 +1,212 @@
+// Package vaultcreds connects with short lived credentials from the database
+// secrets engine of HashiCorp Vault, so no long lived migration user needs
+// to exist. The lease is renewed while the run goes on, and revoked when it
+// is done.
+package vaultcreds
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.daemonl.com/pgmigrate/pgauth"
+)
+
+// refreshBefore is how long before a lease ends new connections get new
+// credentials rather than the expiring ones, or a third of shorter leases
+const refreshBefore = 30 * time.Second
+
+// Provider is a pgauth.Provider with credentials for a role of Vault's
+// database secrets engine. Every connection shares one lease, which is
+// renewed at two thirds of its duration until Close.
+type Provider struct {
+	// Address is Vault's address, such as https://vault.example.com:8200
+	Address string
+
+	// Token authenticates to Vault, and Namespace is its enterprise
+	// namespace, if any
+	Token     string
+	Namespace string
+
+	// Mount is where the secrets engine is mounted, database by default,
+	// and Role the role to generate credentials for
+	Mount string
+	Role  string
+
+	// Client sends the requests, http.DefaultClient if nil
+	Client *http.Client
+
+	lock    sync.Mutex
+	creds   pgauth.Credentials
+	leaseID string
+	refresh time.Time
+	stop    chan struct{}
+}
+
+// New creates a Provider for role from Vault's usual environment:
+// VAULT_ADDR, VAULT_TOKEN, or the ~/.vault-token file the vault CLI's login
+// writes, and VAULT_NAMESPACE
+func New(role string) (*Provider, error) {
+	address := os.Getenv("VAULT_ADDR")
+	if address == "" {
+		return nil, fmt.Errorf("VAULT_ADDR is not set")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		tokenFile, err := ioutil.ReadFile(filepath.Join(home, ".vault-token"))
+		if err != nil {
+			return nil, fmt.Errorf("VAULT_TOKEN is not set, and there is no token from vault login: %w", err)
+		}
+		token = strings.TrimSpace(string(tokenFile))
+	}
+	return &Provider{
+		Address:   address,
+		Token:     token,
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+		Role:      role,
+	}, nil
+}
+
+type leaseResponse struct {
+	LeaseID       string `json:"lease_id"`
+	LeaseDuration int    `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+	Data          struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"data"`
+}
+
+// Credentials returns the lease's user and password, generating new ones the
+// first time and once the lease is close to ending
+func (p *Provider) Credentials(ctx context.Context, params pgauth.Params) (pgauth.Credentials, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.leaseID != "" && time.Now().Before(p.refresh) {
+		return p.creds, nil
+	}
+
+	mount := p.Mount
+	if mount == "" {
+		mount = "database"
+	}
+	lease := leaseResponse{}
+	if err := p.request(ctx, http.MethodGet, "/v1/"+strings.Trim(mount, "/")+"/creds/"+p.Role, nil, &lease); err != nil {
+		return pgauth.Credentials{}, err
+	}
+	p.stopRenewing()
+	p.creds = pgauth.Credentials{User: lease.Data.Username, Password: lease.Data.Password}
+	p.leaseID = lease.LeaseID
+	p.extend(time.Duration(lease.LeaseDuration) * time.Second)
+	if lease.Renewable && lease.LeaseDuration > 0 {
+		p.stop = make(chan struct{})
+		go p.renew(lease.LeaseID, time.Duration(lease.LeaseDuration)*time.Second, p.stop)
+	}
+	return p.creds, nil
+}
+
+// renew extends the lease at two thirds of each duration Vault grants, until
+// stop is closed or Vault refuses, as it does at the role's max TTL
+func (p *Provider) renew(leaseID string, duration time.Duration, stop chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(duration * 2 / 3):
+		}
+		renewed := leaseResponse{}
+		body := map[string]interface{}{"lease_id": leaseID, "increment": int(duration.Seconds())}
+		if err := p.request(context.Background(), http.MethodPut, "/v1/sys/leases/renew", body, &renewed); err != nil || renewed.LeaseDuration <= 0 {
+			return
+		}
+		duration = time.Duration(renewed.LeaseDuration) * time.Second
+		p.lock.Lock()
+		if p.leaseID == leaseID {
+			p.extend(duration)
+		}
+		p.lock.Unlock()
+	}
+}
+
+// extend records that the lease lasts for duration from now
+func (p *Provider) extend(duration time.Duration) {
+	margin := refreshBefore
+	if duration/3 < margin {
+		margin = duration / 3
+	}
+	p.refresh = time.Now().Add(duration - margin)
+}
+
+// stopRenewing stops renewing the current lease, if it is being renewed
+func (p *Provider) stopRenewing() {
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
+}
+
+// Close stops renewing the lease and revokes it, which drops the user
+func (p *Provider) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.stopRenewing()
+	if p.leaseID == "" {
+		return nil
+	}
+	leaseID := p.leaseID
+	p.leaseID = ""
+	return p.request(context.Background(), http.MethodPut, "/v1/sys/leases/revoke", map[string]string{"lease_id": leaseID}, nil)
+}
+
+// request sends a request to Vault, decoding the response into out
+func (p *Provider) request(ctx context.Context, method string, path string, in interface{}, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(p.Address, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", p.Token)
+	if p.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.Namespace)
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("vault %s %s responded %s: %s", method, path, res.Status, strings.TrimSpace(string(content)))
+	}
+	if out == nil || len(content) == 0 {
+		return nil
+	}
+	return json.Unmarshal(content, out)
+}
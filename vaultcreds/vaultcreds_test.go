@@ -0,0 +1,95 @@
+package vaultcreds
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.daemonl.com/pgmigrate/pgauth"
+)
+
+// fakeVault is the part of Vault's API the provider uses, with leases of one
+// second
+type fakeVault struct {
+	lock    sync.Mutex
+	issued  int
+	renewed int
+	revoked []string
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if r.Header.Get("X-Vault-Token") != "root" {
+		http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+		return
+	}
+	body := map[string]string{}
+	json.NewDecoder(r.Body).Decode(&body)
+	switch r.URL.Path {
+	case "/v1/database/creds/migrator":
+		v.issued++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"lease_id":       "database/creds/migrator/lease",
+			"lease_duration": 1,
+			"renewable":      true,
+			"data":           map[string]string{"username": "v-migrator", "password": "secret"},
+		})
+	case "/v1/sys/leases/renew":
+		v.renewed++
+		json.NewEncoder(w).Encode(map[string]interface{}{"lease_id": body["lease_id"], "lease_duration": 1, "renewable": true})
+	case "/v1/sys/leases/revoke":
+		v.revoked = append(v.revoked, body["lease_id"])
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	vault := &fakeVault{}
+	server := httptest.NewServer(vault)
+	defer server.Close()
+
+	ctx := context.Background()
+	provider := &Provider{Address: server.URL, Token: "root", Role: "migrator"}
+	creds, err := provider.Credentials(ctx, pgauth.Params{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if creds.User != "v-migrator" || creds.Password != "secret" {
+		t.Errorf("Wrong credentials %+v", creds)
+	}
+
+	// The renewal keeps the lease, so nothing new is issued
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := provider.Credentials(ctx, pgauth.Params{}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := provider.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	vault.lock.Lock()
+	defer vault.lock.Unlock()
+	if vault.issued != 1 || vault.renewed == 0 {
+		t.Errorf("Expected one lease to be renewed, issued %d and renewed %d", vault.issued, vault.renewed)
+	}
+	if len(vault.revoked) != 1 || vault.revoked[0] != "database/creds/migrator/lease" {
+		t.Errorf("Expected the lease to be revoked, got %v", vault.revoked)
+	}
+}
+
+func TestCredentialsDenied(t *testing.T) {
+	server := httptest.NewServer(&fakeVault{})
+	defer server.Close()
+
+	provider := &Provider{Address: server.URL, Token: "wrong", Role: "migrator"}
+	if _, err := provider.Credentials(context.Background(), pgauth.Params{}); err == nil {
+		t.Fatal("Expected an error for a bad token")
+	}
+}
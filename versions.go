@@ -0,0 +1,137 @@
+package pgmigrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// VersionScheme is how migration versions are numbered
+type VersionScheme int
+
+const (
+	// SequentialVersions number migrations 1, 2, 3 and so on, without gaps.
+	// This is the default.
+	SequentialVersions VersionScheme = iota
+
+	// TimestampVersions number migrations by the UTC time they were created,
+	// as in 20240601123000-add-users.up.sql, so that migrations written on
+	// different branches don't claim the same number. Gaps are expected.
+	TimestampVersions
+)
+
+const timestampFormat = "20060102150405"
+
+// WithVersionScheme sets how migration versions are numbered
+func WithVersionScheme(scheme VersionScheme) Option {
+	return func(cfg *config) {
+		cfg.scheme = scheme
+	}
+}
+
+// validate checks the versions in byVersion, from firstMigration up to and
+// including maxMigration, fit the scheme, returning the first problem
+func (scheme VersionScheme) validate(byVersion map[int]Migration, firstMigration int, maxMigration int, forwardOnly bool) error {
+	if problems := scheme.problems(byVersion, firstMigration, maxMigration, forwardOnly); len(problems) > 0 {
+		return problems[0]
+	}
+	return nil
+}
+
+// problems lists everything wrong with the versions in byVersion, in
+// version order. Unless forwardOnly is set, each must have a down as well as
+// an up, and a down file without an up is always a problem.
+func (scheme VersionScheme) problems(byVersion map[int]Migration, firstMigration int, maxMigration int, forwardOnly bool) []error {
+	problems := []error{}
+	check := func(version int, migration Migration) {
+		if !migration.hasUp() {
+			if migration.DownFile != "" {
+				problems = append(problems, fmt.Errorf("%w %d: %s has no up file", ErrMissingUp, version, migration.DownFile))
+			} else {
+				problems = append(problems, missingUpError(version))
+			}
+		}
+		if !migration.hasDown() && !forwardOnly {
+			problems = append(problems, missingDownError(version))
+		}
+	}
+
+	versions := make([]int, 0, len(byVersion))
+	for version := range byVersion {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	switch scheme {
+	case SequentialVersions:
+		// Gaps are reported once each, however wide
+		expect := firstMigration
+		for _, version := range versions {
+			if version < firstMigration || version > maxMigration {
+				continue
+			}
+			if version == expect+1 {
+				problems = append(problems, missingUpError(expect))
+			} else if version > expect {
+				problems = append(problems, fmt.Errorf("%w %d, nor any version up to %d", ErrMissingUp, expect, version-1))
+			}
+			check(version, byVersion[version])
+			expect = version + 1
+		}
+	case TimestampVersions:
+		for _, version := range versions {
+			if _, err := time.Parse(timestampFormat, strconv.Itoa(version)); err != nil {
+				problems = append(problems, fmt.Errorf("version %d is not a timestamp like %s", version, timestampFormat))
+				continue
+			}
+			check(version, byVersion[version])
+		}
+	default:
+		problems = append(problems, fmt.Errorf("unknown version scheme %d", scheme))
+	}
+	return problems
+}
+
+// next is the version for a new migration after last, which is 0 when there
+// are no migrations yet
+func (scheme VersionScheme) next(last int, now time.Time) int {
+	if scheme == TimestampVersions {
+		next, _ := strconv.Atoi(now.UTC().Format(timestampFormat))
+		if next <= last {
+			next = last + 1
+		}
+		return next
+	}
+	return last + 1
+}
+
+// ensureBigintVersions widens the version columns of tables created before
+// they were bigint, which timestamps don't fit in
+func (t trackingTable) ensureBigintVersions(ctx context.Context, conn Queryer) error {
+	for _, table := range []struct {
+		name    string
+		columns []string
+	}{
+		{t.versionTable(), []string{"version", "dirty_version"}},
+		{t.historyTable(), []string{"version"}},
+	} {
+		dataType := ""
+		if err := conn.QueryRowContext(ctx, `
+			SELECT format_type(atttypid, atttypmod) FROM pg_attribute
+			WHERE attrelid = $1::regclass AND attname = 'version'
+		`, table.name).Scan(&dataType); err != nil {
+			return err
+		}
+		if dataType == "bigint" {
+			continue
+		}
+		for _, column := range table.columns {
+			if _, err := conn.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN %s TYPE bigint`, table.name, column)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package pgmigrate
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTimestampVersions(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"20240601123000-foo.up.sql":   s1u,
+		"20240601123000-foo.down.sql": s1d,
+		"20240715090000-bar.up.sql":   s2u,
+		"20240715090000-bar.down.sql": s2d,
+	})
+	defer os.RemoveAll(migrateDir)
+
+	if _, _, err := loadMigrations(newConfig([]Option{WithSource(DirSource(migrateDir)), WithVersionScheme(SequentialVersions)})); err == nil {
+		t.Error("Expected timestamps to fail as sequential versions")
+	}
+
+	byVersion, maxMigration, err := loadMigrations(newConfig([]Option{WithSource(DirSource(migrateDir)), WithVersionScheme(TimestampVersions)}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if maxMigration != 20240715090000 {
+		t.Errorf("Unexpected max version %d", maxMigration)
+	}
+
+	steps, err := planSteps(byVersion, 0, maxMigration)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(steps) != 2 || steps[0].Version != 20240601123000 || steps[1].ResultVersion() != 20240715090000 {
+		t.Errorf("Unexpected up steps %+v", steps)
+	}
+
+	steps, err = planSteps(byVersion, maxMigration, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(steps) != 2 || steps[0].ResultVersion() != 20240601123000 || steps[1].ResultVersion() != 0 {
+		t.Errorf("Unexpected down steps %+v", steps)
+	}
+
+	if _, err := planSteps(byVersion, 0, 20240601123001); err == nil {
+		t.Error("Expected an error planning to an unknown version")
+	}
+
+	bad := writeMigrationFiles(t, map[string]string{
+		"12-foo.up.sql": s1u,
+	})
+	defer os.RemoveAll(bad)
+	if _, _, err := loadMigrations(newConfig([]Option{WithSource(DirSource(bad)), WithVersionScheme(TimestampVersions)})); err == nil {
+		t.Error("Expected an error for a version which isn't a timestamp")
+	}
+}
+
+func TestVersionSchemeNext(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		scheme VersionScheme
+		last   int
+		expect int
+	}{
+		{SequentialVersions, 0, 1},
+		{SequentialVersions, 9, 10},
+		{TimestampVersions, 0, 20240601123000},
+		{TimestampVersions, 20240601123000, 20240601123001},
+	} {
+		if got := tc.scheme.next(tc.last, now); got != tc.expect {
+			t.Errorf("next after %d: expected %d, got %d", tc.last, tc.expect, got)
+		}
+	}
+}
+
+func TestProblems(t *testing.T) {
+	migrateDir := writeMigrationFiles(t, map[string]string{
+		"001-foo.up.sql":   s1u,
+		"001-foo.down.sql": s1d,
+		"002-bar.down.sql": s2d,
+		"005-qux.up.sql":   s3u,
+		"005-qux.down.sql": s3d,
+		"006-last.up.sql":  "SELECT 1",
+	})
+	defer os.RemoveAll(migrateDir)
+
+	problems := New(nil, WithSource(DirSource(migrateDir))).Problems()
+	expect := []string{
+		"Missing Up migration 2: 002-bar.down.sql has no up file",
+		"Missing Up migration 3, nor any version up to 4",
+		"Missing Down migration 6",
+	}
+	if len(problems) != len(expect) {
+		t.Fatalf("Expected %d problems, got %v", len(expect), problems)
+	}
+	for idx, problem := range problems {
+		if problem.Error() != expect[idx] {
+			t.Errorf("Expected %q, got %q", expect[idx], problem.Error())
+		}
+	}
+	if !errors.Is(problems[0], ErrMissingUp) || !errors.Is(problems[2], ErrMissingDown) {
+		t.Errorf("Expected the problems to wrap the sentinel errors")
+	}
+
+	if err := New(nil, WithSource(DirSource(migrateDir))).Validate(); err == nil || err.Error() != expect[0] {
+		t.Errorf("Expected Validate to return the first problem, got %v", err)
+	}
+
+	forwardOnly := New(nil, WithSource(DirSource(migrateDir)), WithForwardOnly(true)).Problems()
+	if len(forwardOnly) != 2 {
+		t.Errorf("Expected forward only to allow the missing down, got %v", forwardOnly)
+	}
+}